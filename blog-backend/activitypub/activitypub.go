@@ -0,0 +1,240 @@
+// Package activitypub makes the blog an ActivityPub actor that fediverse
+// accounts can follow. Posts are published as Article objects; Create and
+// Update activities are delivered to followers' inboxes with HTTP
+// signatures.
+package activitypub
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+	"github.com/gg582/chi-blog/blog-backend/models"
+	"github.com/gg582/chi-blog/blog-backend/site"
+)
+
+// ContentType is the media type of ActivityPub documents.
+const ContentType = "application/activity+json"
+
+// Public is the audience of public activities.
+const Public = "https://www.w3.org/ns/activitystreams#Public"
+
+var contexts = []string{
+	"https://www.w3.org/ns/activitystreams",
+	"https://w3id.org/security/v1",
+}
+
+// Username is the handle of the blog's actor, as in @blog@chatter.pw.
+var Username = "blog"
+
+// Name and Summary describe the actor on its profile.
+var (
+	Name    = "chi-blog"
+	Summary = "Posts from the blog at chatter.pw"
+)
+
+// URLs of the actor and its collections.
+func ActorID() string      { return site.APIURL + "/ap/actor" }
+func KeyID() string        { return ActorID() + "#main-key" }
+func InboxURL() string     { return site.APIURL + "/ap/inbox" }
+func OutboxURL() string    { return site.APIURL + "/ap/outbox" }
+func FollowersURL() string { return site.APIURL + "/ap/followers" }
+
+// ObjectID returns the id of the Article for a post.
+func ObjectID(slug string) string {
+	return site.APIURL + "/ap/posts/" + url.PathEscape(slug)
+}
+
+// Handle returns the actor's acct: resource, e.g. acct:blog@chatter.pw.
+func Handle() string {
+	return "acct:" + Username + "@" + site.Host()
+}
+
+// Actor returns the actor document.
+func Actor() (map[string]any, error) {
+	pub, err := publicKeyPEM()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"@context":          contexts,
+		"id":                ActorID(),
+		"type":              "Person",
+		"preferredUsername": Username,
+		"name":              Name,
+		"summary":           Summary,
+		"url":               site.URL,
+		"inbox":             InboxURL(),
+		"outbox":            OutboxURL(),
+		"followers":         FollowersURL(),
+		"publicKey": map[string]string{
+			"id":           KeyID(),
+			"owner":        ActorID(),
+			"publicKeyPem": pub,
+		},
+	}, nil
+}
+
+// Article returns the object that represents post.
+func Article(post models.Post) map[string]any {
+	return map[string]any{
+		"id":           ObjectID(post.ID),
+		"type":         "Article",
+		"name":         post.Title,
+		"summary":      post.Summary,
+		"content":      post.ContentHTML,
+		"mediaType":    "text/html",
+		"url":          site.PostURL(post.ID),
+		"attributedTo": ActorID(),
+		"published":    post.CreatedAt.UTC().Format(time.RFC3339),
+		"to":           []string{Public},
+		"cc":           []string{FollowersURL()},
+	}
+}
+
+// Create returns the activity publishing post.
+func Create(post models.Post) map[string]any {
+	return map[string]any{
+		"@context":  contexts,
+		"id":        ObjectID(post.ID) + "#create",
+		"type":      "Create",
+		"actor":     ActorID(),
+		"published": post.CreatedAt.UTC().Format(time.RFC3339),
+		"to":        []string{Public},
+		"cc":        []string{FollowersURL()},
+		"object":    Article(post),
+	}
+}
+
+// Update returns the activity announcing that post was edited at t.
+func Update(post models.Post, t time.Time) map[string]any {
+	object := Article(post)
+	object["updated"] = t.UTC().Format(time.RFC3339)
+	return map[string]any{
+		"@context": contexts,
+		"id":       ObjectID(post.ID) + "#update-" + t.UTC().Format("20060102T150405Z"),
+		"type":     "Update",
+		"actor":    ActorID(),
+		"to":       []string{Public},
+		"cc":       []string{FollowersURL()},
+		"object":   object,
+	}
+}
+
+// Collection returns an OrderedCollection document.
+func Collection(id string, items []any) map[string]any {
+	return map[string]any{
+		"@context":     contexts,
+		"id":           id,
+		"type":         "OrderedCollection",
+		"totalItems":   len(items),
+		"orderedItems": items,
+	}
+}
+
+var (
+	keyMu sync.Mutex
+	key   *rsa.PrivateKey
+)
+
+// privateKey returns the actor's signing key. It is created on first use
+// and kept in the database, as followers cache the public half.
+func privateKey() (*rsa.PrivateKey, error) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if key != nil {
+		return key, nil
+	}
+
+	var encoded string
+	err := database.DB.QueryRow("SELECT private_key FROM activitypub_key WHERE id = 1").Scan(&encoded)
+	if err == sql.ErrNoRows {
+		k, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		encoded = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}))
+		// Another process may have stored a key first; keep that one.
+		if _, err := database.DB.Exec("INSERT OR IGNORE INTO activitypub_key (id, private_key) VALUES (1, ?)", encoded); err != nil {
+			return nil, err
+		}
+		if err := database.DB.QueryRow("SELECT private_key FROM activitypub_key WHERE id = 1").Scan(&encoded); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, errInvalidKey
+	}
+	k, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key = k
+	return key, nil
+}
+
+func publicKeyPEM() (string, error) {
+	k, err := privateKey()
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKIXPublicKey(&k.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// Follower is a remote actor following the blog.
+type Follower struct {
+	Actor     string    `json:"actor"`
+	Inbox     string    `json:"inbox"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// addFollower stores or refreshes a follower. inbox is where activities
+// for it are delivered, its shared inbox when it has one.
+func addFollower(actor, inbox, followID string) error {
+	_, err := database.DB.Exec(
+		`INSERT INTO activitypub_followers (actor, inbox, follow_id, created_at) VALUES (?,?,?,?)
+		ON CONFLICT (actor) DO UPDATE SET inbox = excluded.inbox, follow_id = excluded.follow_id`,
+		actor, inbox, followID, time.Now().Unix(),
+	)
+	return err
+}
+
+func removeFollower(actor string) error {
+	_, err := database.DB.Exec("DELETE FROM activitypub_followers WHERE actor = ?", actor)
+	return err
+}
+
+// Followers lists the followers, oldest first.
+func Followers() ([]Follower, error) {
+	rows, err := database.DB.Query("SELECT actor, inbox, created_at FROM activitypub_followers ORDER BY created_at, actor")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	followers := []Follower{}
+	for rows.Next() {
+		var f Follower
+		var created int64
+		if err := rows.Scan(&f.Actor, &f.Inbox, &created); err != nil {
+			return nil, err
+		}
+		f.CreatedAt = time.Unix(created, 0)
+		followers = append(followers, f)
+	}
+	return followers, rows.Err()
+}
@@ -0,0 +1,100 @@
+package activitypub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/models"
+)
+
+// Delivery is an activity waiting to be posted to an inbox.
+type Delivery struct {
+	Inbox    string
+	Activity []byte
+}
+
+// JobQueue feeds the delivery workers, which run Process. It is nil until
+// the server starts them.
+var JobQueue chan Delivery
+
+// Process posts d to its inbox.
+func Process(id int, d Delivery) {
+	if err := deliver(d.Inbox, d.Activity); err != nil {
+		log.Printf("ActivityPub worker %d: delivery to %s failed: %v", id, d.Inbox, err)
+		return
+	}
+	log.Printf("ActivityPub worker %d: delivered to %s", id, d.Inbox)
+}
+
+// enqueue submits an activity for delivery without waiting. It reports false
+// if the queue is full or not running.
+func enqueue(inbox string, activity map[string]any) bool {
+	body, err := json.Marshal(activity)
+	if err != nil {
+		log.Printf("ActivityPub: encoding activity for %s: %v", inbox, err)
+		return false
+	}
+	select {
+	case JobQueue <- Delivery{Inbox: inbox, Activity: body}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Publish delivers a Create activity for post, or an Update when updated is
+// set, to every follower. Followers sharing an inbox get it once. It
+// returns the number of deliveries queued.
+func Publish(post models.Post, updated bool) (int, error) {
+	followers, err := Followers()
+	if err != nil {
+		return 0, err
+	}
+	activity := Create(post)
+	if updated {
+		activity = Update(post, time.Now())
+	}
+
+	queued := 0
+	seen := map[string]bool{}
+	for _, f := range followers {
+		if seen[f.Inbox] {
+			continue
+		}
+		seen[f.Inbox] = true
+		if !enqueue(f.Inbox, activity) {
+			log.Printf("ActivityPub delivery queue is full. Not delivering %s to %s.", post.ID, f.Inbox)
+			continue
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+// deliver posts a signed activity to inbox.
+func deliver(inbox string, activity []byte) error {
+	req, err := http.NewRequest(http.MethodPost, inbox, bytes.NewReader(activity))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("User-Agent", userAgent)
+	if err := sign(req, activity); err != nil {
+		return err
+	}
+	resp, err := Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxBody))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("inbox answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package activitypub
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+var (
+	// ErrInvalidActivity means the request body is not an activity.
+	ErrInvalidActivity = errors.New("invalid activity")
+	// ErrForbidden means a signed activity was sent on behalf of another
+	// actor.
+	ErrForbidden = errors.New("activity actor does not match the signature")
+)
+
+// activity holds the fields of an incoming activity that we use.
+type activity struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	Actor  string          `json:"actor"`
+	Object json.RawMessage `json:"object"`
+}
+
+// objectID returns the id of an object that is given either as a string or
+// as an embedded object.
+func objectID(raw json.RawMessage) string {
+	var id string
+	if json.Unmarshal(raw, &id) == nil {
+		return id
+	}
+	var obj struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(raw, &obj)
+	return obj.ID
+}
+
+// HandleInbox verifies the signature of an inbox request with body and acts
+// on Follow and Undo Follow. Other activities are accepted and ignored.
+// Errors wrapping ErrInvalidActivity, ErrInvalidSignature or ErrForbidden
+// are the sender's fault.
+func HandleInbox(r *http.Request, body []byte) error {
+	var a activity
+	if err := json.Unmarshal(body, &a); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidActivity, err)
+	}
+	signer, err := verify(r, body)
+	if err != nil {
+		return err
+	}
+	if a.Actor != signer.ID {
+		return ErrForbidden
+	}
+
+	switch a.Type {
+	case "Follow":
+		if objectID(a.Object) != ActorID() {
+			return nil
+		}
+		if err := addFollower(signer.ID, signer.deliveryInbox(), a.ID); err != nil {
+			return err
+		}
+		log.Printf("ActivityPub: %s follows the blog", signer.ID)
+		accept := map[string]any{
+			"@context": contexts,
+			"id":       ActorID() + "#accept-" + shortHash(a.ID),
+			"type":     "Accept",
+			"actor":    ActorID(),
+			"object":   json.RawMessage(body),
+		}
+		// Accepts go to the follower's own inbox, not a shared one.
+		if !enqueue(signer.Inbox, accept) {
+			log.Printf("ActivityPub delivery queue is full. Accept for %s not sent.", signer.ID)
+		}
+	case "Undo":
+		var undone activity
+		if json.Unmarshal(a.Object, &undone) != nil {
+			// Only the id of the Follow was sent.
+			undone.Type = "Follow"
+		}
+		if undone.Type != "Follow" {
+			return nil
+		}
+		if err := removeFollower(signer.ID); err != nil {
+			return err
+		}
+		log.Printf("ActivityPub: %s unfollowed the blog", signer.ID)
+	}
+	return nil
+}
+
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:8])
+}
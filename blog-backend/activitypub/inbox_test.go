@@ -0,0 +1,258 @@
+package activitypub
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+)
+
+// remote is a fake remote server hosting actors with a shared key.
+type remote struct {
+	srv *httptest.Server
+	key *rsa.PrivateKey
+	// ids maps actor paths to the id their document claims.
+	ids map[string]string
+}
+
+// setupInbox points the database at a fresh file, starts a remote server
+// with the actor /users/alice, swaps Client for the server's client (the
+// real one refuses loopback) and captures deliveries in JobQueue.
+func setupInbox(t *testing.T) *remote {
+	t.Helper()
+	database.Path = filepath.Join(t.TempDir(), "auth.db")
+	database.InitDatabase()
+	t.Cleanup(func() { database.DB.Close() })
+	keyMu.Lock()
+	key = nil
+	keyMu.Unlock()
+
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&k.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	rm := &remote{key: k, ids: map[string]string{}}
+	rm.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := rm.ids[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":    id,
+			"type":  "Person",
+			"inbox": rm.srv.URL + r.URL.Path + "/inbox",
+			"publicKey": map[string]any{
+				"id":           id + "#main-key",
+				"owner":        id,
+				"publicKeyPem": pubPEM,
+			},
+		})
+	}))
+	t.Cleanup(rm.srv.Close)
+	rm.ids["/users/alice"] = rm.actor("alice")
+
+	oldClient := Client
+	Client = rm.srv.Client()
+	t.Cleanup(func() { Client = oldClient })
+	oldQueue := JobQueue
+	JobQueue = make(chan Delivery, 8)
+	t.Cleanup(func() { JobQueue = oldQueue })
+	return rm
+}
+
+func (rm *remote) actor(name string) string {
+	return rm.srv.URL + "/users/" + name
+}
+
+// request builds an inbox request for activity signed with the remote key
+// under keyID. mutate may change the request after it was signed.
+func (rm *remote) request(t *testing.T, activity map[string]any, keyID string, date time.Time, mutate func(*http.Request)) (*http.Request, []byte) {
+	t.Helper()
+	body, err := json.Marshal(activity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "https://blog.example/ap/inbox", bytes.NewReader(body))
+	r.Header.Set("Content-Type", ContentType)
+	r.Header.Set("Date", date.UTC().Format(http.TimeFormat))
+	r.Header.Set("Digest", digest(body))
+
+	sum := sha256.Sum256([]byte(signingString(r, signedHeaders)))
+	sig, err := rsa.SignPKCS1v15(nil, rm.key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(signedHeaders, " "), base64.StdEncoding.EncodeToString(sig)))
+	if mutate != nil {
+		mutate(r)
+	}
+	return r, body
+}
+
+func follow(actor string) map[string]any {
+	return map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       actor + "/follows/1",
+		"type":     "Follow",
+		"actor":    actor,
+		"object":   ActorID(),
+	}
+}
+
+func followers(t *testing.T) []string {
+	t.Helper()
+	list, err := Followers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actors []string
+	for _, f := range list {
+		actors = append(actors, f.Actor)
+	}
+	return actors
+}
+
+func TestInboxFollowAndUndo(t *testing.T) {
+	rm := setupInbox(t)
+	alice := rm.actor("alice")
+
+	r, body := rm.request(t, follow(alice), alice+"#main-key", time.Now(), nil)
+	if err := HandleInbox(r, body); err != nil {
+		t.Fatalf("Follow: %v", err)
+	}
+	if got := followers(t); len(got) != 1 || got[0] != alice {
+		t.Fatalf("followers after Follow = %v, want [%s]", got, alice)
+	}
+	select {
+	case d := <-JobQueue:
+		var accept activity
+		json.Unmarshal(d.Activity, &accept)
+		if d.Inbox != alice+"/inbox" || accept.Type != "Accept" || objectID(accept.Object) != alice+"/follows/1" {
+			t.Errorf("queued %s to %s, want an Accept of the Follow to alice's inbox", d.Activity, d.Inbox)
+		}
+	default:
+		t.Error("no Accept was queued")
+	}
+
+	undo := map[string]any{
+		"id":     alice + "/undo/1",
+		"type":   "Undo",
+		"actor":  alice,
+		"object": follow(alice),
+	}
+	r, body = rm.request(t, undo, alice+"#main-key", time.Now(), nil)
+	if err := HandleInbox(r, body); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if got := followers(t); len(got) != 0 {
+		t.Errorf("followers after Undo = %v, want none", got)
+	}
+}
+
+func TestInboxRejects(t *testing.T) {
+	rm := setupInbox(t)
+	alice := rm.actor("alice")
+	// Served at /users/eve but claiming to be alice.
+	rm.ids["/users/eve"] = alice
+
+	tests := []struct {
+		name     string
+		activity map[string]any
+		keyID    string
+		date     time.Time
+		mutate   func(*http.Request)
+		want     error
+	}{
+		{
+			name:     "bad digest",
+			activity: follow(alice),
+			keyID:    alice + "#main-key",
+			date:     time.Now(),
+			mutate:   func(r *http.Request) { r.Header.Set("Digest", digest([]byte("other body"))) },
+			want:     ErrInvalidSignature,
+		},
+		{
+			name:     "stale date",
+			activity: follow(alice),
+			keyID:    alice + "#main-key",
+			date:     time.Now().Add(-maxClockSkew - time.Hour),
+			want:     ErrInvalidSignature,
+		},
+		{
+			name:     "tampered header",
+			activity: follow(alice),
+			keyID:    alice + "#main-key",
+			date:     time.Now(),
+			mutate:   func(r *http.Request) { r.Header.Set("Date", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)) },
+			want:     ErrInvalidSignature,
+		},
+		{
+			name:     "unsigned",
+			activity: follow(alice),
+			keyID:    alice + "#main-key",
+			date:     time.Now(),
+			mutate:   func(r *http.Request) { r.Header.Del("Signature") },
+			want:     ErrInvalidSignature,
+		},
+		{
+			name:     "actor does not match the key",
+			activity: follow(rm.actor("mallory")),
+			keyID:    alice + "#main-key",
+			date:     time.Now(),
+			want:     ErrForbidden,
+		},
+		{
+			name:     "key served under another actor's id",
+			activity: follow(rm.actor("eve")),
+			keyID:    rm.actor("eve") + "#main-key",
+			date:     time.Now(),
+			want:     ErrInvalidSignature,
+		},
+		{
+			name:     "unknown actor",
+			activity: follow(rm.actor("bob")),
+			keyID:    rm.actor("bob") + "#main-key",
+			date:     time.Now(),
+			want:     ErrInvalidSignature,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, body := rm.request(t, tt.activity, tt.keyID, tt.date, tt.mutate)
+			if err := HandleInbox(r, body); !errors.Is(err, tt.want) {
+				t.Errorf("HandleInbox error = %v, want %v", err, tt.want)
+			}
+			if got := followers(t); len(got) != 0 {
+				t.Errorf("followers = %v, want none", got)
+			}
+		})
+	}
+
+	if err := HandleInbox(httptest.NewRequest(http.MethodPost, "/ap/inbox", nil), []byte("not json")); !errors.Is(err, ErrInvalidActivity) {
+		t.Errorf("HandleInbox of a non-JSON body: %v, want ErrInvalidActivity", err)
+	}
+}
@@ -0,0 +1,221 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/utils"
+)
+
+// maxBody is how much of a remote document or inbox request is read.
+const maxBody = 1 << 20
+
+// maxClockSkew is how far the Date of a signed request may be off.
+const maxClockSkew = 12 * time.Hour
+
+const userAgent = "chi-blog activitypub (+https://github.com/gg582/chi-blog)"
+
+// Client fetches remote actors and delivers activities. The addresses come
+// from strangers, so it only connects to public addresses. Tests may replace
+// it.
+var Client = utils.NewPublicClient(10 * time.Second)
+
+var (
+	errInvalidKey = errors.New("invalid key")
+	// ErrInvalidSignature means an inbox request is not signed by the key
+	// of a reachable actor.
+	ErrInvalidSignature = errors.New("invalid HTTP signature")
+)
+
+// signedHeaders are the headers covered by our signatures, in order.
+var signedHeaders = []string{"(request-target)", "host", "date", "digest"}
+
+// sign adds Date, Digest and a draft-cavage HTTP Signature made with the
+// actor's key to req, whose body is body.
+func sign(req *http.Request, body []byte) error {
+	k, err := privateKey()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("Digest", digest(body))
+
+	sum := sha256.Sum256([]byte(signingString(req, signedHeaders)))
+	sig, err := rsa.SignPKCS1v15(nil, k, crypto.SHA256, sum[:])
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		KeyID(), strings.Join(signedHeaders, " "), base64.StdEncoding.EncodeToString(sig)))
+	return nil
+}
+
+func digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// signingString builds the string that a signature over headers covers.
+func signingString(req *http.Request, headers []string) string {
+	lines := make([]string, len(headers))
+	for i, h := range headers {
+		switch h {
+		case "(request-target)":
+			lines[i] = h + ": " + strings.ToLower(req.Method) + " " + req.URL.RequestURI()
+		case "host":
+			lines[i] = h + ": " + req.Host
+		default:
+			lines[i] = h + ": " + strings.Join(req.Header.Values(h), ", ")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseSignature splits a Signature header into its parameters.
+func parseSignature(header string) map[string]string {
+	params := map[string]string{}
+	for _, part := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			params[name] = strings.Trim(value, `"`)
+		}
+	}
+	return params
+}
+
+// verify checks the HTTP signature of an inbox request with body and returns
+// the remote actor that made it.
+func verify(r *http.Request, body []byte) (*remoteActor, error) {
+	params := parseSignature(r.Header.Get("Signature"))
+	keyID, sig64 := params["keyId"], params["signature"]
+	if keyID == "" || sig64 == "" {
+		return nil, ErrInvalidSignature
+	}
+	headers := strings.Fields(params["headers"])
+	if len(headers) == 0 {
+		headers = []string{"date"}
+	}
+	covered := map[string]bool{}
+	for _, h := range headers {
+		covered[strings.ToLower(h)] = true
+	}
+	if !covered["(request-target)"] || !covered["date"] || !covered["digest"] {
+		return nil, fmt.Errorf("%w: must cover (request-target), date and digest", ErrInvalidSignature)
+	}
+	if r.Header.Get("Digest") != digest(body) {
+		return nil, fmt.Errorf("%w: digest does not match body", ErrInvalidSignature)
+	}
+	date, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil || time.Since(date).Abs() > maxClockSkew {
+		return nil, fmt.Errorf("%w: date is missing or too far off", ErrInvalidSignature)
+	}
+	sig, err := base64.StdEncoding.DecodeString(sig64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	actor, err := fetchActor(keyID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: fetching key %s: %v", ErrInvalidSignature, keyID, err)
+	}
+	if actor.PublicKey.ID != keyID {
+		return nil, fmt.Errorf("%w: key %s not found on %s", ErrInvalidSignature, keyID, actor.ID)
+	}
+	pub, err := parsePublicKey(actor.PublicKey.PublicKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("%w: key %s: %v", ErrInvalidSignature, keyID, err)
+	}
+	sum := sha256.Sum256([]byte(signingString(r, headers)))
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig); err != nil {
+		return nil, ErrInvalidSignature
+	}
+	return actor, nil
+}
+
+func parsePublicKey(encoded string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, errInvalidKey
+	}
+	if k, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		if pub, ok := k.(*rsa.PublicKey); ok {
+			return pub, nil
+		}
+		return nil, errInvalidKey
+	}
+	return x509.ParsePKCS1PublicKey(block.Bytes)
+}
+
+// remoteActor holds the fields of a remote actor document that we use.
+type remoteActor struct {
+	ID        string `json:"id"`
+	Inbox     string `json:"inbox"`
+	Endpoints struct {
+		SharedInbox string `json:"sharedInbox"`
+	} `json:"endpoints"`
+	PublicKey struct {
+		ID           string `json:"id"`
+		Owner        string `json:"owner"`
+		PublicKeyPEM string `json:"publicKeyPem"`
+	} `json:"publicKey"`
+}
+
+// deliveryInbox returns where activities for the actor are sent.
+func (a *remoteActor) deliveryInbox() string {
+	if a.Endpoints.SharedInbox != "" {
+		return a.Endpoints.SharedInbox
+	}
+	return a.Inbox
+}
+
+// fetchActor fetches the actor document at id. A key id such as
+// https://example.com/users/a#main-key resolves to its actor. The request
+// is signed, as servers in "authorized fetch" mode require.
+func fetchActor(id string) (*remoteActor, error) {
+	id, _, _ = strings.Cut(id, "#")
+	req, err := http.NewRequest(http.MethodGet, id, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", ContentType+`, application/ld+json; profile="https://www.w3.org/ns/activitystreams"`)
+	req.Header.Set("User-Agent", userAgent)
+	if err := sign(req, nil); err != nil {
+		return nil, err
+	}
+	resp, err := Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching actor: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBody))
+	if err != nil {
+		return nil, err
+	}
+
+	var actor remoteActor
+	if err := json.Unmarshal(body, &actor); err != nil {
+		return nil, err
+	}
+	// The key must belong to the actor that serves it.
+	if actor.ID != id || actor.PublicKey.Owner != "" && actor.PublicKey.Owner != actor.ID {
+		return nil, fmt.Errorf("actor document at %s has id %s", id, actor.ID)
+	}
+	if actor.Inbox == "" {
+		return nil, fmt.Errorf("actor %s has no inbox", actor.ID)
+	}
+	return &actor, nil
+}
@@ -0,0 +1,147 @@
+package audit
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+	"github.com/gg582/chi-blog/blog-backend/utils"
+)
+
+// Outcome values stored in the audit log.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionLogin          = "login"
+	ActionLoginLockout   = "login.lockout"
+	ActionRecoveryCode   = "login.recovery-code"
+	ActionLogout         = "logout"
+	ActionPostCreate     = "post.create"
+	ActionPostUpdate     = "post.update"
+	ActionPostDelete     = "post.delete"
+	ActionUpload         = "upload"
+	ActionCommentApprove = "comment.approve"
+	ActionCommentReject  = "comment.reject"
+	ActionCommentSpam    = "comment.spam"
+)
+
+// Entry is a single row of the audit log.
+type Entry struct {
+	ID        int64     `json:"id"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	Outcome   string    `json:"outcome"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Filter narrows a Query. Zero fields do not filter.
+type Filter struct {
+	Actor   string
+	Action  string // exact action, or a prefix ending in "." such as "post."
+	Target  string
+	Outcome string
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+	Offset  int
+}
+
+// Record writes an entry to the audit log. Failures are logged and otherwise
+// ignored so that auditing never breaks the request being audited.
+func Record(e Entry) {
+	_, err := database.DB.Exec(
+		"INSERT INTO audit_log (actor, action, target, ip, user_agent, outcome, created_at) VALUES (?,?,?,?,?,?,?)",
+		e.Actor, e.Action, e.Target, e.IP, e.UserAgent, e.Outcome, time.Now().Unix(),
+	)
+	if err != nil {
+		log.Printf("Failed to write audit log entry (%s %s): %v", e.Action, e.Target, err)
+	}
+}
+
+// Log records an entry, filling IP and user agent from the request.
+func Log(r *http.Request, actor, action, target, outcome string) {
+	Record(Entry{
+		Actor:     actor,
+		Action:    action,
+		Target:    target,
+		IP:        utils.ClientIP(r),
+		UserAgent: r.UserAgent(),
+		Outcome:   outcome,
+	})
+}
+
+// Query returns the entries matching f, newest first, together with the
+// number of matching entries ignoring Limit and Offset.
+func Query(f Filter) ([]Entry, int, error) {
+	var where []string
+	var args []any
+	if f.Actor != "" {
+		where = append(where, "actor = ?")
+		args = append(args, f.Actor)
+	}
+	if strings.HasSuffix(f.Action, ".") {
+		where = append(where, "action LIKE ?")
+		args = append(args, f.Action+"%")
+	} else if f.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, f.Action)
+	}
+	if f.Target != "" {
+		where = append(where, "target = ?")
+		args = append(args, f.Target)
+	}
+	if f.Outcome != "" {
+		where = append(where, "outcome = ?")
+		args = append(args, f.Outcome)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.Since.Unix())
+	}
+	if !f.Until.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, f.Until.Unix())
+	}
+	clause := ""
+	if len(where) > 0 {
+		clause = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM audit_log"+clause, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT id, actor, action, target, ip, user_agent, outcome, created_at FROM audit_log" + clause + " ORDER BY id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, f.Limit, f.Offset)
+	}
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		var created int64
+		if err := rows.Scan(&e.ID, &e.Actor, &e.Action, &e.Target, &e.IP, &e.UserAgent, &e.Outcome, &created); err != nil {
+			return nil, 0, err
+		}
+		e.CreatedAt = time.Unix(created, 0)
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
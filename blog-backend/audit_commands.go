@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gg582/chi-blog/blog-backend/audit"
+	"github.com/gg582/chi-blog/blog-backend/database"
+)
+
+// newAuditCommand builds the "audit" command tree.
+func newAuditCommand() *cobra.Command {
+	var auditCmd = &cobra.Command{
+		Use:   "audit",
+		Short: "Inspect the security audit log",
+	}
+
+	var output, since, until string
+	var f audit.Filter
+	var export = &cobra.Command{
+		Use:   "export",
+		Short: "Write audit log entries as JSON lines",
+		Long:  `Write audit log entries, newest first, as one JSON object per line to stdout or to --output.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			if since != "" {
+				if f.Since, err = time.Parse(time.RFC3339, since); err != nil {
+					log.Fatalf("Invalid --since, expected RFC 3339: %v", err)
+				}
+			}
+			if until != "" {
+				if f.Until, err = time.Parse(time.RFC3339, until); err != nil {
+					log.Fatalf("Invalid --until, expected RFC 3339: %v", err)
+				}
+			}
+
+			database.InitDatabase()
+			entries, _, err := audit.Query(f)
+			if err != nil {
+				log.Fatalf("Failed to query audit log: %v", err)
+			}
+
+			out := os.Stdout
+			if output != "" && output != "-" {
+				out, err = os.Create(output)
+				if err != nil {
+					log.Fatalf("Failed to create %s: %v", output, err)
+				}
+				defer out.Close()
+			}
+			enc := json.NewEncoder(out)
+			for _, e := range entries {
+				if err := enc.Encode(e); err != nil {
+					log.Fatalf("Failed to write audit entry: %v", err)
+				}
+			}
+			log.Printf("Exported %d audit log entries.", len(entries))
+		},
+	}
+	export.Flags().StringVarP(&output, "output", "o", "-", "file to write, or - for stdout")
+	export.Flags().StringVar(&f.Actor, "actor", "", "only entries by this actor")
+	export.Flags().StringVar(&f.Action, "action", "", `only this action, or a prefix such as "post."`)
+	export.Flags().StringVar(&f.Target, "target", "", "only entries for this slug or file")
+	export.Flags().StringVar(&f.Outcome, "outcome", "", "only this outcome (success, failure, denied)")
+	export.Flags().StringVar(&since, "since", "", "only entries at or after this RFC 3339 time")
+	export.Flags().StringVar(&until, "until", "", "only entries before this RFC 3339 time")
+	export.Flags().IntVar(&f.Limit, "limit", 0, "maximum number of entries (0 for all)")
+
+	auditCmd.AddCommand(export)
+	return auditCmd
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// CSRFHeader is the request header that must echo the session's CSRF token.
+const CSRFHeader = "X-CSRF-Token"
+
+// CSRF enforces the synchronizer token pattern on state-changing requests
+// authenticated by the session cookie. It must run after RequireAuth. The
+// token is issued per session and handed out by the CSRF token endpoint,
+// which cross-origin pages cannot read because of the CORS policy.
+func CSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		p := FromContext(r.Context())
+		if p == nil || p.Session == nil {
+			// Not authenticated by an ambient cookie, nothing to forge.
+			next.ServeHTTP(w, r)
+			return
+		}
+		token := r.Header.Get(CSRFHeader)
+		if token == "" {
+			writeError(w, http.StatusForbidden, "CSRF token is missing. Fetch one from /api/csrf-token and send it in the "+CSRFHeader+" header.", "CSRF_TOKEN_MISSING")
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(p.Session.CSRFToken)) != 1 {
+			writeError(w, http.StatusForbidden, "CSRF token does not match the current session.", "CSRF_TOKEN_MISMATCH")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+)
+
+// setupAuth points the database at a fresh file and fixes the clock.
+func setupAuth(t *testing.T) *time.Time {
+	t.Helper()
+	database.Path = filepath.Join(t.TempDir(), "auth.db")
+	database.InitDatabase()
+	t.Cleanup(func() { database.DB.Close() })
+	clock := time.Unix(1700000000, 0)
+	Now = func() time.Time { return clock }
+	t.Cleanup(func() { Now = time.Now })
+	return &clock
+}
+
+// serve runs r through h and returns the status and error code.
+func serve(h http.Handler, r *http.Request) (int, string) {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var body struct{ Code string }
+	json.NewDecoder(w.Body).Decode(&body)
+	return w.Code, body.Code
+}
+
+// passed answers 204 to requests the middleware lets through.
+var passed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+})
+
+func TestCSRF(t *testing.T) {
+	setupAuth(t)
+	session, s, err := CreateSession("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := CreateToken("admin", "ci", []string{ScopePostsWrite}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := RequireAuth(CSRF(passed))
+
+	tests := []struct {
+		name       string
+		method     string
+		bearer     bool
+		csrf       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"session without token", http.MethodPost, false, "", http.StatusForbidden, "CSRF_TOKEN_MISSING"},
+		{"session with wrong token", http.MethodPost, false, "wrong", http.StatusForbidden, "CSRF_TOKEN_MISMATCH"},
+		{"delete without token", http.MethodDelete, false, "", http.StatusForbidden, "CSRF_TOKEN_MISSING"},
+		{"session with token", http.MethodPost, false, s.CSRFToken, http.StatusNoContent, ""},
+		{"bearer token", http.MethodPost, true, "", http.StatusNoContent, ""},
+		{"GET", http.MethodGet, false, "", http.StatusNoContent, ""},
+		{"HEAD", http.MethodHead, false, "", http.StatusNoContent, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/posts", nil)
+			if tt.bearer {
+				r.Header.Set("Authorization", "Bearer "+token)
+			} else {
+				r.AddCookie(&http.Cookie{Name: SessionCookie, Value: session})
+			}
+			if tt.csrf != "" {
+				r.Header.Set(CSRFHeader, tt.csrf)
+			}
+			if status, code := serve(h, r); status != tt.wantStatus || code != tt.wantCode {
+				t.Errorf("got %d %q, want %d %q", status, code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestExpiredSessionsPurgedOnLogin(t *testing.T) {
+	clock := setupAuth(t)
+	old, _, err := CreateSession("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	*clock = clock.Add(sessionTTL + time.Second)
+	current, _, err := CreateSession("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	database.DB.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&n)
+	if n != 1 {
+		t.Errorf("%d sessions stored, want only the new one", n)
+	}
+	if s, _ := LookupSession(old); s != nil {
+		t.Error("expired session still valid")
+	}
+	if s, _ := LookupSession(current); s == nil {
+		t.Error("new session not found")
+	}
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type contextKey int
+
+const principalKey contextKey = iota
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Username string
+	// Session is set when the caller authenticated with the session cookie.
+	Session *Session
+	// SessionToken is the raw cookie value, needed to end the session.
+	SessionToken string
+	// Token is set when the caller authenticated with an API token.
+	Token *APIToken
+}
+
+// HasScope reports whether the caller may act within scope. Sessions belong
+// to a logged-in admin and hold every scope; API tokens only what they were
+// granted.
+func (p *Principal) HasScope(scope string) bool {
+	if p.Token == nil {
+		return true
+	}
+	for _, s := range p.Token.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// NewContext returns a copy of ctx that carries p as the caller.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// FromContext returns the caller stored by RequireAuth, or nil.
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey).(*Principal)
+	return p
+}
+
+// RequireAuth rejects unauthenticated requests with 401 and stores the caller
+// in the request context for downstream handlers. Callers authenticate either
+// with an "Authorization: Bearer" API token or with the session cookie.
+func RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := authenticate(w, r)
+		if !ok {
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), p)))
+	})
+}
+
+// Optional returns the caller of r when it carries a valid API token or
+// session cookie, and nil otherwise. Unlike RequireAuth it never answers the
+// request, for public endpoints that show more to logged-in callers.
+func Optional(r *http.Request) *Principal {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, _ := strings.Cut(header, " ")
+		if !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return nil
+		}
+		t, err := LookupToken(strings.TrimSpace(token))
+		if err != nil {
+			log.Printf("Error looking up API token: %v", err)
+			return nil
+		}
+		if t == nil {
+			return nil
+		}
+		return &Principal{Username: t.Username, Token: t}
+	}
+
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+	s, err := LookupSession(cookie.Value)
+	if err != nil {
+		log.Printf("Error looking up session: %v", err)
+		return nil
+	}
+	if s == nil {
+		return nil
+	}
+	return &Principal{Username: s.Username, Session: s, SessionToken: cookie.Value}
+}
+
+// RequireSession is like RequireAuth but only accepts the session cookie,
+// for endpoints that make no sense with an API token.
+func RequireSession(next http.Handler) http.Handler {
+	return RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if FromContext(r.Context()).Session == nil {
+			writeError(w, http.StatusBadRequest, "This endpoint requires a browser session, not an API token.", "SESSION_REQUIRED")
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// RequireScope rejects callers lacking scope with 403. It must run after
+// RequireAuth.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if p := FromContext(r.Context()); p == nil || !p.HasScope(scope) {
+				writeError(w, http.StatusForbidden, "This token lacks the "+scope+" scope.", "INSUFFICIENT_SCOPE")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// authenticate resolves the caller of r, answering the request itself when
+// it cannot.
+func authenticate(w http.ResponseWriter, r *http.Request) (*Principal, bool) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, _ := strings.Cut(header, " ")
+		if !strings.EqualFold(scheme, "Bearer") || token == "" {
+			writeError(w, http.StatusUnauthorized, "Authorization header must be a Bearer token.", "UNAUTHENTICATED")
+			return nil, false
+		}
+		t, err := LookupToken(strings.TrimSpace(token))
+		if err != nil {
+			log.Printf("Error looking up API token: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return nil, false
+		}
+		if t == nil {
+			writeError(w, http.StatusUnauthorized, "API token is invalid, expired or revoked.", "INVALID_TOKEN")
+			return nil, false
+		}
+		return &Principal{Username: t.Username, Token: t}, true
+	}
+
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil || cookie.Value == "" {
+		writeError(w, http.StatusUnauthorized, "Authentication required.", "UNAUTHENTICATED")
+		return nil, false
+	}
+	s, err := LookupSession(cookie.Value)
+	if err != nil {
+		log.Printf("Error looking up session: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if s == nil {
+		ClearSessionCookie(w, r)
+		writeError(w, http.StatusUnauthorized, "Session expired, please log in again.", "SESSION_EXPIRED")
+		return nil, false
+	}
+	return &Principal{Username: s.Username, Session: s, SessionToken: cookie.Value}, true
+}
+
+func writeError(w http.ResponseWriter, status int, message, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": message,
+		"code":    code,
+	})
+}
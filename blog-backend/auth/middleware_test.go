@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequireScope(t *testing.T) {
+	clock := setupAuth(t)
+	session, _, err := CreateSession("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploads, _, err := CreateToken("admin", "uploads", []string{ScopeUploadsWrite}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, _, err := CreateToken("admin", "ci", []string{ScopePostsWrite, ScopeReadDrafts}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, revokedToken, err := CreateToken("admin", "old", []string{ScopePostsWrite}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := RevokeToken(revokedToken.ID); !ok || err != nil {
+		t.Fatalf("RevokeToken = %v, %v", ok, err)
+	}
+	expiring, _, err := CreateToken("admin", "short", []string{ScopePostsWrite}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	*clock = clock.Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		bearer     string
+		cookie     string
+		scope      string
+		wantStatus int
+		wantCode   string
+	}{
+		{"token with the scope", writer, "", ScopePostsWrite, http.StatusNoContent, ""},
+		{"token with another scope", writer, "", ScopeReadDrafts, http.StatusNoContent, ""},
+		{"token without the scope", uploads, "", ScopePostsWrite, http.StatusForbidden, "INSUFFICIENT_SCOPE"},
+		{"revoked token", revoked, "", ScopePostsWrite, http.StatusUnauthorized, "INVALID_TOKEN"},
+		{"expired token", expiring, "", ScopePostsWrite, http.StatusUnauthorized, "INVALID_TOKEN"},
+		{"unknown token", "cbt_nope", "", ScopePostsWrite, http.StatusUnauthorized, "INVALID_TOKEN"},
+		{"session, posts", "", session, ScopePostsWrite, http.StatusNoContent, ""},
+		{"session, uploads", "", session, ScopeUploadsWrite, http.StatusNoContent, ""},
+		{"session, drafts", "", session, ScopeReadDrafts, http.StatusNoContent, ""},
+		{"nobody", "", "", ScopePostsWrite, http.StatusUnauthorized, "UNAUTHENTICATED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.bearer != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: SessionCookie, Value: tt.cookie})
+			}
+			h := RequireAuth(RequireScope(tt.scope)(passed))
+			if status, code := serve(h, r); status != tt.wantStatus || code != tt.wantCode {
+				t.Errorf("got %d %q, want %d %q", status, code, tt.wantStatus, tt.wantCode)
+			}
+
+			// Optional agrees with RequireAuth on who the caller is.
+			if p := Optional(r); (p != nil) != (tt.wantStatus != http.StatusUnauthorized) {
+				t.Errorf("Optional = %+v", p)
+			}
+		})
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	clock := setupAuth(t)
+	token, created, err := CreateToken("admin", "short", []string{ScopeReadDrafts}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ExpiresAt == nil || !created.ExpiresAt.Equal(clock.Add(time.Hour)) {
+		t.Fatalf("ExpiresAt = %v, want an hour from now", created.ExpiresAt)
+	}
+	if tok, _ := LookupToken(token); tok == nil {
+		t.Fatal("fresh token rejected")
+	}
+	*clock = clock.Add(time.Hour - time.Second)
+	if tok, _ := LookupToken(token); tok == nil {
+		t.Fatal("token rejected before it expired")
+	}
+	*clock = clock.Add(time.Second)
+	if tok, _ := LookupToken(token); tok != nil {
+		t.Error("expired token accepted")
+	}
+
+	tokens, err := ListTokens()
+	if err != nil || len(tokens) != 1 || !tokens[0].Expired() {
+		t.Errorf("ListTokens = %+v, %v; want the token marked expired", tokens, err)
+	}
+
+	if _, _, err := CreateToken("admin", "bad", []string{"admin"}, 0); err == nil {
+		t.Error("token created with an unknown scope")
+	}
+}
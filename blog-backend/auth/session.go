@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+)
+
+// SessionCookie is the name of the cookie carrying the session token.
+const SessionCookie = "chi_blog_session"
+
+const sessionTTL = 7 * 24 * time.Hour
+
+// Now is the clock used for session expiry. Tests may replace it.
+var Now = time.Now
+
+// Session is a logged-in browser session. Only a hash of the session token is
+// stored; the CSRF token is kept in clear because it is handed back to the
+// client on request.
+type Session struct {
+	Username  string
+	CSRFToken string
+	ExpiresAt time.Time
+}
+
+// CreateSession starts a session for username and returns its token.
+// Sessions that have expired are deleted on the way, so that the table only
+// grows with logins that are still valid.
+func CreateSession(username string) (string, *Session, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", nil, err
+	}
+	csrf, err := randomToken()
+	if err != nil {
+		return "", nil, err
+	}
+	now := Now()
+	if _, err := database.DB.Exec("DELETE FROM sessions WHERE expires_at < ?", now.Unix()); err != nil {
+		return "", nil, err
+	}
+	s := &Session{Username: username, CSRFToken: csrf, ExpiresAt: now.Add(sessionTTL)}
+	_, err = database.DB.Exec(
+		"INSERT INTO sessions (token_hash, username, csrf_token, created_at, expires_at) VALUES (?,?,?,?,?)",
+		hashToken(token), username, csrf, now.Unix(), s.ExpiresAt.Unix(),
+	)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, s, nil
+}
+
+// LookupSession returns the live session for token, or nil when there is none.
+func LookupSession(token string) (*Session, error) {
+	var s Session
+	var expires int64
+	err := database.DB.QueryRow(
+		"SELECT username, csrf_token, expires_at FROM sessions WHERE token_hash = ?", hashToken(token),
+	).Scan(&s.Username, &s.CSRFToken, &expires)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	s.ExpiresAt = time.Unix(expires, 0)
+	if Now().After(s.ExpiresAt) {
+		DeleteSession(token)
+		return nil, nil
+	}
+	return &s, nil
+}
+
+// DeleteSession ends the session identified by token.
+func DeleteSession(token string) error {
+	_, err := database.DB.Exec("DELETE FROM sessions WHERE token_hash = ?", hashToken(token))
+	return err
+}
+
+// SetSessionCookie stores the session token in an HttpOnly cookie.
+func SetSessionCookie(w http.ResponseWriter, r *http.Request, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearSessionCookie tells the browser to drop the session cookie.
+func ClearSessionCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+)
+
+// Scopes an API token can be granted. Browser sessions implicitly hold all.
+const (
+	ScopePostsWrite   = "posts:write"
+	ScopeUploadsWrite = "uploads:write"
+	// ScopeReadDrafts lets a token fetch posts marked "draft: true".
+	ScopeReadDrafts = "read-drafts"
+)
+
+// Scopes lists every valid scope.
+var Scopes = []string{ScopePostsWrite, ScopeUploadsWrite, ScopeReadDrafts}
+
+// tokenPrefix makes API tokens easy to recognise in logs and secret scanners.
+const tokenPrefix = "cbt_"
+
+// APIToken describes a personal API token. The token itself is only shown
+// once, at creation; the database keeps its hash.
+type APIToken struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Username   string     `json:"username"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	// ExpiresAt is nil for tokens that do not expire.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// ValidScope reports whether scope is one of Scopes.
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateToken issues a new API token for username and returns the plain
+// token together with its stored record. The token expires after ttl, or
+// never when ttl is zero.
+func CreateToken(username, name string, scopes []string, ttl time.Duration) (string, *APIToken, error) {
+	for _, s := range scopes {
+		if !ValidScope(s) {
+			return "", nil, fmt.Errorf("unknown scope %q (valid: %s)", s, strings.Join(Scopes, ", "))
+		}
+	}
+	if len(scopes) == 0 {
+		return "", nil, fmt.Errorf("at least one scope is required (valid: %s)", strings.Join(Scopes, ", "))
+	}
+	raw, err := randomToken()
+	if err != nil {
+		return "", nil, err
+	}
+	token := tokenPrefix + raw
+	now := Now()
+	var expiresAt *time.Time
+	var expires sql.NullInt64
+	if ttl > 0 {
+		at := now.Add(ttl)
+		expiresAt, expires = &at, sql.NullInt64{Int64: at.Unix(), Valid: true}
+	}
+	res, err := database.DB.Exec(
+		"INSERT INTO api_tokens (name, username, token_hash, scopes, created_at, expires_at) VALUES (?,?,?,?,?,?)",
+		name, username, hashToken(token), strings.Join(scopes, " "), now.Unix(), expires,
+	)
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &APIToken{ID: id, Name: name, Username: username, Scopes: scopes, CreatedAt: now, ExpiresAt: expiresAt}, nil
+}
+
+// ListTokens returns every token, revoked ones included, newest first.
+func ListTokens() ([]APIToken, error) {
+	rows, err := database.DB.Query("SELECT id, name, username, scopes, created_at, last_used_at, revoked_at, expires_at FROM api_tokens ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeToken disables the token with id. It reports false when no active
+// token has that id.
+func RevokeToken(id int64) (bool, error) {
+	res, err := database.DB.Exec("UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", Now().Unix(), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// LookupToken returns the token matching token, or nil when there is none or
+// it was revoked or has expired, and records that it was used.
+func LookupToken(token string) (*APIToken, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return nil, nil
+	}
+	row := database.DB.QueryRow(
+		"SELECT id, name, username, scopes, created_at, last_used_at, revoked_at, expires_at FROM api_tokens WHERE token_hash = ?",
+		hashToken(token),
+	)
+	t, err := scanToken(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if t.RevokedAt != nil || t.Expired() {
+		return nil, nil
+	}
+	if _, err := database.DB.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", Now().Unix(), t.ID); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Expired reports whether t is past its expiry time.
+func (t *APIToken) Expired() bool {
+	return t.ExpiresAt != nil && !Now().Before(*t.ExpiresAt)
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanToken(s scanner) (*APIToken, error) {
+	var t APIToken
+	var scopes string
+	var created int64
+	var lastUsed, revoked, expires sql.NullInt64
+	if err := s.Scan(&t.ID, &t.Name, &t.Username, &scopes, &created, &lastUsed, &revoked, &expires); err != nil {
+		return nil, err
+	}
+	t.Scopes = strings.Fields(scopes)
+	t.CreatedAt = time.Unix(created, 0)
+	if lastUsed.Valid {
+		at := time.Unix(lastUsed.Int64, 0)
+		t.LastUsedAt = &at
+	}
+	if revoked.Valid {
+		at := time.Unix(revoked.Int64, 0)
+		t.RevokedAt = &at
+	}
+	if expires.Valid {
+		at := time.Unix(expires.Int64, 0)
+		t.ExpiresAt = &at
+	}
+	return &t, nil
+}
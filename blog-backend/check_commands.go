@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gg582/chi-blog/blog-backend/content"
+	"github.com/gg582/chi-blog/blog-backend/utils"
+)
+
+// newCheckCommand builds the "check" command, which validates posts for CI.
+func newCheckCommand() *cobra.Command {
+	var opts content.CheckOptions
+	var format string
+	var strict bool
+	var checkCmd = &cobra.Command{
+		Use:   "check",
+		Short: "Report broken links, missing or unused assets, duplicate titles and undated posts",
+		Long: `Parse every post and page and report references to missing assets, links to
+posts or anchors that do not exist, posts that fail to render, unused files
+under the assets directory, posts sharing a title and posts without a date.
+
+Exits with status 1 when an error is found, or with --strict any issue.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if format != "text" && format != "json" {
+				log.Fatalf("Invalid --format %q, expected text or json", format)
+			}
+			issues, err := content.Check(opts)
+			if err != nil {
+				log.Fatalf("Failed to check posts: %v", err)
+			}
+
+			var errors, warnings int
+			for _, issue := range issues {
+				if issue.Severity == content.SeverityError {
+					errors++
+				} else {
+					warnings++
+				}
+			}
+
+			if format == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				err = enc.Encode(map[string]any{
+					"issues":   append([]content.Issue{}, issues...),
+					"errors":   errors,
+					"warnings": warnings,
+				})
+				if err != nil {
+					log.Fatalf("Failed to write report: %v", err)
+				}
+			} else {
+				for _, issue := range issues {
+					location := issue.File
+					if issue.Line > 0 {
+						location = fmt.Sprintf("%s:%d", issue.File, issue.Line)
+					}
+					fmt.Printf("%s: %s: %s: %s\n", location, issue.Severity, issue.Kind, issue.Message)
+				}
+				fmt.Printf("%d errors, %d warnings\n", errors, warnings)
+			}
+
+			if errors > 0 || strict && warnings > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+	checkCmd.Flags().StringVar(&opts.PostsDir, "posts", content.PostsDir, "directory with the posts")
+	checkCmd.Flags().StringVar(&opts.PagesDir, "pages", "./pages", "directory with the pages, empty to skip")
+	checkCmd.Flags().StringVar(&opts.AssetsDir, "assets", "", "directory with the assets (default <posts>/assets)")
+	checkCmd.Flags().StringSliceVar(&opts.SiteHosts, "site-host", []string{"chatter.pw", "localhost"}, "host names of absolute links to this blog")
+	checkCmd.Flags().StringVar(&format, "format", "text", "output format, text or json")
+	checkCmd.Flags().BoolVar(&strict, "strict", false, "also fail on warnings")
+	return checkCmd
+}
+
+// newBackfillDatesCommand builds the "backfill-dates" command, which writes a
+// "date" front matter key into every post that lacks one.
+func newBackfillDatesCommand() *cobra.Command {
+	var postsDir string
+	var dryRun bool
+	var backfillCmd = &cobra.Command{
+		Use:   "backfill-dates",
+		Short: "Add a date to posts without one, from their first git commit",
+		Long: `Give every post without a "date" front matter key the date of the git commit
+that added it, or its modification time when it is not tracked by git.
+Without a date, a post moves whenever its file is copied or touched.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			files, err := filepath.Glob(filepath.Join(postsDir, "*.md"))
+			if err != nil {
+				log.Fatalf("Failed to list posts: %v", err)
+			}
+			dates := make([]time.Time, len(files))
+			hashes := make([]string, len(files))
+			for i, file := range files {
+				dates[i], hashes[i] = firstCommit(file)
+			}
+			// A history that was squashed or imported in one commit dates
+			// every post to that commit, which is worse than no date.
+			if hash := sameCommit(hashes); hash != "" {
+				log.Fatalf("Every post was added by commit %s, so its date says nothing about when they were published. Set the dates by hand or run this in a clone with the full history.", hash[:7])
+			}
+			for i, file := range files {
+				source, err := os.ReadFile(file)
+				if err != nil {
+					log.Fatalf("Failed to read %s: %v", file, err)
+				}
+				meta, cleanedContent := utils.ParseFrontMatter(source)
+				if meta["date"] != "" {
+					continue
+				}
+				date, from := dates[i], "commit "+shortHash(hashes[i])
+				if date.IsZero() {
+					info, err := os.Stat(file)
+					if err != nil {
+						log.Fatalf("Failed to stat %s: %v", file, err)
+					}
+					date, from = info.ModTime(), "modification time"
+				}
+				meta["date"] = date.Format(time.RFC3339)
+				fmt.Printf("%s: %s (%s)\n", filepath.Base(file), meta["date"], from)
+				if dryRun {
+					continue
+				}
+				if err := os.WriteFile(file, []byte(meta.Encode()+"\n"+string(cleanedContent)), 0644); err != nil {
+					log.Fatalf("Failed to write %s: %v", file, err)
+				}
+			}
+		},
+	}
+	backfillCmd.Flags().StringVar(&postsDir, "posts", content.PostsDir, "directory with the posts")
+	backfillCmd.Flags().BoolVar(&dryRun, "dry-run", false, "only print the dates")
+	return backfillCmd
+}
+
+// firstCommit returns the author date and hash of the commit that added
+// file, following renames. Both are zero when git does not know the file.
+func firstCommit(file string) (time.Time, string) {
+	out, err := exec.Command("git", "log", "--diff-filter=A", "--follow", "--format=%H %aI", "--", file).Output()
+	if err != nil {
+		return time.Time{}, ""
+	}
+	lines := strings.Fields(strings.TrimSpace(string(out)))
+	if len(lines) < 2 {
+		return time.Time{}, ""
+	}
+	// Newest first; the last commit is the one that added the file.
+	hash, date := lines[len(lines)-2], lines[len(lines)-1]
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return time.Time{}, ""
+	}
+	return t, hash
+}
+
+// sameCommit returns the commit that added all of several files, or "" when
+// they came from different commits or some are not tracked.
+func sameCommit(hashes []string) string {
+	if len(hashes) < 2 {
+		return ""
+	}
+	for _, h := range hashes {
+		if h == "" || h != hashes[0] {
+			return ""
+		}
+	}
+	return hashes[0]
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
@@ -0,0 +1,270 @@
+// Package comments stores reader comments on posts. New comments wait in a
+// moderation queue and are only shown once an admin approves them.
+package comments
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+	"github.com/gg582/chi-blog/blog-backend/render"
+)
+
+// Moderation states of a comment.
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+	StatusSpam     = "spam"
+)
+
+// MaxDepth is how deeply comments nest: a top-level comment has depth 1,
+// a reply to it depth 2, and so on.
+const MaxDepth = 3
+
+// Now returns the current time. Tests may replace it.
+var Now = time.Now
+
+var (
+	// ErrNotFound is returned for an unknown comment id.
+	ErrNotFound = errors.New("comment not found")
+	// ErrParentNotFound is returned when a reply's parent is not an approved
+	// comment on the same post.
+	ErrParentNotFound = errors.New("parent comment not found")
+	// ErrTooDeep is returned for a reply to a comment at MaxDepth.
+	ErrTooDeep = errors.New("replies are nested too deeply")
+)
+
+// Comment is an approved comment as shown to readers.
+type Comment struct {
+	ID        int64      `json:"id"`
+	PostID    string     `json:"postId"`
+	ParentID  int64      `json:"parentId,omitempty"`
+	Author    string     `json:"author"`
+	BodyHTML  string     `json:"bodyHtml"`
+	CreatedAt time.Time  `json:"createdAt"`
+	Replies   []*Comment `json:"replies,omitempty"`
+}
+
+// Moderation is a comment as listed in the moderation queue.
+type Moderation struct {
+	Comment
+	Body      string `json:"body"`
+	EmailHash string `json:"emailHash,omitempty"`
+	Status    string `json:"status"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"userAgent"`
+}
+
+// New is a comment submitted by a reader.
+type New struct {
+	PostID    string
+	ParentID  int64 // 0 for a top-level comment
+	Author    string
+	Email     string // optional, only its hash is stored
+	Body      string // markdown
+	IP        string
+	UserAgent string
+}
+
+// HashEmail returns the SHA-256 of the normalized address, the form in which
+// email addresses are stored. It is also what Gravatar accepts.
+func HashEmail(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create renders and stores a new comment in the moderation queue and
+// returns its id.
+func Create(n New) (int64, error) {
+	if n.ParentID != 0 {
+		var postID string
+		err := database.DB.QueryRow("SELECT post_id FROM comments WHERE id = ? AND status = ?", n.ParentID, StatusApproved).Scan(&postID)
+		if err == sql.ErrNoRows || err == nil && postID != n.PostID {
+			return 0, ErrParentNotFound
+		} else if err != nil {
+			return 0, err
+		}
+		depth, err := depthOf(n.ParentID)
+		if err != nil {
+			return 0, err
+		} else if depth >= MaxDepth {
+			return 0, ErrTooDeep
+		}
+	}
+	html, err := render.Comment([]byte(n.Body))
+	if err != nil {
+		return 0, err
+	}
+	var emailHash string
+	if n.Email != "" {
+		emailHash = HashEmail(n.Email)
+	}
+	var parent any
+	if n.ParentID != 0 {
+		parent = n.ParentID
+	}
+
+	res, err := database.DB.Exec(
+		`INSERT INTO comments (post_id, parent_id, author, email_hash, body, body_html, status, ip, user_agent, created_at)
+		VALUES (?,?,?,?,?,?,?,?,?,?)`,
+		n.PostID, parent, n.Author, emailHash, n.Body, string(html), StatusPending, n.IP, n.UserAgent, Now().Unix(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// depthOf returns the depth of the comment with id, counting at most up to
+// MaxDepth.
+func depthOf(id int64) (int, error) {
+	depth := 1
+	for ; depth < MaxDepth; depth++ {
+		var parent sql.NullInt64
+		if err := database.DB.QueryRow("SELECT parent_id FROM comments WHERE id = ?", id).Scan(&parent); err != nil {
+			return 0, err
+		}
+		if !parent.Valid {
+			break
+		}
+		id = parent.Int64
+	}
+	return depth, nil
+}
+
+// Thread returns the approved comments on a post as a tree, oldest first,
+// and their number. Replies whose parent is no longer approved are left out
+// with it.
+func Thread(postID string) ([]*Comment, int, error) {
+	rows, err := database.DB.Query(
+		"SELECT id, post_id, parent_id, author, body_html, created_at FROM comments WHERE post_id = ? AND status = ? ORDER BY id",
+		postID, StatusApproved,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	roots := []*Comment{}
+	byID := map[int64]*Comment{}
+	count := 0
+	for rows.Next() {
+		c := &Comment{}
+		var parent sql.NullInt64
+		var created int64
+		if err := rows.Scan(&c.ID, &c.PostID, &parent, &c.Author, &c.BodyHTML, &created); err != nil {
+			return nil, 0, err
+		}
+		c.ParentID = parent.Int64
+		c.CreatedAt = time.Unix(created, 0)
+		// Ids increase, so a parent is always seen before its replies.
+		if c.ParentID == 0 {
+			roots = append(roots, c)
+		} else if p, ok := byID[c.ParentID]; ok {
+			p.Replies = append(p.Replies, c)
+		} else {
+			continue
+		}
+		byID[c.ID] = c
+		count++
+	}
+	return roots, count, rows.Err()
+}
+
+// Queue lists comments with the given status, newest first, and the total
+// number of comments with that status.
+func Queue(status string, limit, offset int) ([]Moderation, int, error) {
+	var total int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM comments WHERE status = ?", status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := database.DB.Query(
+		`SELECT id, post_id, parent_id, author, body, body_html, email_hash, status, ip, user_agent, created_at
+		FROM comments WHERE status = ? ORDER BY id DESC LIMIT ? OFFSET ?`,
+		status, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	queue := []Moderation{}
+	for rows.Next() {
+		var m Moderation
+		var parent sql.NullInt64
+		var created int64
+		if err := rows.Scan(&m.ID, &m.PostID, &parent, &m.Author, &m.Body, &m.BodyHTML, &m.EmailHash, &m.Status, &m.IP, &m.UserAgent, &created); err != nil {
+			return nil, 0, err
+		}
+		m.ParentID = parent.Int64
+		m.CreatedAt = time.Unix(created, 0)
+		queue = append(queue, m)
+	}
+	return queue, total, rows.Err()
+}
+
+// ValidStatus reports whether status is a moderation state.
+func ValidStatus(status string) bool {
+	switch status {
+	case StatusPending, StatusApproved, StatusRejected, StatusSpam:
+		return true
+	}
+	return false
+}
+
+// SetStatus moves a comment to status on behalf of moderator.
+func SetStatus(id int64, status, moderator string) error {
+	res, err := database.DB.Exec(
+		"UPDATE comments SET status = ?, moderated_by = ?, moderated_at = ? WHERE id = ?",
+		status, moderator, Now().Unix(), id,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// visible selects the approved comments whose ancestors are all approved,
+// the ones Thread shows.
+const visible = `WITH RECURSIVE visible(id, post_id) AS (
+	SELECT id, post_id FROM comments WHERE parent_id IS NULL AND status = ?
+	UNION ALL
+	SELECT c.id, c.post_id FROM comments c JOIN visible v ON c.parent_id = v.id WHERE c.status = ?
+)`
+
+// Count returns the number of comments Thread shows on a post.
+func Count(postID string) (int, error) {
+	var n int
+	err := database.DB.QueryRow(visible+" SELECT COUNT(*) FROM visible WHERE post_id = ?", StatusApproved, StatusApproved, postID).Scan(&n)
+	return n, err
+}
+
+// Counts returns the number of comments Thread shows per post id.
+func Counts() (map[string]int, error) {
+	rows, err := database.DB.Query(visible+" SELECT post_id, COUNT(*) FROM visible GROUP BY post_id", StatusApproved, StatusApproved)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var postID string
+		var n int
+		if err := rows.Scan(&postID, &n); err != nil {
+			return nil, err
+		}
+		counts[postID] = n
+	}
+	return counts, rows.Err()
+}
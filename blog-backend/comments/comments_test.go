@@ -0,0 +1,114 @@
+package comments
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+)
+
+func setupComments(t *testing.T) {
+	t.Helper()
+	database.Path = filepath.Join(t.TempDir(), "auth.db")
+	database.InitDatabase()
+	t.Cleanup(func() { database.DB.Close() })
+}
+
+// create stores a comment on post and moves it to status.
+func create(t *testing.T, post string, parent int64, status string) int64 {
+	t.Helper()
+	id, err := Create(New{PostID: post, ParentID: parent, Author: "Reader", Body: "Hello"})
+	if err != nil {
+		t.Fatalf("Create(parent %d): %v", parent, err)
+	}
+	if status != StatusPending {
+		if err := SetStatus(id, status, "admin"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return id
+}
+
+func TestCreateParent(t *testing.T) {
+	setupComments(t)
+	approved := create(t, "post", 0, StatusApproved)
+	pending := create(t, "post", 0, StatusPending)
+	rejected := create(t, "post", 0, StatusRejected)
+	other := create(t, "other", 0, StatusApproved)
+
+	tests := []struct {
+		name   string
+		parent int64
+		want   error
+	}{
+		{"approved parent", approved, nil},
+		{"unknown parent", 9999, ErrParentNotFound},
+		{"pending parent", pending, ErrParentNotFound},
+		{"rejected parent", rejected, ErrParentNotFound},
+		{"parent on another post", other, ErrParentNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Create(New{PostID: "post", ParentID: tt.parent, Author: "Reader", Body: "Reply"})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Create error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateDepth(t *testing.T) {
+	setupComments(t)
+	id := create(t, "post", 0, StatusApproved)
+	for depth := 2; depth <= MaxDepth; depth++ {
+		id = create(t, "post", id, StatusApproved)
+	}
+	if _, err := Create(New{PostID: "post", ParentID: id, Author: "Reader", Body: "Too deep"}); !errors.Is(err, ErrTooDeep) {
+		t.Errorf("reply at depth %d: %v, want ErrTooDeep", MaxDepth+1, err)
+	}
+
+	_, count, err := Thread("post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != MaxDepth {
+		t.Errorf("Thread count = %d, want %d", count, MaxDepth)
+	}
+}
+
+func TestThreadHidesUnapproved(t *testing.T) {
+	setupComments(t)
+	root := create(t, "post", 0, StatusApproved)
+	reply := create(t, "post", root, StatusApproved)
+	create(t, "post", root, StatusPending)
+	create(t, "post", root, StatusRejected)
+	create(t, "post", 0, StatusSpam)
+	create(t, "other", 0, StatusApproved)
+
+	thread, count, err := Thread("post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 || len(thread) != 1 || thread[0].ID != root ||
+		len(thread[0].Replies) != 1 || thread[0].Replies[0].ID != reply {
+		t.Fatalf("Thread = %d comments %+v, want %d with the single reply %d", count, thread, root, reply)
+	}
+	if n, err := Count("post"); err != nil || n != 2 {
+		t.Errorf("Count = %d, %v, want 2", n, err)
+	}
+
+	// Rejecting the root hides its replies with it.
+	if err := SetStatus(root, StatusRejected, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if thread, count, err := Thread("post"); err != nil || count != 0 || len(thread) != 0 {
+		t.Errorf("Thread after rejecting the root = %+v, %d, %v, want none", thread, count, err)
+	}
+	if n, err := Count("post"); err != nil || n != 0 {
+		t.Errorf("Count after rejecting the root = %d, %v, want 0", n, err)
+	}
+	if counts, err := Counts(); err != nil || counts["post"] != 0 || counts["other"] != 1 {
+		t.Errorf("Counts = %v, %v, want only other 1", counts, err)
+	}
+}
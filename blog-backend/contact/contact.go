@@ -0,0 +1,119 @@
+// Package contact stores messages sent through the contact form and
+// forwards them to the site owner through the configured notifiers.
+package contact
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+)
+
+// Inbox states of a message.
+const (
+	StatusUnread = "unread"
+	StatusRead   = "read"
+)
+
+// Now returns the current time. Tests may replace it.
+var Now = time.Now
+
+// ErrNotFound is returned for an unknown message id.
+var ErrNotFound = errors.New("message not found")
+
+// Message is a message from the contact form.
+type Message struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Subject   string     `json:"subject"`
+	Body      string     `json:"body"`
+	Status    string     `json:"status"`
+	IP        string     `json:"ip"`
+	UserAgent string     `json:"userAgent"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ReadAt    *time.Time `json:"readAt,omitempty"`
+}
+
+// Create stores msg as an unread message, hands it to the notifiers and
+// returns its id. msg.ID, Status and CreatedAt are filled in.
+func Create(msg *Message) (int64, error) {
+	msg.Status = StatusUnread
+	msg.CreatedAt = Now()
+	res, err := database.DB.Exec(
+		`INSERT INTO contact_messages (name, email, subject, body, status, ip, user_agent, created_at)
+		VALUES (?,?,?,?,?,?,?,?)`,
+		msg.Name, msg.Email, msg.Subject, msg.Body, msg.Status, msg.IP, msg.UserAgent, msg.CreatedAt.Unix(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	if msg.ID, err = res.LastInsertId(); err != nil {
+		return 0, err
+	}
+	notify(*msg)
+	return msg.ID, nil
+}
+
+// List returns messages with status, or all messages when it is empty,
+// newest first, along with the total number of them.
+func List(status string, limit, offset int) ([]Message, int, error) {
+	where, args := "", []any{}
+	if status != "" {
+		where, args = " WHERE status = ?", append(args, status)
+	}
+	var total int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM contact_messages"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := database.DB.Query(
+		`SELECT id, name, email, subject, body, status, ip, user_agent, created_at, read_at
+		FROM contact_messages`+where+` ORDER BY id DESC LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		var m Message
+		var created int64
+		var read sql.NullInt64
+		if err := rows.Scan(&m.ID, &m.Name, &m.Email, &m.Subject, &m.Body, &m.Status, &m.IP, &m.UserAgent, &created, &read); err != nil {
+			return nil, 0, err
+		}
+		m.CreatedAt = time.Unix(created, 0)
+		if read.Valid {
+			t := time.Unix(read.Int64, 0)
+			m.ReadAt = &t
+		}
+		messages = append(messages, m)
+	}
+	return messages, total, rows.Err()
+}
+
+// ValidStatus reports whether status is an inbox state.
+func ValidStatus(status string) bool {
+	return status == StatusUnread || status == StatusRead
+}
+
+// SetStatus marks a message as read or unread.
+func SetStatus(id int64, status string) error {
+	var readAt any
+	if status == StatusRead {
+		readAt = Now().Unix()
+	}
+	res, err := database.DB.Exec("UPDATE contact_messages SET status = ?, read_at = ? WHERE id = ?", status, readAt, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
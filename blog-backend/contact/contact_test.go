@@ -0,0 +1,110 @@
+package contact
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+	"github.com/gg582/chi-blog/blog-backend/mail"
+)
+
+// setupContact points the database at a fresh file and freezes the clock.
+func setupContact(t *testing.T) *time.Time {
+	t.Helper()
+	database.Path = filepath.Join(t.TempDir(), "auth.db")
+	database.InitDatabase()
+	t.Cleanup(func() { database.DB.Close() })
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	Now = func() time.Time { return now }
+	t.Cleanup(func() { Now = time.Now })
+	return &now
+}
+
+func TestFormToken(t *testing.T) {
+	now := setupContact(t)
+	token := FormToken()
+	*now = now.Add(90 * time.Second)
+	if age, err := FormAge(token); err != nil || age != 90*time.Second {
+		t.Errorf("FormAge = %s, %v, want 1m30s", age, err)
+	}
+
+	issued, _, _ := strings.Cut(token, ".")
+	earlier := strings.Replace(token, issued, "0"+issued[1:], 1)
+	for _, bad := range []string{"", "nope", issued, issued + ".", earlier, token + "0", "x." + signForm("x")} {
+		if _, err := FormAge(bad); !errors.Is(err, ErrInvalidFormToken) {
+			t.Errorf("FormAge(%q) error = %v, want ErrInvalidFormToken", bad, err)
+		}
+	}
+}
+
+func TestInbox(t *testing.T) {
+	setupContact(t)
+	var ids []int64
+	for _, name := range []string{"Ann", "Bob", "Cid"} {
+		id, err := Create(&Message{Name: name, Body: "Hi"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := SetStatus(ids[1], StatusRead); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetStatus(9999, StatusRead); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetStatus of an unknown id: %v, want ErrNotFound", err)
+	}
+
+	tests := []struct {
+		status string
+		limit  int
+		names  []string
+		total  int
+	}{
+		{"", 10, []string{"Cid", "Bob", "Ann"}, 3},
+		{"", 1, []string{"Cid"}, 3},
+		{StatusUnread, 10, []string{"Cid", "Ann"}, 2},
+		{StatusRead, 10, []string{"Bob"}, 1},
+	}
+	for _, tt := range tests {
+		messages, total, err := List(tt.status, tt.limit, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, m := range messages {
+			names = append(names, m.Name)
+			if (m.Status == StatusRead) != (m.ReadAt != nil) {
+				t.Errorf("%s: status %s with readAt %v", m.Name, m.Status, m.ReadAt)
+			}
+		}
+		if strings.Join(names, " ") != strings.Join(tt.names, " ") || total != tt.total {
+			t.Errorf("List(%q, %d) = %v of %d, want %v of %d", tt.status, tt.limit, names, total, tt.names, tt.total)
+		}
+	}
+}
+
+func TestMailNotifier(t *testing.T) {
+	setupContact(t)
+	dir := t.TempDir()
+	n := MailNotifier{Mailer: mail.FileMailer{Dir: dir, From: "blog@example.com"}, To: "owner@example.com"}
+	if err := n.Notify(Message{Name: "Ann", Email: "ann@example.com", Body: "Hello there"}); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("mails = %v, %v, want one", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"To: owner@example.com", "Reply-To: ann@example.com", "Message from Ann", "Hello there"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("mail does not contain %q:\n%s", want, data)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package contact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidFormToken is returned for a form token this server did not
+// issue.
+var ErrInvalidFormToken = errors.New("invalid form token")
+
+// formKey signs form tokens. It is made at startup, so a form shown before
+// a restart has to be reloaded.
+var formKey = func() []byte {
+	k := make([]byte, 32)
+	rand.Read(k)
+	return k
+}()
+
+// FormToken returns a token recording when the contact form was shown. The
+// form sends it back with the message, so the server can tell how long the
+// form was open without trusting the client's clock.
+func FormToken() string {
+	issued := strconv.FormatInt(Now().UnixMilli(), 10)
+	return issued + "." + signForm(issued)
+}
+
+// FormAge returns how long ago token was issued by FormToken.
+func FormAge(token string) (time.Duration, error) {
+	issued, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signForm(issued))) {
+		return 0, ErrInvalidFormToken
+	}
+	ms, err := strconv.ParseInt(issued, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidFormToken
+	}
+	return Now().Sub(time.UnixMilli(ms)), nil
+}
+
+func signForm(issued string) string {
+	mac := hmac.New(sha256.New, formKey)
+	mac.Write([]byte(issued))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,92 @@
+package contact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/mail"
+	"github.com/gg582/chi-blog/blog-backend/site"
+)
+
+// Notifier forwards a new message to the site owner.
+type Notifier interface {
+	Notify(msg Message) error
+}
+
+// Notifiers are told about every new message. The server sets them from
+// the environment; with none, messages are only kept in the inbox.
+var Notifiers []Notifier
+
+// notify hands msg to every notifier in the background. A failing notifier
+// is logged only, as the message is already stored.
+func notify(msg Message) {
+	for _, n := range Notifiers {
+		go func(n Notifier) {
+			if err := n.Notify(msg); err != nil {
+				log.Printf("Failed to forward contact message %d: %v", msg.ID, err)
+			}
+		}(n)
+	}
+}
+
+// MailNotifier mails messages to To. Replies go to the sender when they
+// left an address.
+type MailNotifier struct {
+	Mailer mail.Mailer
+	To     string
+}
+
+// Notify implements Notifier.
+func (n MailNotifier) Notify(msg Message) error {
+	subject := msg.Subject
+	if subject == "" {
+		subject = "Message from " + msg.Name
+	}
+	headers := map[string]string{}
+	if msg.Email != "" {
+		headers["Reply-To"] = msg.Email
+	}
+	return n.Mailer.Send(mail.Message{
+		To:      n.To,
+		Subject: "[" + site.Host() + " contact] " + subject,
+		Body: fmt.Sprintf("From: %s <%s>\nSent: %s\n\n%s\n",
+			msg.Name, msg.Email, msg.CreatedAt.Format(time.RFC1123Z), msg.Body),
+		Headers: headers,
+	})
+}
+
+// WebhookNotifier posts messages as JSON to URL, for chat integrations and
+// the like. The URL is set by the admin, so it may point to the local
+// network.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// Notify implements Notifier.
+func (n WebhookNotifier) Notify(msg Message) error {
+	body, err := json.Marshal(map[string]any{
+		"event":   "contact.message",
+		"message": msg,
+	})
+	if err != nil {
+		return err
+	}
+	client := n.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Post(n.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package content
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/models"
+)
+
+// Archive counts the posts per year and month, newest first. Drafts are
+// left out. Dates are taken in the time zone they were written with.
+func Archive() ([]models.ArchiveYear, error) {
+	idx, err := currentIndex()
+	if err != nil {
+		return nil, err
+	}
+	counts := map[int]map[int]int{}
+	for _, p := range idx.posts {
+		y, m := p.CreatedAt.Year(), int(p.CreatedAt.Month())
+		if counts[y] == nil {
+			counts[y] = map[int]int{}
+		}
+		counts[y][m]++
+	}
+
+	years := []models.ArchiveYear{}
+	for y, months := range counts {
+		year := models.ArchiveYear{Year: y, Months: []models.ArchiveMonth{}}
+		for m, n := range months {
+			year.Months = append(year.Months, models.ArchiveMonth{Month: m, Count: n})
+			year.Count += n
+		}
+		sort.Slice(year.Months, func(i, j int) bool { return year.Months[i].Month > year.Months[j].Month })
+		years = append(years, year)
+	}
+	sort.Slice(years, func(i, j int) bool { return years[i].Year > years[j].Year })
+	return years, nil
+}
+
+// PostsInMonth returns the posts published in month of year, newest first.
+// Drafts are left out.
+func PostsInMonth(year int, month time.Month) ([]models.Post, error) {
+	idx, err := currentIndex()
+	if err != nil {
+		return nil, err
+	}
+	posts := []models.Post{}
+	for i := len(idx.posts) - 1; i >= 0; i-- {
+		if t := idx.posts[i].CreatedAt; t.Year() == year && t.Month() == month {
+			posts = append(posts, idx.posts[i])
+		}
+	}
+	return posts, nil
+}
@@ -0,0 +1,339 @@
+package content
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/gg582/chi-blog/blog-backend/render"
+	"github.com/gg582/chi-blog/blog-backend/shortcode"
+	"github.com/gg582/chi-blog/blog-backend/utils"
+)
+
+// Kinds of problems reported by Check.
+const (
+	IssueMissingAsset   = "missing-asset"
+	IssueDeadLink       = "dead-link"
+	IssueUnusedAsset    = "unused-asset"
+	IssueDuplicateTitle = "duplicate-title"
+	IssueInvalidPost    = "invalid-post"
+	IssueMissingDate    = "missing-date"
+)
+
+// Issue severities. Errors break a post for readers, warnings are clean-up.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Issue is a single problem found by Check.
+type Issue struct {
+	Kind     string `json:"kind"`
+	Severity string `json:"severity"`
+	// File is the post or asset the issue is about, relative to the
+	// directory that was checked.
+	File string `json:"file"`
+	// Line is the 1-based line of the reference, when it could be found.
+	Line    int    `json:"line,omitempty"`
+	Target  string `json:"target,omitempty"`
+	Message string `json:"message"`
+}
+
+// CheckOptions configure Check.
+type CheckOptions struct {
+	PostsDir string
+	// PagesDir is also scanned for references; its titles may repeat those
+	// of posts. Leave it empty to skip pages.
+	PagesDir string
+	// AssetsDir defaults to the assets directory inside PostsDir.
+	AssetsDir string
+	// SiteHosts are the host names, without port, that absolute links to
+	// this blog use, such as https://chatter.pw:8080/assets/Tux.png.
+	SiteHosts []string
+}
+
+// checkedFile is a post or page prepared for checking.
+type checkedFile struct {
+	name   string
+	source []byte
+	html   []byte
+	draft  bool
+}
+
+// Check parses every post and page and reports missing assets, dead links
+// to posts or to anchors, unused assets, posts sharing a title and posts
+// without a valid date. Issues are sorted by file and line.
+func Check(opts CheckOptions) ([]Issue, error) {
+	if opts.AssetsDir == "" {
+		opts.AssetsDir = filepath.Join(opts.PostsDir, "assets")
+	}
+	c := &checker{opts: opts}
+
+	posts, err := c.load(opts.PostsDir, "")
+	if err != nil {
+		return nil, err
+	}
+	var pages []checkedFile
+	if opts.PagesDir != "" {
+		if pages, err = c.load(opts.PagesDir, filepath.Base(opts.PagesDir)); err != nil {
+			return nil, err
+		}
+	}
+
+	used := map[string]bool{}
+	for _, f := range append(posts, pages...) {
+		c.checkReferences(f, used)
+	}
+	if err := c.checkUnusedAssets(used); err != nil {
+		return nil, err
+	}
+	c.checkDuplicateTitles(posts)
+	c.checkDates(posts)
+
+	sort.SliceStable(c.issues, func(i, j int) bool {
+		a, b := c.issues[i], c.issues[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return c.issues, nil
+}
+
+type checker struct {
+	opts   CheckOptions
+	issues []Issue
+}
+
+func (c *checker) report(i Issue) {
+	c.issues = append(c.issues, i)
+}
+
+// load reads and renders the markdown files in dir. Files that do not render
+// are reported and kept without HTML. prefix is prepended to reported file
+// names.
+func (c *checker) load(dir, prefix string) ([]checkedFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading directory '%s': %w", dir, err)
+	}
+	var files []checkedFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".md") {
+			continue
+		}
+		name := path.Join(prefix, entry.Name())
+		source, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		meta, cleanedContent := utils.ParseFrontMatter(source)
+		// Links to missing posts are let through here, so that they are
+		// reported as dead links together with all other links.
+		expanded, err := shortcode.Expand(cleanedContent, func(slug string) (string, bool) {
+			if title, ok := PostTitle(slug); ok {
+				return title, true
+			}
+			return slug, true
+		})
+		f := checkedFile{name: name, source: source, draft: meta.Bool("draft")}
+		if err == nil {
+			var rendered *render.Result
+			if rendered, err = render.Markdown.Render(expanded, render.Options{Trusted: true}); err == nil {
+				f.html = rendered.HTML
+			}
+		}
+		if err != nil {
+			c.report(Issue{
+				Kind:     IssueInvalidPost,
+				Severity: SeverityError,
+				File:     name,
+				Line:     errorLine(err, source, cleanedContent),
+				Message:  err.Error(),
+			})
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// checkReferences reports links and embeds in f that point to missing
+// assets, posts or anchors, and marks the assets it uses.
+func (c *checker) checkReferences(f checkedFile, used map[string]bool) {
+	refs, ids := htmlReferences(f.html)
+	for _, ref := range refs {
+		u, err := url.Parse(ref)
+		if err != nil {
+			continue
+		}
+		if u.Host != "" && !c.siteHost(u.Hostname()) || u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+			continue
+		}
+		line := lineOf(f.source, ref)
+		switch {
+		case u.Path == "" && u.Fragment != "":
+			if !ids[u.Fragment] {
+				c.report(Issue{IssueDeadLink, SeverityError, f.name, line, ref, fmt.Sprintf("no heading or element with id %q", u.Fragment)})
+			}
+		case strings.HasPrefix(u.Path, "/assets/"):
+			asset := strings.TrimPrefix(u.Path, "/assets/")
+			used[asset] = true
+			if _, err := os.Stat(filepath.Join(c.opts.AssetsDir, filepath.FromSlash(asset))); err != nil {
+				c.report(Issue{IssueMissingAsset, SeverityError, f.name, line, ref, fmt.Sprintf("asset %q does not exist", asset)})
+			}
+		case strings.HasPrefix(u.Path, "/posts/"):
+			slug := strings.TrimSuffix(strings.TrimPrefix(u.Path, "/posts/"), "/")
+			switch exists, draft := c.post(slug); {
+			case !exists:
+				c.report(Issue{IssueDeadLink, SeverityError, f.name, line, ref, fmt.Sprintf("post %q does not exist", slug)})
+			case draft && !f.draft:
+				// Drafts may link each other ahead of publishing.
+				c.report(Issue{IssueDeadLink, SeverityError, f.name, line, ref, fmt.Sprintf("post %q is a draft, readers get a 404", slug)})
+			}
+		}
+	}
+}
+
+// post reports whether the post slug exists and whether it is a draft.
+func (c *checker) post(slug string) (exists, draft bool) {
+	if !validSlug(slug) {
+		return false, false
+	}
+	source, err := os.ReadFile(filepath.Join(c.opts.PostsDir, slug+".md"))
+	if err != nil {
+		return false, false
+	}
+	meta, _ := utils.ParseFrontMatter(source)
+	return true, meta.Bool("draft")
+}
+
+func (c *checker) siteHost(host string) bool {
+	for _, h := range c.opts.SiteHosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkUnusedAssets reports files in the assets directory that no post or
+// page refers to.
+func (c *checker) checkUnusedAssets(used map[string]bool) error {
+	err := filepath.WalkDir(c.opts.AssetsDir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(c.opts.AssetsDir, p)
+		if err != nil {
+			return err
+		}
+		if asset := filepath.ToSlash(rel); !used[asset] {
+			c.report(Issue{IssueUnusedAsset, SeverityWarning, path.Join("assets", asset), 0, "", "asset is not used by any post or page"})
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// checkDuplicateTitles reports posts whose titles differ only in case or
+// surrounding space.
+func (c *checker) checkDuplicateTitles(posts []checkedFile) {
+	byTitle := map[string][]string{}
+	var titles []string
+	for _, f := range posts {
+		_, cleanedContent := utils.ParseFrontMatter(f.source)
+		title := strings.ToLower(strings.TrimSpace(titleOf(cleanedContent, strings.TrimSuffix(f.name, ".md"))))
+		if byTitle[title] == nil {
+			titles = append(titles, title)
+		}
+		byTitle[title] = append(byTitle[title], f.name)
+	}
+	for _, title := range titles {
+		names := byTitle[title]
+		if len(names) < 2 {
+			continue
+		}
+		for _, name := range names {
+			c.report(Issue{IssueDuplicateTitle, SeverityWarning, name, 0, "", fmt.Sprintf("title %q is shared by %s", title, strings.Join(names, ", "))})
+		}
+	}
+}
+
+// checkDates reports posts whose "date" front matter key is missing or
+// cannot be parsed. Those fall back to the file's modification time, so
+// they move whenever the file is copied or touched.
+func (c *checker) checkDates(posts []checkedFile) {
+	for _, f := range posts {
+		meta, _ := utils.ParseFrontMatter(f.source)
+		if meta["date"] == "" {
+			c.report(Issue{IssueMissingDate, SeverityWarning, f.name, 0, "", "no date in the front matter, the modification time is used (see backfill-dates)"})
+		} else if _, ok := meta.Time("date"); !ok {
+			c.report(Issue{IssueMissingDate, SeverityWarning, f.name, lineOf(f.source, meta["date"]), meta["date"], fmt.Sprintf("date %q is not in a known format", meta["date"])})
+		}
+	}
+}
+
+// htmlReferences returns the link and embed URLs in rendered HTML, and the
+// ids of its elements.
+func htmlReferences(doc []byte) (refs []string, ids map[string]bool) {
+	ids = map[string]bool{}
+	z := html.NewTokenizer(bytes.NewReader(doc))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return refs, ids
+		case html.StartTagToken, html.SelfClosingTagToken:
+			for _, attr := range z.Token().Attr {
+				switch attr.Key {
+				case "href", "src", "poster":
+					refs = append(refs, attr.Val)
+				case "srcset":
+					for _, candidate := range strings.Split(attr.Val, ",") {
+						if fields := strings.Fields(candidate); len(fields) > 0 {
+							refs = append(refs, fields[0])
+						}
+					}
+				case "id":
+					ids[attr.Val] = true
+				}
+			}
+		}
+	}
+}
+
+// lineOf returns the 1-based line of the first occurrence of ref in source,
+// also trying its unescaped form, or 0 if it does not occur.
+func lineOf(source []byte, ref string) int {
+	candidates := []string{ref}
+	if s, err := url.PathUnescape(ref); err == nil && s != ref {
+		candidates = append(candidates, s)
+	}
+	for _, s := range candidates {
+		if i := bytes.Index(source, []byte(s)); i >= 0 {
+			return bytes.Count(source[:i], []byte("\n")) + 1
+		}
+	}
+	return 0
+}
+
+// errorLine returns the line in source that a shortcode error refers to,
+// or 0 for other errors. Shortcode lines count from the end of the front
+// matter.
+func errorLine(err error, source, cleanedContent []byte) int {
+	var se *shortcode.Error
+	if !errors.As(err, &se) {
+		return 0
+	}
+	return bytes.Count(source[:len(source)-len(cleanedContent)], []byte("\n")) + se.Line
+}
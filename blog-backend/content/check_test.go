@@ -0,0 +1,59 @@
+package content
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	issues, err := Check(CheckOptions{
+		PostsDir:  "testdata/check/posts",
+		PagesDir:  "testdata/check/pages",
+		SiteHosts: []string{"chatter.pw"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type issue struct {
+		Kind, File string
+		Line       int
+		Target     string
+	}
+	want := []issue{
+		{IssueUnusedAsset, "assets/unused.png", 0, ""},
+		{IssueInvalidPost, "broken.md", 10, ""},
+		{IssueMissingAsset, "links.md", 8, "/assets/missing.png"},
+		{IssueDeadLink, "links.md", 10, "/posts/gone"},
+		// Once as a markdown link and once from the post shortcode.
+		{IssueDeadLink, "links.md", 11, "/posts/upcoming"},
+		{IssueDeadLink, "links.md", 11, "/posts/upcoming"},
+		{IssueDeadLink, "links.md", 13, "#nowhere"},
+		{IssueMissingAsset, "links.md", 18, "https://chatter.pw/assets/absolute.png"},
+		{IssueDuplicateTitle, "other.md", 0, ""},
+		{IssueDuplicateTitle, "same-title.md", 0, ""},
+		{IssueMissingDate, "same-title.md", 2, "someday"},
+	}
+	var got []issue
+	for _, i := range issues {
+		got = append(got, issue{i.Kind, i.File, i.Line, i.Target})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Check found\n%v\nwant\n%v", got, want)
+	}
+
+	messages := map[string]string{}
+	for _, i := range issues {
+		messages[i.File+" "+i.Target] = i.Message
+	}
+	for key, want := range map[string]string{
+		"broken.md ":               `invalid video id "short"`,
+		"links.md /posts/upcoming": "is a draft",
+		"links.md /posts/gone":     "does not exist",
+	} {
+		if !strings.Contains(messages[key], want) {
+			t.Errorf("message for %s = %q, want it to mention %q", key, messages[key], want)
+		}
+	}
+}
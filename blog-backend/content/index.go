@@ -0,0 +1,179 @@
+package content
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gg582/chi-blog/blog-backend/models"
+)
+
+// index holds what is computed from all posts together: their order, the
+// related posts of each and the series. It is never modified once built.
+type index struct {
+	posts   []models.Post  // Oldest first
+	byID    map[string]int // Position in posts
+	related map[string][]models.RelatedPost
+	series  map[string]*series // By series id
+}
+
+// postIndex caches the index of PostsDir. It is rebuilt when a post file is
+// added, removed or changed.
+var postIndex struct {
+	sync.Mutex
+	signature string
+	idx       *index
+}
+
+// currentIndex returns the index of PostsDir, rebuilding it if needed.
+func currentIndex() (*index, error) {
+	signature, err := dirSignature(PostsDir)
+	if err != nil {
+		return nil, err
+	}
+	postIndex.Lock()
+	defer postIndex.Unlock()
+	if postIndex.idx == nil || postIndex.signature != signature {
+		idx, err := buildIndex(PostsDir)
+		if err != nil {
+			return nil, err
+		}
+		postIndex.signature, postIndex.idx = signature, idx
+	}
+	return postIndex.idx, nil
+}
+
+// dirSignature describes the names, sizes and modification times of the
+// posts in dir, so that any change to them changes it.
+func dirSignature(dir string) (string, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("error reading directory '%s': %w", dir, err)
+	}
+	var b strings.Builder
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".md") {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue // Removed since it was listed.
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", file.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// buildIndex loads every post in dir except drafts.
+func buildIndex(dir string) (*index, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading directory '%s': %w", dir, err)
+	}
+	idx := &index{byID: map[string]int{}, series: map[string]*series{}}
+	var texts []string
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".md") {
+			continue
+		}
+		post, text, err := loadPost(filepath.Join(dir, file.Name()), strings.TrimSuffix(file.Name(), ".md"))
+		if err != nil || post.Draft {
+			continue // Errors are reported when the post itself is loaded.
+		}
+		idx.posts = append(idx.posts, *post)
+		texts = append(texts, text)
+	}
+
+	order := make([]int, len(idx.posts))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return olderThan(&idx.posts[order[i]], &idx.posts[order[j]])
+	})
+	posts, sortedTexts := make([]models.Post, len(order)), make([]string, len(order))
+	for i, o := range order {
+		posts[i], sortedTexts[i] = idx.posts[o], texts[o]
+	}
+	idx.posts = posts
+	for i, p := range idx.posts {
+		idx.byID[p.ID] = i
+	}
+
+	idx.related = buildRelated(idx.posts, sortedTexts)
+	idx.series = buildSeries(idx.posts)
+	return idx, nil
+}
+
+// Posts returns the published posts, newest first. Drafts are left out.
+// The posts come from the cached index, so they are only rendered again
+// after a post file changes.
+func Posts() ([]models.Post, error) {
+	idx, err := currentIndex()
+	if err != nil {
+		return nil, err
+	}
+	posts := make([]models.Post, len(idx.posts))
+	for i, p := range idx.posts {
+		posts[len(posts)-1-i] = p
+	}
+	return posts, nil
+}
+
+// olderThan orders posts by date, and by id for posts of the same time.
+func olderThan(a, b *models.Post) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// AddNavigation fills in the related posts of post, where it stands in its
+// series and the posts published right before and after it.
+func AddNavigation(post *models.Post) error {
+	idx, err := currentIndex()
+	if err != nil {
+		return err
+	}
+	post.Related = idx.related[post.ID]
+
+	i, ok := idx.byID[post.ID]
+	if !ok {
+		return nil
+	}
+	if i > 0 {
+		post.Previous = linkTo(&idx.posts[i-1])
+	}
+	if i+1 < len(idx.posts) {
+		post.Next = linkTo(&idx.posts[i+1])
+	}
+
+	if post.Series == nil {
+		return nil
+	}
+	s := idx.series[post.Series.ID]
+	if s == nil {
+		return nil
+	}
+	post.Series.Total = len(s.posts)
+	for j, p := range s.posts {
+		if p.ID != post.ID {
+			continue
+		}
+		post.Series.Part = j + 1
+		if j > 0 {
+			post.Series.Previous = linkTo(&s.posts[j-1])
+		}
+		if j+1 < len(s.posts) {
+			post.Series.Next = linkTo(&s.posts[j+1])
+		}
+	}
+	return nil
+}
+
+func linkTo(p *models.Post) *models.PostLink {
+	return &models.PostLink{ID: p.ID, Title: p.Title}
+}
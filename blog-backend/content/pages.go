@@ -0,0 +1,72 @@
+package content
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gg582/chi-blog/blog-backend/models"
+	"github.com/gg582/chi-blog/blog-backend/utils"
+)
+
+// defaultMenuOrder places pages without a menu_order after ordered ones.
+const defaultMenuOrder = 1000
+
+// LoadPage loads the page slug from pagesDir. Pages are plain markdown files
+// like posts; their front matter may also set nav_title and menu_order.
+func LoadPage(pagesDir, slug string) (*models.Post, error) {
+	if !validSlug(slug) {
+		return nil, os.ErrNotExist
+	}
+	return LoadPost(filepath.Join(pagesDir, slug+".md"), slug)
+}
+
+// GetNavigation lists the pages in pagesDir for the site menu, ordered by
+// menu_order and then by title. Pages with "nav: false" are left out.
+func GetNavigation(pagesDir string) ([]models.NavEntry, error) {
+	files, err := os.ReadDir(pagesDir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading directory '%s': %w", pagesDir, err)
+	}
+
+	nav := []models.NavEntry{}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".md") {
+			continue
+		}
+		filePath := filepath.Join(pagesDir, file.Name())
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			log.Printf("error reading file: %s - %v", filePath, err)
+			continue
+		}
+
+		meta, cleanedContent := utils.ParseFrontMatter(content)
+		if meta["nav"] != "" && !meta.Bool("nav") {
+			continue
+		}
+		id := strings.TrimSuffix(file.Name(), ".md")
+		title := titleOf(cleanedContent, id)
+		navTitle := meta["nav_title"]
+		if navTitle == "" {
+			navTitle = title
+		}
+		nav = append(nav, models.NavEntry{
+			ID:        id,
+			Title:     title,
+			NavTitle:  navTitle,
+			MenuOrder: meta.Int("menu_order", defaultMenuOrder),
+		})
+	}
+
+	sort.SliceStable(nav, func(i, j int) bool {
+		if nav[i].MenuOrder != nav[j].MenuOrder {
+			return nav[i].MenuOrder < nav[j].MenuOrder
+		}
+		return nav[i].NavTitle < nav[j].NavTitle
+	})
+	return nav, nil
+}
@@ -0,0 +1,110 @@
+package content
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gg582/chi-blog/blog-backend/models"
+	"github.com/gg582/chi-blog/blog-backend/render"
+	"github.com/gg582/chi-blog/blog-backend/shortcode"
+	"github.com/gg582/chi-blog/blog-backend/utils"
+)
+
+// PostsDir is the directory posts are served from.
+const PostsDir = "./posts"
+
+// LoadPost reads and renders the markdown file at path. id is the slug the
+// post is served under. Errors from reading the file are returned unwrapped
+// so callers can check os.IsNotExist.
+func LoadPost(path, id string) (*models.Post, error) {
+	post, _, err := loadPost(path, id)
+	return post, err
+}
+
+// loadPost is LoadPost that also returns the plain text of the post.
+func loadPost(path, id string) (*models.Post, string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	meta, cleanedContent := utils.ParseFrontMatter(content)
+	expanded, err := shortcode.ExpandLenient(cleanedContent, PostTitle)
+	if err != nil {
+		return nil, "", fmt.Errorf("error expanding shortcodes in %s: %w", path, err)
+	}
+	rendered, err := render.Markdown.Render(expanded, render.Options{Trusted: meta.Bool("trusted")})
+	if err != nil {
+		return nil, "", fmt.Errorf("error rendering %s: %w", path, err)
+	}
+
+	fileInfo, err := os.Stat(path) // Get file information for modification time
+	if err != nil {
+		return nil, "", err
+	}
+	// The "date" front matter key keeps the date stable when the file is
+	// copied or edited; the modification time is only a fallback.
+	createdAt, ok := meta.Time("date")
+	if !ok {
+		createdAt = fileInfo.ModTime()
+	}
+
+	words, cjkChars := textStats(rendered.Text)
+
+	return &models.Post{
+		ID:          id,
+		Title:       titleOf(cleanedContent, id),
+		ContentHTML: string(rendered.HTML),
+		Author:      meta.Author(),
+		CreatedAt:   createdAt,
+		FileName:    filepath.Base(path),
+		TOC:         rendered.TOC,
+		Summary:     excerptOf(meta, rendered),
+		WordCount:   words + cjkChars,
+		ReadingTime: readingMinutes(words, cjkChars),
+		Tags:        tagsOf(meta),
+		Series:      seriesOf(meta),
+		Draft:       meta.Bool("draft"),
+	}, rendered.Text, nil
+}
+
+// PostTitle returns the title of the post slug in PostsDir and whether it
+// is published. {{< post >}} shortcodes, comments and webmentions are checked
+// against it, so drafts count as missing.
+func PostTitle(slug string) (string, bool) {
+	if !validSlug(slug) {
+		return "", false
+	}
+	content, err := os.ReadFile(filepath.Join(PostsDir, slug+".md"))
+	if err != nil {
+		return "", false
+	}
+	meta, cleanedContent := utils.ParseFrontMatter(content)
+	if meta.Bool("draft") {
+		return "", false
+	}
+	return titleOf(cleanedContent, slug), true
+}
+
+// validSlug reports whether slug names a file directly inside a content
+// directory.
+func validSlug(slug string) bool {
+	return slug != "" && slug == filepath.Base(slug) && !strings.HasPrefix(slug, ".")
+}
+
+// titleOf returns the first non-empty line of the markdown, without a leading
+// "#", or def when there is none.
+func titleOf(markdown []byte, def string) string {
+	for _, line := range strings.Split(string(markdown), "\n") {
+		trimmedLine := strings.TrimSpace(line)
+		if trimmedLine != "" {
+			if strings.HasPrefix(trimmedLine, "#") {
+				return strings.TrimSpace(strings.TrimPrefix(trimmedLine, "#"))
+			}
+			return trimmedLine
+		}
+	}
+	return def
+}
@@ -0,0 +1,182 @@
+package content
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gg582/chi-blog/blog-backend/models"
+	"github.com/gg582/chi-blog/blog-backend/utils"
+)
+
+// MaxRelated is the number of related posts returned for a post.
+const MaxRelated = 3
+
+// Weights of the related post score: the cosine similarity of the TF-IDF
+// vectors of two posts plus tagWeight times the share of their tags in
+// common. Titles count as titleWeight occurrences of their terms. Posts
+// scoring below minRelatedScore are not related at all.
+const (
+	tagWeight       = 0.5
+	titleWeight     = 3
+	minRelatedScore = 0.05
+)
+
+// indexedPost is a post reduced to what similarity is computed from.
+type indexedPost struct {
+	post   models.RelatedPost
+	tags   []string
+	vector map[string]float64 // TF-IDF weight of each term, unit length
+}
+
+// buildRelated ranks the other posts by similarity for each of posts.
+// texts holds the plain text of each post.
+func buildRelated(posts []models.Post, texts []string) map[string][]models.RelatedPost {
+	indexed := make([]*indexedPost, len(posts))
+	counts := make([]map[string]int, len(posts))
+	df := map[string]int{}
+	for i, post := range posts {
+		tf := map[string]int{}
+		for _, term := range terms(texts[i]) {
+			tf[term]++
+		}
+		for _, term := range terms(post.Title) {
+			tf[term] += titleWeight
+		}
+		for term := range tf {
+			df[term]++
+		}
+		indexed[i] = &indexedPost{
+			post: models.RelatedPost{ID: post.ID, Title: post.Title, Summary: post.Summary},
+			tags: post.Tags,
+		}
+		counts[i] = tf
+	}
+
+	// Terms found in every post say nothing about any of them and get an
+	// idf of 0.
+	for i, p := range indexed {
+		p.vector = map[string]float64{}
+		var norm float64
+		for term, n := range counts[i] {
+			w := (1 + math.Log(float64(n))) * math.Log(float64(len(indexed))/float64(df[term]))
+			if w > 0 {
+				p.vector[term] = w
+				norm += w * w
+			}
+		}
+		if norm > 0 {
+			norm = math.Sqrt(norm)
+			for term := range p.vector {
+				p.vector[term] /= norm
+			}
+		}
+	}
+
+	related := make(map[string][]models.RelatedPost, len(indexed))
+	type scored struct {
+		post  models.RelatedPost
+		score float64
+	}
+	for _, p := range indexed {
+		var candidates []scored
+		for _, q := range indexed {
+			if p == q {
+				continue
+			}
+			if score := similarity(p, q); score >= minRelatedScore {
+				candidates = append(candidates, scored{q.post, score})
+			}
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			if candidates[i].score != candidates[j].score {
+				return candidates[i].score > candidates[j].score
+			}
+			return candidates[i].post.ID < candidates[j].post.ID
+		})
+		for i := 0; i < len(candidates) && i < MaxRelated; i++ {
+			related[p.post.ID] = append(related[p.post.ID], candidates[i].post)
+		}
+	}
+	return related
+}
+
+// similarity scores how related p and q are.
+func similarity(p, q *indexedPost) float64 {
+	var cosine float64
+	a, b := p.vector, q.vector
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	for term, w := range a {
+		cosine += w * b[term]
+	}
+
+	var shared int
+	for _, tag := range p.tags {
+		if slices.Contains(q.tags, tag) {
+			shared++
+		}
+	}
+	var tagScore float64
+	if union := len(p.tags) + len(q.tags) - shared; union > 0 {
+		tagScore = float64(shared) / float64(union)
+	}
+	return cosine + tagWeight*tagScore
+}
+
+// terms splits text into the terms posts are compared by. Latin and other
+// space-separated words are lower-cased, and single characters are
+// dropped. CJK text has no reliable word boundaries, so it is split into
+// overlapping character bigrams, which match across particles and
+// compounds alike.
+func terms(text string) []string {
+	var out []string
+	var word, cjk []rune
+	flushWord := func() {
+		if len(word) > 1 {
+			out = append(out, strings.ToLower(string(word)))
+		}
+		word = word[:0]
+	}
+	flushCJK := func() {
+		if len(cjk) == 1 {
+			out = append(out, string(cjk))
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			out = append(out, string(cjk[i:i+2]))
+		}
+		cjk = cjk[:0]
+	}
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return out
+}
+
+// tagsOf returns the post's "tags" front matter key, lower-cased and
+// without duplicates.
+func tagsOf(meta utils.FrontMatter) []string {
+	tags := []string{}
+	for _, tag := range meta.List("tags") {
+		tag = strings.ToLower(tag)
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,95 @@
+package content
+
+import (
+	"sort"
+
+	"github.com/gg582/chi-blog/blog-backend/models"
+	"github.com/gg582/chi-blog/blog-backend/utils"
+)
+
+// defaultSeriesOrder places posts without a series_order after ordered
+// ones, by date.
+const defaultSeriesOrder = 1000
+
+// seriesOf reads the "series" and "series_order" front matter keys, or
+// returns nil for a post that is not part of a series.
+func seriesOf(meta utils.FrontMatter) *models.SeriesNav {
+	title := meta["series"]
+	if title == "" {
+		return nil
+	}
+	return &models.SeriesNav{
+		ID:    utils.GenerateSlug(title),
+		Title: title,
+		Order: meta.Int("series_order", defaultSeriesOrder),
+	}
+}
+
+// series is a series in the index, with the full posts for navigation.
+type series struct {
+	id, title string
+	posts     []models.Post // Reading order
+}
+
+// buildSeries groups posts, which are oldest first, into their series in
+// reading order: by series_order, then by date.
+func buildSeries(posts []models.Post) map[string]*series {
+	all := map[string]*series{}
+	for _, p := range posts {
+		if p.Series == nil {
+			continue
+		}
+		s := all[p.Series.ID]
+		if s == nil {
+			// The oldest post names the series.
+			s = &series{id: p.Series.ID, title: p.Series.Title}
+			all[s.id] = s
+		}
+		s.posts = append(s.posts, p)
+	}
+	for _, s := range all {
+		sort.SliceStable(s.posts, func(i, j int) bool {
+			return s.posts[i].Series.Order < s.posts[j].Series.Order
+		})
+	}
+	return all
+}
+
+// GetSeries returns the series id with its posts in reading order, or nil
+// if there is no such series.
+func GetSeries(id string) (*models.Series, error) {
+	idx, err := currentIndex()
+	if err != nil {
+		return nil, err
+	}
+	s := idx.series[id]
+	if s == nil {
+		return nil, nil
+	}
+	out := &models.Series{ID: s.id, Title: s.title, Posts: make([]models.SeriesPart, len(s.posts))}
+	for i, p := range s.posts {
+		out.Posts[i] = models.SeriesPart{
+			ID:           p.ID,
+			Title:        p.Title,
+			Summary:      p.Summary,
+			CreatedAt:    p.CreatedAt,
+			ReadingTime:  p.ReadingTime,
+			CommentCount: p.CommentCount,
+		}
+	}
+	return out, nil
+}
+
+// ListSeries lists every series, sorted by title.
+func ListSeries() ([]models.SeriesEntry, error) {
+	idx, err := currentIndex()
+	if err != nil {
+		return nil, err
+	}
+	entries := []models.SeriesEntry{}
+	for _, s := range idx.series {
+		entries = append(entries, models.SeriesEntry{ID: s.id, Title: s.title, PostCount: len(s.posts)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Title < entries[j].Title })
+	return entries, nil
+}
@@ -0,0 +1,85 @@
+package content
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gg582/chi-blog/blog-backend/render"
+	"github.com/gg582/chi-blog/blog-backend/utils"
+)
+
+// Reading speeds used for the reading time estimate. CJK text is read
+// character by character, so it is measured in characters, not words.
+const (
+	wordsPerMinute    = 200
+	cjkCharsPerMinute = 500
+	// excerptLength is the maximum excerpt length in characters.
+	excerptLength = 200
+)
+
+// textStats counts the words of text. Each CJK character (Han, Hiragana,
+// Katakana, Hangul) counts on its own; other text is split into words on
+// anything that is not a letter or digit.
+func textStats(text string) (words, cjkChars int) {
+	inWord := false
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			cjkChars++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if !inWord {
+				words++
+				inWord = true
+			}
+		case r == '\'' || r == '’':
+			// Keep contractions such as "isn't" as one word.
+		default:
+			inWord = false
+		}
+	}
+	return words, cjkChars
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// readingMinutes estimates the reading time, rounded up to whole minutes.
+func readingMinutes(words, cjkChars int) int {
+	minutes := float64(words)/wordsPerMinute + float64(cjkChars)/cjkCharsPerMinute
+	return max(1, int(math.Ceil(minutes)))
+}
+
+// excerptOf picks the post summary: the "summary" front matter key, else the
+// first paragraph, else the beginning of the text. Markup is stripped and
+// the result is cut to excerptLength characters.
+func excerptOf(meta utils.FrontMatter, rendered *render.Result) string {
+	if summary := meta["summary"]; summary != "" {
+		return truncate(render.PlainText([]byte(summary)), excerptLength)
+	}
+	if rendered.FirstParagraph != "" {
+		return truncate(rendered.FirstParagraph, excerptLength)
+	}
+	return truncate(strings.Join(strings.Fields(rendered.Text), " "), excerptLength)
+}
+
+// truncate cuts s to at most n characters, preferring a word boundary, and
+// marks the cut with an ellipsis.
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	cut := n
+	for i := n; i > n*3/4; i-- {
+		if unicode.IsSpace(runes[i]) {
+			cut = i
+			break
+		}
+	}
+	return strings.TrimSpace(string(runes[:cut])) + "…"
+}
@@ -0,0 +1,139 @@
+package content
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTextStats(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		words    int
+		cjkChars int
+	}{
+		{"english", "It isn't hard, is it?", 5, 0},
+		{"curly apostrophe", "Don’t panic", 2, 0},
+		{"numbers and punctuation", "Go 1.24 ships in 2025-02.", 7, 0},
+		{"korean", "리눅스 데스크톱", 0, 7},
+		{"japanese", "日本語のテキスト", 0, 8},
+		{"mixed", "Go 언어로 Flink 만들기", 2, 6},
+		{"cjk between latin", "a한b", 2, 1},
+		{"code", "func main() { fmt.Println(\"hi\") }", 5, 0},
+		{"empty", " \n\t", 0, 0},
+	}
+	for _, tt := range tests {
+		words, cjk := textStats(tt.text)
+		if words != tt.words || cjk != tt.cjkChars {
+			t.Errorf("%s: textStats(%q) = %d words, %d CJK; want %d, %d", tt.name, tt.text, words, cjk, tt.words, tt.cjkChars)
+		}
+	}
+}
+
+func TestReadingMinutes(t *testing.T) {
+	tests := []struct{ words, cjkChars, want int }{
+		{0, 0, 1},
+		{wordsPerMinute, 0, 1},
+		{wordsPerMinute + 1, 0, 2},
+		{0, cjkCharsPerMinute, 1},
+		{0, cjkCharsPerMinute + 1, 2},
+		{wordsPerMinute / 2, cjkCharsPerMinute / 2, 1},
+		{wordsPerMinute / 2, cjkCharsPerMinute * 3 / 5, 2},
+		{10 * wordsPerMinute, 0, 10},
+	}
+	for _, tt := range tests {
+		if got := readingMinutes(tt.words, tt.cjkChars); got != tt.want {
+			t.Errorf("readingMinutes(%d, %d) = %d, want %d", tt.words, tt.cjkChars, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"  spread \n out  ", 20, "spread out"},
+		{"exactly ten", 11, "exactly ten"},
+		{"one two three four five", 15, "one two three…"},
+		// Only the last quarter is searched for a space; else cut mid-word.
+		{"one two three four five", 12, "one two thre…"},
+		{"abcdefghijklmnop", 8, "abcdefgh…"},
+		{"가나다라마바사아자차", 5, "가나다라마…"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.n); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestLoadPostStats(t *testing.T) {
+	long := strings.Repeat("word ", 300)
+	tests := []struct {
+		name        string
+		source      string
+		summary     string
+		wordCount   int
+		readingTime int
+	}{
+		{
+			name:        "english with code",
+			source:      "# Title\n\nFirst paragraph *with* [markup](/x).\n\n```go\nfmt.Println(\"hi\")\n```\n\nMore text.\n",
+			summary:     "First paragraph with markup.",
+			wordCount:   10, // The title, the paragraphs and the three words of code.
+			readingTime: 1,
+		},
+		{
+			name:        "korean",
+			source:      "# 제목\n\n리눅스 데스크톱에서 입력기 문제.\n",
+			summary:     "리눅스 데스크톱에서 입력기 문제.",
+			wordCount:   16,
+			readingTime: 1,
+		},
+		{
+			name:        "summary override",
+			source:      "---\nsummary: A **short** summary with `code`\n---\n# Title\n\nIgnored first paragraph.\n",
+			summary:     "A short summary with code",
+			wordCount:   4,
+			readingTime: 1,
+		},
+		{
+			name:        "no paragraph",
+			source:      "# Title\n\n- one\n- two\n",
+			summary:     "Title one two",
+			wordCount:   3,
+			readingTime: 1,
+		},
+		{
+			name:        "long",
+			source:      "# Title\n\n" + long + "\n",
+			summary:     strings.TrimSpace(strings.Repeat("word ", 40)) + "…",
+			wordCount:   301,
+			readingTime: 2,
+		},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "post.md")
+			if err := os.WriteFile(path, []byte(tt.source), 0644); err != nil {
+				t.Fatal(err)
+			}
+			post, err := LoadPost(path, "post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if post.Summary != tt.summary {
+				t.Errorf("Summary = %q, want %q", post.Summary, tt.summary)
+			}
+			if post.WordCount != tt.wordCount || post.ReadingTime != tt.readingTime {
+				t.Errorf("WordCount, ReadingTime = %d, %d; want %d, %d", post.WordCount, post.ReadingTime, tt.wordCount, tt.readingTime)
+			}
+		})
+	}
+}
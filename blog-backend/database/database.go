@@ -8,20 +8,208 @@ import (
 
 var DB *sql.DB
 
+// Path is the SQLite database file. Tests point it at a temporary file.
+var Path = "/opt/chi-blog/blog-backend/auth.db"
+
+// schema lists every table the blog needs. Each statement must be idempotent
+// because it runs on every start.
+var schema = []struct {
+    name string
+    stmt string
+}{
+    {"users", `CREATE TABLE IF NOT EXISTS blog_users (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        username TEXT NOT NULL UNIQUE,
+        password_hash TEXT NOT NULL
+    );`},
+    // login_attempts keeps failed login counters per IP and per username so
+    // that backoff and lockouts survive a restart.
+    {"login attempts", `CREATE TABLE IF NOT EXISTS login_attempts (
+        key TEXT PRIMARY KEY,
+        failures INTEGER NOT NULL DEFAULT 0,
+        last_failure INTEGER NOT NULL DEFAULT 0,
+        blocked_until INTEGER NOT NULL DEFAULT 0
+    );`},
+    // user_totp holds the TOTP secret of users who enabled two-factor login.
+    // last_step is the newest time step accepted, to stop code replays.
+    {"two-factor", `CREATE TABLE IF NOT EXISTS user_totp (
+        username TEXT PRIMARY KEY,
+        secret TEXT NOT NULL,
+        last_step INTEGER NOT NULL DEFAULT 0
+    );`},
+    {"recovery codes", `CREATE TABLE IF NOT EXISTS recovery_codes (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        username TEXT NOT NULL,
+        code_hash TEXT NOT NULL UNIQUE
+    );`},
+    // sessions only stores a hash of the cookie token. csrf_token is the
+    // synchronizer token that write requests must echo back.
+    {"sessions", `CREATE TABLE IF NOT EXISTS sessions (
+        token_hash TEXT PRIMARY KEY,
+        username TEXT NOT NULL,
+        csrf_token TEXT NOT NULL,
+        created_at INTEGER NOT NULL,
+        expires_at INTEGER NOT NULL
+    );`},
+    // api_tokens are personal tokens for scripted publishing. scopes is a
+    // space separated list.
+    {"api tokens", `CREATE TABLE IF NOT EXISTS api_tokens (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        name TEXT NOT NULL,
+        username TEXT NOT NULL,
+        token_hash TEXT NOT NULL UNIQUE,
+        scopes TEXT NOT NULL,
+        created_at INTEGER NOT NULL,
+        last_used_at INTEGER,
+        revoked_at INTEGER,
+        expires_at INTEGER
+    );`},
+    // comments are reader comments on posts. parent_id links a reply to
+    // the comment it answers. Only a hash of the optional email is kept.
+    {"comments", `CREATE TABLE IF NOT EXISTS comments (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        post_id TEXT NOT NULL,
+        parent_id INTEGER REFERENCES comments (id),
+        author TEXT NOT NULL,
+        email_hash TEXT NOT NULL DEFAULT '',
+        body TEXT NOT NULL,
+        body_html TEXT NOT NULL,
+        status TEXT NOT NULL DEFAULT 'pending',
+        ip TEXT NOT NULL DEFAULT '',
+        user_agent TEXT NOT NULL DEFAULT '',
+        created_at INTEGER NOT NULL,
+        moderated_by TEXT NOT NULL DEFAULT '',
+        moderated_at INTEGER
+    );`},
+    {"comments index", `CREATE INDEX IF NOT EXISTS comments_post ON comments (post_id, status);`},
+    // webmentions are verified links to posts from other sites.
+    {"webmentions", `CREATE TABLE IF NOT EXISTS webmentions (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        post_id TEXT NOT NULL,
+        source TEXT NOT NULL,
+        target TEXT NOT NULL,
+        title TEXT NOT NULL DEFAULT '',
+        created_at INTEGER NOT NULL,
+        updated_at INTEGER NOT NULL,
+        UNIQUE (source, target)
+    );`},
+    // activitypub_key holds the single RSA key that signs the blog actor's
+    // requests.
+    {"activitypub key", `CREATE TABLE IF NOT EXISTS activitypub_key (
+        id INTEGER PRIMARY KEY CHECK (id = 1),
+        private_key TEXT NOT NULL
+    );`},
+    // activitypub_followers are fediverse actors following the blog. inbox
+    // is their shared inbox when they have one.
+    {"activitypub followers", `CREATE TABLE IF NOT EXISTS activitypub_followers (
+        actor TEXT PRIMARY KEY,
+        inbox TEXT NOT NULL,
+        follow_id TEXT NOT NULL DEFAULT '',
+        created_at INTEGER NOT NULL
+    );`},
+    // post_views holds deduplicated view counts per post, UTC day and
+    // referring host. No visitor data is stored.
+    {"post views", `CREATE TABLE IF NOT EXISTS post_views (
+        post_id TEXT NOT NULL,
+        day TEXT NOT NULL,
+        referrer TEXT NOT NULL DEFAULT '',
+        views INTEGER NOT NULL DEFAULT 0,
+        PRIMARY KEY (post_id, day, referrer)
+    );`},
+    // subscribers are newsletter readers. Only a hash of the confirmation
+    // token is kept; the unsubscribe token is mailed with every digest.
+    {"subscribers", `CREATE TABLE IF NOT EXISTS subscribers (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        email TEXT NOT NULL UNIQUE,
+        status TEXT NOT NULL DEFAULT 'pending',
+        confirm_token_hash TEXT NOT NULL DEFAULT '',
+        confirm_expires_at INTEGER NOT NULL DEFAULT 0,
+        unsubscribe_token TEXT NOT NULL UNIQUE,
+        created_at INTEGER NOT NULL,
+        confirmed_at INTEGER,
+        unsubscribed_at INTEGER
+    );`},
+    // newsletter_posts records the posts already mailed in a digest.
+    {"newsletter posts", `CREATE TABLE IF NOT EXISTS newsletter_posts (
+        post_id TEXT PRIMARY KEY,
+        sent_at INTEGER NOT NULL
+    );`},
+    // contact_messages are messages sent through the contact form.
+    {"contact messages", `CREATE TABLE IF NOT EXISTS contact_messages (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        name TEXT NOT NULL,
+        email TEXT NOT NULL DEFAULT '',
+        subject TEXT NOT NULL DEFAULT '',
+        body TEXT NOT NULL,
+        status TEXT NOT NULL DEFAULT 'unread',
+        ip TEXT NOT NULL DEFAULT '',
+        user_agent TEXT NOT NULL DEFAULT '',
+        created_at INTEGER NOT NULL,
+        read_at INTEGER
+    );`},
+    // webhooks are endpoints that get signed payloads when posts change.
+    // events is a comma-separated list of event types, empty for all.
+    {"webhooks", `CREATE TABLE IF NOT EXISTS webhooks (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        url TEXT NOT NULL,
+        secret TEXT NOT NULL,
+        events TEXT NOT NULL DEFAULT '',
+        created_at INTEGER NOT NULL
+    );`},
+    // webhook_deliveries logs every attempt at delivering a payload.
+    {"webhook deliveries", `CREATE TABLE IF NOT EXISTS webhook_deliveries (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        webhook_id INTEGER NOT NULL REFERENCES webhooks (id),
+        delivery_id TEXT NOT NULL,
+        event TEXT NOT NULL,
+        attempt INTEGER NOT NULL,
+        status_code INTEGER NOT NULL DEFAULT 0,
+        error TEXT NOT NULL DEFAULT '',
+        duration_ms INTEGER NOT NULL DEFAULT 0,
+        created_at INTEGER NOT NULL
+    );`},
+    {"webhook deliveries index", `CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook ON webhook_deliveries (webhook_id);`},
+    {"audit log", `CREATE TABLE IF NOT EXISTS audit_log (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        actor TEXT NOT NULL DEFAULT '',
+        action TEXT NOT NULL,
+        target TEXT NOT NULL DEFAULT '',
+        ip TEXT NOT NULL DEFAULT '',
+        user_agent TEXT NOT NULL DEFAULT '',
+        outcome TEXT NOT NULL DEFAULT '',
+        created_at INTEGER NOT NULL
+    );`},
+}
+
+// columns lists columns added to tables after they were first released.
+// CREATE TABLE IF NOT EXISTS leaves existing tables alone, so they are added
+// when missing.
+var columns = []struct {
+    table, column, decl string
+}{
+    {"api_tokens", "expires_at", "INTEGER"},
+}
+
 func InitDatabase() {
     var err error
-    DB, err = sql.Open("sqlite3", "/opt/chi-blog/blog-backend/auth.db")
+    DB, err = sql.Open("sqlite3", Path)
     if err != nil {
         log.Fatalf("Failed to open database: %v", err)
     }
-    createTblIfNone := `CREATE TABLE IF NOT EXISTS blog_users (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        username TEXT NOT NULL UNIQUE,
-        password_hash TEXT NOT NULL
-    );`
-    _, err = DB.Exec(createTblIfNone)
-    if err != nil {
-        log.Fatalf("Failed to create users table: %v", err)
+    for _, t := range schema {
+        if _, err = DB.Exec(t.stmt); err != nil {
+            log.Fatalf("Failed to create %s table: %v", t.name, err)
+        }
+    }
+    for _, c := range columns {
+        var n int
+        err = DB.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", c.table, c.column).Scan(&n)
+        if err == nil && n == 0 {
+            _, err = DB.Exec("ALTER TABLE " + c.table + " ADD COLUMN " + c.column + " " + c.decl)
+        }
+        if err != nil {
+            log.Fatalf("Failed to add %s.%s: %v", c.table, c.column, err)
+        }
     }
-    log.Println("Database initialized and users table checked/created")
+    log.Println("Database initialized and tables checked/created")
 }
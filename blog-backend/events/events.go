@@ -0,0 +1,56 @@
+// Package events is an in-process bus for things that happen to posts.
+// Handlers publish events; integrations such as outgoing webhooks subscribe
+// to them at startup.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/models"
+)
+
+// Event types.
+const (
+	PostPublished = "post.published"
+	PostUpdated   = "post.updated"
+	PostDeleted   = "post.deleted"
+)
+
+// Types lists every event type that is published.
+var Types = []string{PostPublished, PostUpdated, PostDeleted}
+
+// Event is something that happened to a post.
+type Event struct {
+	Type   string
+	PostID string
+	// Post is the post as rendered after the change. It is nil for deleted
+	// posts and when the post could not be loaded.
+	Post *models.Post
+	Time time.Time
+}
+
+var (
+	mu          sync.RWMutex
+	subscribers []func(Event)
+)
+
+// Subscribe calls fn for every event published from now on. fn runs on the
+// publisher's goroutine, so it must hand slow work off to a queue.
+func Subscribe(fn func(Event)) {
+	mu.Lock()
+	defer mu.Unlock()
+	subscribers = append(subscribers, fn)
+}
+
+// Publish passes e to every subscriber. Time is set to now if it is zero.
+func Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, fn := range subscribers {
+		fn(e)
+	}
+}
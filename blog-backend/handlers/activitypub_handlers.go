@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/gg582/chi-blog/blog-backend/activitypub"
+	"github.com/gg582/chi-blog/blog-backend/content"
+	"github.com/gg582/chi-blog/blog-backend/site"
+)
+
+// maxInboxBody limits the size of activities posted to the inbox.
+const maxInboxBody = 1 << 20
+
+// writeActivity answers with an ActivityPub document.
+func writeActivity(w http.ResponseWriter, doc any) {
+	w.Header().Set("Content-Type", activitypub.ContentType)
+	json.NewEncoder(w).Encode(doc)
+}
+
+// WebFingerHandler resolves the blog's handle, acct:blog@<host>, or its
+// actor URL to the actor document.
+func WebFingerHandler(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	if !strings.EqualFold(resource, activitypub.Handle()) && resource != activitypub.ActorID() {
+		http.Error(w, "Unknown resource.", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/jrd+json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(map[string]any{
+		"subject": activitypub.Handle(),
+		"aliases": []string{activitypub.ActorID(), site.URL},
+		"links": []map[string]string{
+			{"rel": "self", "type": activitypub.ContentType, "href": activitypub.ActorID()},
+			{"rel": "http://webfinger.net/rel/profile-page", "type": "text/html", "href": site.URL},
+		},
+	})
+}
+
+// ActorHandler serves the blog's actor document.
+func ActorHandler(w http.ResponseWriter, r *http.Request) {
+	actor, err := activitypub.Actor()
+	if err != nil {
+		log.Printf("Error building ActivityPub actor: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	writeActivity(w, actor)
+}
+
+// OutboxHandler lists a Create activity for every post, newest first.
+func OutboxHandler(w http.ResponseWriter, r *http.Request) {
+	posts, err := content.Posts()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	items := make([]any, len(posts))
+	for i, post := range posts {
+		items[i] = activitypub.Create(post)
+	}
+	writeActivity(w, activitypub.Collection(activitypub.OutboxURL(), items))
+}
+
+// FollowersHandler lists the actors following the blog.
+func FollowersHandler(w http.ResponseWriter, r *http.Request) {
+	followers, err := activitypub.Followers()
+	if err != nil {
+		log.Printf("Error listing ActivityPub followers: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	items := make([]any, len(followers))
+	for i, f := range followers {
+		items[i] = f.Actor
+	}
+	writeActivity(w, activitypub.Collection(activitypub.FollowersURL(), items))
+}
+
+// ArticleHandler serves the Article object of a post, so that its id can be
+// fetched by other servers.
+func ArticleHandler(w http.ResponseWriter, r *http.Request) {
+	postID := chi.URLParam(r, "id")
+	post, err := content.LoadPost(filepath.Join(content.PostsDir, postID+".md"), postID)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "Post not found.", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error reading file: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if post.Draft && !canReadDrafts(r) {
+		http.Error(w, "Post not found.", http.StatusNotFound)
+		return
+	}
+	article := activitypub.Article(*post)
+	article["@context"] = "https://www.w3.org/ns/activitystreams"
+	writeActivity(w, article)
+}
+
+// InboxHandler receives signed activities from other servers. Follow and
+// Undo Follow change the followers; everything else is acknowledged and
+// dropped.
+func InboxHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxInboxBody))
+	if err != nil {
+		http.Error(w, "Request body too large.", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	err = activitypub.HandleInbox(r, body)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusAccepted)
+	case errors.Is(err, activitypub.ErrInvalidActivity):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, activitypub.ErrInvalidSignature):
+		log.Printf("Rejected ActivityPub inbox request: %v", err)
+		http.Error(w, "Invalid signature.", http.StatusUnauthorized)
+	case errors.Is(err, activitypub.ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		log.Printf("Error handling ActivityPub activity: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
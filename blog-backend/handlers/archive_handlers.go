@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/gg582/chi-blog/blog-backend/content"
+)
+
+// GetArchiveHandler returns the number of posts per year and month, newest
+// first.
+func GetArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	archive, err := content.Archive()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(archive)
+}
+
+// GetArchiveMonthHandler lists the posts of /api/archive/{year}/{month},
+// newest first. It is paginated like GetPostsHandler.
+func GetArchiveMonthHandler(w http.ResponseWriter, r *http.Request) {
+	year, err := strconv.Atoi(chi.URLParam(r, "year"))
+	if err != nil || year < 1 || year > 9999 {
+		writeBadQuery(w, "year must be a number such as 2025.")
+		return
+	}
+	month, err := strconv.Atoi(chi.URLParam(r, "month"))
+	if err != nil || month < 1 || month > 12 {
+		writeBadQuery(w, "month must be a number from 1 to 12.")
+		return
+	}
+	limit, offset, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	posts, err := content.PostsInMonth(year, time.Month(month))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writePostPage(w, posts, limit, offset)
+}
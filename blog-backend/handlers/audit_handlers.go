@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/audit"
+	"github.com/gg582/chi-blog/blog-backend/auth"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+// GetAuditLogHandler lists audit log entries, newest first. It accepts the
+// query parameters actor, action (exact, or a prefix such as "post."),
+// target, outcome, since and until (RFC 3339), limit and offset. The total
+// number of matches is returned in the X-Total-Count header.
+func GetAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := audit.Filter{
+		Actor:   q.Get("actor"),
+		Action:  q.Get("action"),
+		Target:  q.Get("target"),
+		Outcome: q.Get("outcome"),
+		Limit:   defaultAuditLimit,
+	}
+
+	var err error
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			writeBadQuery(w, "since must be an RFC 3339 timestamp.")
+			return
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			writeBadQuery(w, "until must be an RFC 3339 timestamp.")
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 || f.Limit > maxAuditLimit {
+			writeBadQuery(w, "limit must be between 1 and "+strconv.Itoa(maxAuditLimit)+".")
+			return
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if f.Offset, err = strconv.Atoi(v); err != nil || f.Offset < 0 {
+			writeBadQuery(w, "offset must be a non-negative integer.")
+			return
+		}
+	}
+
+	entries, total, err := audit.Query(f)
+	if err != nil {
+		log.Printf("Error querying audit log: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	json.NewEncoder(w).Encode(entries)
+}
+
+// writeBadQuery answers with 400 for an invalid query parameter.
+func writeBadQuery(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": message,
+		"code":    "INVALID_QUERY",
+	})
+}
+
+// actorOf names the authenticated caller of r for the audit log.
+func actorOf(r *http.Request) string {
+	if p := auth.FromContext(r.Context()); p != nil {
+		return p.Username
+	}
+	return ""
+}
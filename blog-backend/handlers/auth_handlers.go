@@ -3,9 +3,15 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
+	"strings"
 
+	"github.com/gg582/chi-blog/blog-backend/audit"
+	"github.com/gg582/chi-blog/blog-backend/auth"
 	"github.com/gg582/chi-blog/blog-backend/database"
+	"github.com/gg582/chi-blog/blog-backend/ratelimit"
+	"github.com/gg582/chi-blog/blog-backend/twofactor"
 	"github.com/gg582/chi-blog/blog-backend/utils"
 )
 
@@ -15,6 +21,14 @@ type LoginRequest struct {
     Password string `json:"password"`
 }
 
+// TwoFactorLoginRequest is the second login step. Either Code (from the
+// authenticator app) or RecoveryCode must be set.
+type TwoFactorLoginRequest struct {
+    Challenge    string `json:"challenge"`
+    Code         string `json:"code"`
+    RecoveryCode string `json:"recoveryCode"`
+}
+
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
     var req LoginRequest
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -22,20 +36,183 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    // Throttle per client IP and per username before doing any bcrypt work.
+    keys := loginKeys(r, req.Username)
+    lockouts, ok := reserveLoginAttempt(w, keys)
+    if !ok {
+        return
+    }
+
     var storedPwHash string
     err := database.DB.QueryRow("SELECT password_hash FROM blog_users WHERE username = ?", req.Username).Scan(&storedPwHash)
     if err == sql.ErrNoRows {
-        http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+        loginFailed(w, r, req.Username, lockouts)
         return
     } else if err != nil {
         http.Error(w, "Internal server error", http.StatusInternalServerError)
         return
     }
     if !utils.CheckPasswordHash(req.Password, storedPwHash) {
-        http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+        loginFailed(w, r, req.Username, lockouts)
+        return
+    }
+
+    enabled, err := twofactor.Enabled(req.Username)
+    if err != nil {
+        log.Printf("Error checking two-factor status for %s: %v", req.Username, err)
+        http.Error(w, "Internal server error", http.StatusInternalServerError)
+        return
+    }
+    if enabled {
+        challenge, err := twofactor.NewChallenge(req.Username)
+        if err != nil {
+            log.Printf("Error creating two-factor challenge: %v", err)
+            http.Error(w, "Internal server error", http.StatusInternalServerError)
+            return
+        }
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusOK)
+        json.NewEncoder(w).Encode(map[string]any{
+            "message":           "Two-factor code required",
+            "twoFactorRequired": true,
+            "challenge":         challenge,
+        })
+        return
+    }
+
+    loginSucceeded(w, r, req.Username, keys)
+}
+
+// TwoFactorLoginHandler completes a login started by LoginHandler for users
+// with two-factor authentication enabled.
+func TwoFactorLoginHandler(w http.ResponseWriter, r *http.Request) {
+    var req TwoFactorLoginRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, "Invalid request body", http.StatusBadRequest)
+        return
+    }
+
+    username, ok := twofactor.ChallengeUser(req.Challenge)
+    if !ok {
+        http.Error(w, "Login challenge is invalid or expired, please log in again", http.StatusUnauthorized)
+        return
+    }
+    keys := loginKeys(r, username)
+    lockouts, ok := reserveLoginAttempt(w, keys)
+    if !ok {
+        return
+    }
+
+    var valid bool
+    var err error
+    if req.RecoveryCode != "" {
+        valid, err = twofactor.UseRecoveryCode(username, req.RecoveryCode)
+        if valid {
+            log.Printf("User %s logged in with a recovery code", username)
+            audit.Log(r, username, audit.ActionRecoveryCode, "", audit.OutcomeSuccess)
+        }
+    } else {
+        valid, err = twofactor.Verify(username, req.Code)
+    }
+    if err != nil {
+        log.Printf("Error verifying two-factor code for %s: %v", username, err)
+        http.Error(w, "Internal server error", http.StatusInternalServerError)
         return
     }
+    if !valid {
+        loginFailed(w, r, username, lockouts)
+        return
+    }
+
+    twofactor.CompleteChallenge(req.Challenge)
+    loginSucceeded(w, r, username, keys)
+}
+
+// CSRFTokenHandler returns the CSRF token of the caller's session. Write
+// endpoints expect it back in the X-CSRF-Token header.
+func CSRFTokenHandler(w http.ResponseWriter, r *http.Request) {
+    p := auth.FromContext(r.Context())
+    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("Cache-Control", "no-store")
+    json.NewEncoder(w).Encode(map[string]string{"csrfToken": p.Session.CSRFToken})
+}
+
+// LogoutHandler ends the caller's session.
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+    p := auth.FromContext(r.Context())
+    if err := auth.DeleteSession(p.SessionToken); err != nil {
+        log.Printf("Error deleting session of %s: %v", p.Username, err)
+        http.Error(w, "Internal server error", http.StatusInternalServerError)
+        return
+    }
+    auth.ClearSessionCookie(w, r)
+    audit.Log(r, p.Username, audit.ActionLogout, "", audit.OutcomeSuccess)
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{"message": "Logged out"})
+}
+
+// loginKeys returns the rate-limit keys a login attempt is counted against.
+func loginKeys(r *http.Request, username string) []string {
+    return []string{
+        ratelimit.IPKey(utils.ClientIP(r)),
+        ratelimit.UserKey(strings.ToLower(username)),
+    }
+}
+
+// reserveLoginAttempt counts the attempt against every key before the
+// credentials are checked. It answers with 429 and returns false when any of
+// keys is still backing off. lockouts are the keys that lock if the attempt
+// fails.
+func reserveLoginAttempt(w http.ResponseWriter, keys []string) (lockouts []string, ok bool) {
+    for _, key := range keys {
+        wait, locked, err := ratelimit.Attempt(key)
+        if err != nil {
+            log.Printf("Error checking login rate limit for %s: %v", key, err)
+            http.Error(w, "Internal server error", http.StatusInternalServerError)
+            return nil, false
+        }
+        if wait > 0 {
+            ratelimit.TooManyRequests(w, wait)
+            return nil, false
+        }
+        if locked {
+            lockouts = append(lockouts, key)
+        }
+    }
+    return lockouts, true
+}
+
+// loginSucceeded clears the throttling counters, starts a session cookie and
+// answers with 200 and the session's CSRF token.
+func loginSucceeded(w http.ResponseWriter, r *http.Request, username string, keys []string) {
+    for _, key := range keys {
+        if err := ratelimit.Reset(key); err != nil {
+            log.Printf("Error resetting login rate limit for %s: %v", key, err)
+        }
+    }
+    token, session, err := auth.CreateSession(username)
+    if err != nil {
+        log.Printf("Error creating session for %s: %v", username, err)
+        http.Error(w, "Internal server error", http.StatusInternalServerError)
+        return
+    }
+    auth.SetSessionCookie(w, r, token, session.ExpiresAt)
+    audit.Log(r, username, audit.ActionLogin, "", audit.OutcomeSuccess)
     w.Header().Set("Content-Type", "application/json")
     w.WriteHeader(http.StatusOK)
-    json.NewEncoder(w).Encode(map[string]string{"message": "Login succeed"})
+    json.NewEncoder(w).Encode(map[string]string{
+        "message":   "Login succeed",
+        "csrfToken": session.CSRFToken,
+    })
+}
+
+// loginFailed audits the lockouts the failed attempt triggered and answers
+// with 401. The attempt was already counted by reserveLoginAttempt.
+func loginFailed(w http.ResponseWriter, r *http.Request, username string, lockouts []string) {
+    for _, key := range lockouts {
+        log.Printf("Login locked out for %s", key)
+        audit.Log(r, username, audit.ActionLoginLockout, key, audit.OutcomeDenied)
+    }
+    audit.Log(r, username, audit.ActionLogin, "", audit.OutcomeFailure)
+    http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 }
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/gg582/chi-blog/blog-backend/audit"
+	"github.com/gg582/chi-blog/blog-backend/comments"
+	"github.com/gg582/chi-blog/blog-backend/content"
+	"github.com/gg582/chi-blog/blog-backend/models"
+	"github.com/gg582/chi-blog/blog-backend/utils"
+)
+
+const (
+	maxCommentAuthor = 80
+	maxCommentBody   = 5000
+
+	defaultCommentLimit = 50
+	maxCommentLimit     = 200
+)
+
+// moderationActions maps the actions of the moderation queue to the status
+// they set and the audit action they record.
+var moderationActions = map[string]struct{ status, action string }{
+	"approve": {comments.StatusApproved, audit.ActionCommentApprove},
+	"reject":  {comments.StatusRejected, audit.ActionCommentReject},
+	"spam":    {comments.StatusSpam, audit.ActionCommentSpam},
+}
+
+// GetCommentsHandler returns the approved comments on a post as a thread.
+// The number of comments is returned in the X-Total-Count header.
+func GetCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	postID := chi.URLParam(r, "id")
+	if _, ok := content.PostTitle(postID); !ok {
+		http.Error(w, "Post not found.", http.StatusNotFound)
+		return
+	}
+
+	thread, total, err := comments.Thread(postID)
+	if err != nil {
+		log.Printf("Error loading comments on %s: %v", postID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	json.NewEncoder(w).Encode(thread)
+}
+
+// CreateCommentHandler queues a reader's comment for moderation. It answers
+// 202 Accepted, as the comment is not shown until it is approved.
+func CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	postID := chi.URLParam(r, "id")
+
+	var req models.NewCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Bots fill in every field. Pretend to accept so they do not adapt.
+	if req.Website != "" {
+		log.Printf("Dropped comment on %s from %s: honeypot filled", postID, utils.ClientIP(r))
+		writeCommentAccepted(w, 0)
+		return
+	}
+
+	req.Author = strings.Join(strings.Fields(req.Author), " ")
+	req.Email = strings.TrimSpace(req.Email)
+	req.Body = strings.TrimSpace(req.Body)
+	if message := validateComment(req); message != "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": message,
+			"code":    "VALIDATION_ERROR",
+		})
+		return
+	}
+
+	if _, ok := content.PostTitle(postID); !ok {
+		http.Error(w, "Post not found.", http.StatusNotFound)
+		return
+	}
+
+	id, err := comments.Create(comments.New{
+		PostID:    postID,
+		ParentID:  req.ParentID,
+		Author:    req.Author,
+		Email:     req.Email,
+		Body:      req.Body,
+		IP:        utils.ClientIP(r),
+		UserAgent: r.UserAgent(),
+	})
+	if errors.Is(err, comments.ErrParentNotFound) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "The comment being replied to does not exist on this post.",
+			"code":    "INVALID_PARENT",
+		})
+		return
+	} else if errors.Is(err, comments.ErrTooDeep) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Replies cannot be nested more than " + strconv.Itoa(comments.MaxDepth) + " levels deep.",
+			"code":    "TOO_DEEP",
+		})
+		return
+	} else if err != nil {
+		log.Printf("Error saving comment on %s: %v", postID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	writeCommentAccepted(w, id)
+}
+
+// validateComment returns a message describing what is wrong with req, or
+// "" if it may be stored.
+func validateComment(req models.NewCommentRequest) string {
+	switch {
+	case req.Author == "" || req.Body == "":
+		return "Author and body cannot be empty."
+	case utf8.RuneCountInString(req.Author) > maxCommentAuthor:
+		return "Author must be at most " + strconv.Itoa(maxCommentAuthor) + " characters."
+	case utf8.RuneCountInString(req.Body) > maxCommentBody:
+		return "Body must be at most " + strconv.Itoa(maxCommentBody) + " characters."
+	case req.ParentID < 0:
+		return "parentId must be a comment id."
+	}
+	if req.Email != "" {
+		if addr, err := mail.ParseAddress(req.Email); err != nil || addr.Address != req.Email {
+			return "Email is not a valid address."
+		}
+	}
+	return ""
+}
+
+func writeCommentAccepted(w http.ResponseWriter, id int64) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{
+		"message": "Comment received and awaiting moderation.",
+		"id":      id,
+		"status":  comments.StatusPending,
+	})
+}
+
+// GetCommentQueueHandler lists comments for moderation, newest first. It
+// accepts the query parameters status (pending by default, or approved,
+// rejected, spam), limit and offset. The total number of comments with the
+// status is returned in the X-Total-Count header.
+func GetCommentQueueHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	status := q.Get("status")
+	if status == "" {
+		status = comments.StatusPending
+	} else if !comments.ValidStatus(status) {
+		writeBadQuery(w, "status must be pending, approved, rejected or spam.")
+		return
+	}
+	limit, offset := defaultCommentLimit, 0
+	var err error
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxCommentLimit {
+			writeBadQuery(w, "limit must be between 1 and "+strconv.Itoa(maxCommentLimit)+".")
+			return
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			writeBadQuery(w, "offset must be a non-negative integer.")
+			return
+		}
+	}
+
+	queue, total, err := comments.Queue(status, limit, offset)
+	if err != nil {
+		log.Printf("Error querying comment queue: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	json.NewEncoder(w).Encode(queue)
+}
+
+// ModerateCommentHandler approves, rejects or marks a comment as spam,
+// depending on the {action} in the URL.
+func ModerateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	target := chi.URLParam(r, "commentID")
+	id, err := strconv.ParseInt(target, 10, 64)
+	if err != nil {
+		http.Error(w, "Comment not found.", http.StatusNotFound)
+		return
+	}
+	m, ok := moderationActions[chi.URLParam(r, "action")]
+	if !ok {
+		http.Error(w, "Unknown moderation action.", http.StatusNotFound)
+		return
+	}
+
+	err = comments.SetStatus(id, m.status, actorOf(r))
+	if errors.Is(err, comments.ErrNotFound) {
+		http.Error(w, "Comment not found.", http.StatusNotFound)
+		return
+	} else if err != nil {
+		audit.Log(r, actorOf(r), m.action, target, audit.OutcomeFailure)
+		log.Printf("Error moderating comment %d: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	audit.Log(r, actorOf(r), m.action, target, audit.OutcomeSuccess)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"message": "Comment updated.",
+		"id":      id,
+		"status":  m.status,
+	})
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/gg582/chi-blog/blog-backend/comments"
+)
+
+// postComment sends body to CreateCommentHandler for post.
+func postComment(post, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/posts/"+post+"/comments", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	CreateCommentHandler(w, withID(r, post))
+	return w
+}
+
+// commentCount returns the X-Total-Count of GetCommentsHandler for post.
+func commentCount(t *testing.T, post string) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	GetCommentsHandler(w, withID(httptest.NewRequest(http.MethodGet, "/api/posts/"+post+"/comments", nil), post))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetCommentsHandler status %d: %s", w.Code, w.Body)
+	}
+	return w.Header().Get("X-Total-Count")
+}
+
+func moderate(id int64, action string) *httptest.ResponseRecorder {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("commentID", strconv.FormatInt(id, 10))
+	rctx.URLParams.Add("action", action)
+	r := httptest.NewRequest(http.MethodPost, "/api/comments/", nil)
+	w := httptest.NewRecorder()
+	ModerateCommentHandler(w, r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx)))
+	return w
+}
+
+func TestCommentHoneypot(t *testing.T) {
+	setupPosts(t, map[string]string{"post": "# Post\n"})
+
+	w := postComment("post", `{"author":"Bot","body":"Buy now","website":"https://spam.example"}`)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("status %d, want 202 so bots do not notice: %s", w.Code, w.Body)
+	}
+	for _, status := range []string{comments.StatusPending, comments.StatusApproved, comments.StatusSpam} {
+		if _, total, err := comments.Queue(status, 10, 0); err != nil || total != 0 {
+			t.Errorf("%s comments = %d, %v, want none stored", status, total, err)
+		}
+	}
+}
+
+func TestModerateCommentCount(t *testing.T) {
+	setupPosts(t, map[string]string{"post": "# Post\n"})
+
+	if w := postComment("post", `{"author":"Reader","body":"Nice post"}`); w.Code != http.StatusAccepted {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	queue, _, err := comments.Queue(comments.StatusPending, 10, 0)
+	if err != nil || len(queue) != 1 {
+		t.Fatalf("queue = %v, %v, want one pending comment", queue, err)
+	}
+	id := queue[0].ID
+	if got := commentCount(t, "post"); got != "0" {
+		t.Errorf("count while pending = %s, want 0", got)
+	}
+
+	for _, step := range []struct {
+		action string
+		count  string
+	}{
+		{"approve", "1"},
+		{"spam", "0"},
+		{"approve", "1"},
+		{"reject", "0"},
+	} {
+		if w := moderate(id, step.action); w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", step.action, w.Code, w.Body)
+		}
+		if got := commentCount(t, "post"); got != step.count {
+			t.Errorf("count after %s = %s, want %s", step.action, got, step.count)
+		}
+	}
+
+	if w := moderate(id, "delete"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown action: status %d, want 404", w.Code)
+	}
+	if w := moderate(id+1, "approve"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown comment: status %d, want 404", w.Code)
+	}
+}
+
+func TestCommentReplyErrors(t *testing.T) {
+	setupPosts(t, map[string]string{"post": "# Post\n"})
+	id, err := comments.Create(comments.New{PostID: "post", Author: "Reader", Body: "Root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := postComment("post", `{"author":"Reader","body":"Reply","parentId":`+strconv.FormatInt(id, 10)+`}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_PARENT") {
+		t.Errorf("reply to a pending comment: status %d %s, want 400 INVALID_PARENT", w.Code, w.Body)
+	}
+
+	for depth := 1; depth < comments.MaxDepth; depth++ {
+		if err := comments.SetStatus(id, comments.StatusApproved, "admin"); err != nil {
+			t.Fatal(err)
+		}
+		if id, err = comments.Create(comments.New{PostID: "post", ParentID: id, Author: "Reader", Body: "Reply"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := comments.SetStatus(id, comments.StatusApproved, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	w = postComment("post", `{"author":"Reader","body":"Reply","parentId":`+strconv.FormatInt(id, 10)+`}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "TOO_DEEP") {
+		t.Errorf("reply below MaxDepth: status %d %s, want 400 TOO_DEEP", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/gg582/chi-blog/blog-backend/contact"
+	"github.com/gg582/chi-blog/blog-backend/models"
+	"github.com/gg582/chi-blog/blog-backend/utils"
+)
+
+const (
+	maxContactName    = 80
+	maxContactSubject = 200
+	maxContactMessage = 10000
+
+	// People take a few seconds at least to fill in the form; bots post it
+	// right away. A form open for longer than a day has to be reloaded.
+	minContactFillTime = 3 * time.Second
+	maxContactFillTime = 24 * time.Hour
+
+	// maxContactBody bounds the request body, well above the longest
+	// message the limits above allow.
+	maxContactBody = 64 << 10
+
+	defaultContactLimit = 50
+	maxContactLimit     = 200
+)
+
+// CreateContactMessageHandler stores a message from the contact form and
+// forwards it to the site owner. The form must carry the token that
+// GetContactPageHandler issued when it was shown.
+func CreateContactMessageHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.ContactRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxContactBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Bots fill in every field or post the form at once. Pretend to accept
+	// so they do not adapt.
+	elapsed, err := contact.FormAge(req.FormToken)
+	if req.Website != "" || err == nil && elapsed < minContactFillTime {
+		log.Printf("Dropped contact message from %s: honeypot filled or sent after %s", utils.ClientIP(r), elapsed.Round(time.Millisecond))
+		writeContactAccepted(w)
+		return
+	}
+
+	req.Name = strings.Join(strings.Fields(req.Name), " ")
+	req.Email = strings.TrimSpace(req.Email)
+	req.Subject = strings.Join(strings.Fields(req.Subject), " ")
+	req.Message = strings.TrimSpace(req.Message)
+	message := validateContact(req)
+	if message == "" && (err != nil || elapsed > maxContactFillTime) {
+		message = "The form has expired. Reload the page and send your message again."
+	}
+	if message != "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": message,
+			"code":    "VALIDATION_ERROR",
+		})
+		return
+	}
+
+	_, err = contact.Create(&contact.Message{
+		Name:      req.Name,
+		Email:     req.Email,
+		Subject:   req.Subject,
+		Body:      req.Message,
+		IP:        utils.ClientIP(r),
+		UserAgent: r.UserAgent(),
+	})
+	if err != nil {
+		log.Printf("Error saving contact message: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	writeContactAccepted(w)
+}
+
+// validateContact returns a message describing what is wrong with req, or
+// "" if it may be stored.
+func validateContact(req models.ContactRequest) string {
+	switch {
+	case req.Name == "" || req.Message == "":
+		return "Name and message cannot be empty."
+	case utf8.RuneCountInString(req.Name) > maxContactName:
+		return "Name must be at most " + strconv.Itoa(maxContactName) + " characters."
+	case utf8.RuneCountInString(req.Subject) > maxContactSubject:
+		return "Subject must be at most " + strconv.Itoa(maxContactSubject) + " characters."
+	case utf8.RuneCountInString(req.Message) > maxContactMessage:
+		return "Message must be at most " + strconv.Itoa(maxContactMessage) + " characters."
+	}
+	if req.Email != "" {
+		if addr, err := mail.ParseAddress(req.Email); err != nil || addr.Address != req.Email {
+			return "Email is not a valid address."
+		}
+	}
+	return ""
+}
+
+func writeContactAccepted(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Thank you, your message has been sent.",
+	})
+}
+
+// GetContactMessagesHandler lists the contact inbox, newest first. It
+// accepts the query parameters status (unread or read, all by default),
+// limit and offset. The total number of matching messages is returned in
+// the X-Total-Count header.
+func GetContactMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	status := q.Get("status")
+	if status != "" && !contact.ValidStatus(status) {
+		writeBadQuery(w, "status must be unread or read.")
+		return
+	}
+	limit, offset := defaultContactLimit, 0
+	var err error
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxContactLimit {
+			writeBadQuery(w, "limit must be between 1 and "+strconv.Itoa(maxContactLimit)+".")
+			return
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			writeBadQuery(w, "offset must be a non-negative integer.")
+			return
+		}
+	}
+
+	messages, total, err := contact.List(status, limit, offset)
+	if err != nil {
+		log.Printf("Error querying contact messages: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	json.NewEncoder(w).Encode(messages)
+}
+
+// MarkContactMessageHandler marks a message as read or unread, depending
+// on the {status} in the URL.
+func MarkContactMessageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "messageID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Message not found.", http.StatusNotFound)
+		return
+	}
+	status := chi.URLParam(r, "status")
+	if !contact.ValidStatus(status) {
+		http.Error(w, "Unknown message status.", http.StatusNotFound)
+		return
+	}
+
+	err = contact.SetStatus(id, status)
+	if errors.Is(err, contact.ErrNotFound) {
+		http.Error(w, "Message not found.", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error marking contact message %d: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"message": "Message updated.",
+		"id":      id,
+		"status":  status,
+	})
+}
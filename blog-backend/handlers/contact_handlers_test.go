@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/contact"
+)
+
+func TestContactForm(t *testing.T) {
+	setupPosts(t, nil)
+	now := time.Now()
+	contact.Now = func() time.Time { return now }
+	t.Cleanup(func() { contact.Now = time.Now })
+
+	w := httptest.NewRecorder()
+	GetContactPageHandler(w, httptest.NewRequest(http.MethodGet, "/api/contact", nil))
+	token := w.Header().Get("X-Form-Token")
+	if token == "" {
+		t.Fatal("GET /api/contact issued no X-Form-Token")
+	}
+
+	tests := []struct {
+		name    string
+		after   time.Duration
+		body    string
+		want    int
+		message string // expected in the response
+		stored  bool
+	}{
+		{"sent at once", time.Second, `{"name":"Ann","message":"Hi","formToken":"` + token + `"}`, http.StatusAccepted, "Thank you", false},
+		{"honeypot", time.Minute, `{"name":"Ann","message":"Hi","website":"x","formToken":"` + token + `"}`, http.StatusAccepted, "Thank you", false},
+		{"no token", time.Minute, `{"name":"Ann","message":"Hi"}`, http.StatusBadRequest, "expired", false},
+		{"client timestamp", time.Minute, `{"name":"Ann","message":"Hi","startedAt":1}`, http.StatusBadRequest, "expired", false},
+		{"forged token", time.Minute, `{"name":"Ann","message":"Hi","formToken":"1.abc"}`, http.StatusBadRequest, "expired", false},
+		{"expired token", 25 * time.Hour, `{"name":"Ann","message":"Hi","formToken":"` + token + `"}`, http.StatusBadRequest, "expired", false},
+		{"too large", time.Minute, `{"name":"Ann","message":"` + strings.Repeat("a", maxContactBody) + `"}`, http.StatusBadRequest, "too large", false},
+		{"valid", time.Minute, `{"name":"Ann","message":"Hi","formToken":"` + token + `"}`, http.StatusAccepted, "Thank you", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contact.Now = func() time.Time { return now.Add(tt.after) }
+			w := httptest.NewRecorder()
+			CreateContactMessageHandler(w, httptest.NewRequest(http.MethodPost, "/api/contact", strings.NewReader(tt.body)))
+			if w.Code != tt.want || !strings.Contains(w.Body.String(), tt.message) {
+				t.Errorf("status %d %q, want %d containing %q", w.Code, w.Body, tt.want, tt.message)
+			}
+			_, total, err := contact.List("", 10, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored := total > 0; stored != tt.stored {
+				t.Errorf("stored = %v, want %v", stored, tt.stored)
+			}
+		})
+	}
+}
@@ -7,10 +7,22 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5" // Import chi for URLParam
 
+	"github.com/gg582/chi-blog/blog-backend/activitypub"
+	"github.com/gg582/chi-blog/blog-backend/audit"
+	"github.com/gg582/chi-blog/blog-backend/auth"
+	"github.com/gg582/chi-blog/blog-backend/content"
+	"github.com/gg582/chi-blog/blog-backend/events"
 	"github.com/gg582/chi-blog/blog-backend/models"
+	"github.com/gg582/chi-blog/blog-backend/shortcode"
+	"github.com/gg582/chi-blog/blog-backend/site"
+	"github.com/gg582/chi-blog/blog-backend/utils"
+	"github.com/gg582/chi-blog/blog-backend/webmention"
 )
 
 // CreateNewPostHandler handles the submission of a new blog post.
@@ -50,6 +62,31 @@ func CreateNewPostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Trusted posts bypass HTML sanitization, so only an admin's browser
+	// session may create them, never an API token.
+	if newPost.Trusted {
+		if p := auth.FromContext(r.Context()); p == nil || p.Session == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{
+				"message": "Only a logged-in admin can publish trusted posts.",
+				"code":    "TRUSTED_REQUIRES_SESSION",
+			})
+			return
+		}
+	}
+
+	// Reject shortcodes that would break the post when it is rendered.
+	if _, err := shortcode.Expand([]byte(newPost.Content), content.PostTitle); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": err.Error(),
+			"code":    "INVALID_SHORTCODE",
+		})
+		return
+	}
+
 	// Define the directory for posts
 	postsDir := "./posts"
 	if _, err := os.Stat(postsDir); os.IsNotExist(err) {
@@ -78,20 +115,94 @@ func CreateNewPostHandler(w http.ResponseWriter, r *http.Request) {
 	// 	return
 	// }
 
-	// Prepare the content to be written to the markdown file
-	// Include the author as front matter. You might also want to include the original title here.
-	markdownContent := fmt.Sprintf("---\nauthor: %s\n---\n\n# %s\n\n%s", newPost.Author, newPost.Title, newPost.Content)
+	// Overwriting an existing slug is recorded as an update in the audit log.
+	// The existing front matter is kept, so keys the request leaves out, such
+	// as tags or the original date, survive the edit.
+	action := audit.ActionPostCreate
+	meta := utils.FrontMatter{}
+	if info, err := os.Stat(filePath); err == nil {
+		action = audit.ActionPostUpdate
+		if existing, err := os.ReadFile(filePath); err == nil {
+			meta, _ = utils.ParseFrontMatter(existing)
+		}
+		if _, ok := meta.Time("date"); !ok {
+			meta["date"] = info.ModTime().Format(time.RFC3339)
+		}
+	} else {
+		meta["date"] = time.Now().Format(time.RFC3339)
+	}
+	wasDraft := meta.Bool("draft")
+
+	// Line breaks are dropped from every value by Encode, so the author
+	// cannot smuggle extra front matter keys such as "trusted".
+	meta["author"] = newPost.Author
+	// Trusted is decided again on every save: an edit through an API token
+	// must not keep publishing unsanitized HTML.
+	delete(meta, "trusted")
+	if newPost.Trusted {
+		meta["trusted"] = "true"
+	}
+	if newPost.Draft != nil {
+		delete(meta, "draft")
+		if *newPost.Draft {
+			meta["draft"] = "true"
+		}
+	}
+	if newPost.Tags != nil {
+		meta.SetList("tags", newPost.Tags)
+	}
+	if newPost.Series != nil {
+		delete(meta, "series")
+		delete(meta, "series_order")
+		if series := strings.TrimSpace(*newPost.Series); series != "" {
+			meta["series"] = series
+		}
+	}
+	if newPost.SeriesOrder != nil && meta["series"] != "" {
+		meta["series_order"] = strconv.Itoa(*newPost.SeriesOrder)
+	}
+	if newPost.Summary != nil {
+		delete(meta, "summary")
+		if summary := strings.TrimSpace(*newPost.Summary); summary != "" {
+			meta["summary"] = summary
+		}
+	}
+	markdownContent := fmt.Sprintf("%s\n# %s\n\n%s", meta.Encode(), newPost.Title, newPost.Content)
 
 	// Write the markdown content to the file
 	err = os.WriteFile(filePath, []byte(markdownContent), 0644)
 	if err != nil {
+		audit.Log(r, actorOf(r), action, postSlug, audit.OutcomeFailure)
 		http.Error(w, "Error saving post file: "+err.Error(), http.StatusInternalServerError)
 		log.Printf("Error saving post file: %v", err)
 		return
 	}
+	audit.Log(r, actorOf(r), action, postSlug, audit.OutcomeSuccess)
 
 	log.Printf("New post '%s' (slug: %s) saved to %s", newPost.Title, postSlug, filePath)
 
+	// Let the sites the post links to, the blog's followers and the webhooks
+	// know about it. Drafts stay quiet until they are published; a draft
+	// published by this edit is announced as new.
+	post, err := content.LoadPost(filePath, postSlug)
+	if err != nil {
+		log.Printf("Error rendering post %s for webmentions and followers: %v", postSlug, err)
+	}
+	if post == nil || !post.Draft {
+		updated := action == audit.ActionPostUpdate && !wasDraft
+		if post != nil {
+			webmention.Announce(site.PostURL(postSlug), []byte(post.ContentHTML))
+			if _, err := activitypub.Publish(*post, updated); err != nil {
+				log.Printf("Error delivering post %s to followers: %v", postSlug, err)
+			}
+		}
+		event := events.PostPublished
+		if updated {
+			event = events.PostUpdated
+		}
+		events.Publish(events.Event{Type: event, PostID: postSlug, Post: post})
+	}
+
 	// Respond with success
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated) // 201 Created for successful resource creation
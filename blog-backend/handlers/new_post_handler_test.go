@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/gg582/chi-blog/blog-backend/auth"
+)
+
+// TestTrustedRequiresSession checks that only a browser session can save a
+// post with "trusted", the flag that skips HTML sanitization.
+func TestTrustedRequiresSession(t *testing.T) {
+	// The unknown shortcode makes an accepted request stop at validation,
+	// right after the trusted check and before anything is written.
+	body := `{"title":"T","author":"a","content":"{{< nope >}}","trusted":true}`
+
+	tests := []struct {
+		name     string
+		caller   *auth.Principal
+		wantCode string
+	}{
+		{"anonymous", nil, "TRUSTED_REQUIRES_SESSION"},
+		{"api token", &auth.Principal{Username: "admin", Token: &auth.APIToken{Scopes: []string{auth.ScopePostsWrite}}}, "TRUSTED_REQUIRES_SESSION"},
+		{"session", &auth.Principal{Username: "admin", Session: &auth.Session{Username: "admin"}}, "INVALID_SHORTCODE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/new-post/t", strings.NewReader(body))
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "t")
+			ctx := context.WithValue(r.Context(), chi.RouteCtxKey, rctx)
+			if tt.caller != nil {
+				ctx = auth.NewContext(ctx, tt.caller)
+			}
+			w := httptest.NewRecorder()
+			CreateNewPostHandler(w, r.WithContext(ctx))
+
+			var resp struct{ Code string }
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.Code != tt.wantCode {
+				t.Errorf("status %d, code %q; want code %q", w.Code, resp.Code, tt.wantCode)
+			}
+		})
+	}
+}
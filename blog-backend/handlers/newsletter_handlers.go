@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/gg582/chi-blog/blog-backend/models"
+	"github.com/gg582/chi-blog/blog-backend/newsletter"
+	"github.com/gg582/chi-blog/blog-backend/utils"
+)
+
+// SubscribeHandler starts a newsletter subscription by mailing a
+// confirmation link. It answers the same whether or not the address is
+// already subscribed.
+func SubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.SubscribeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Website == "" {
+		email := strings.TrimSpace(req.Email)
+		if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"message": "Email is not a valid address.",
+				"code":    "VALIDATION_ERROR",
+			})
+			return
+		}
+		if err := newsletter.Subscribe(email); err != nil {
+			log.Printf("Error subscribing an address: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		// Bots fill in every field. Pretend to accept so they do not adapt.
+		log.Printf("Dropped subscription from %s: honeypot filled", utils.ClientIP(r))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Check your inbox for a link to confirm your subscription.",
+	})
+}
+
+// ConfirmSubscriptionHandler confirms a subscription with the token from
+// the confirmation mail.
+func ConfirmSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.TokenRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	writeTokenResult(w, newsletter.Confirm(req.Token), "Your subscription is confirmed.")
+}
+
+// UnsubscribeHandler ends a subscription. The token is read from the query
+// string, as in one-click List-Unsubscribe requests, or from a JSON body.
+func UnsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		var req models.TokenRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		token = req.Token
+	}
+	writeTokenResult(w, newsletter.Unsubscribe(token), "You have been unsubscribed.")
+}
+
+func writeTokenResult(w http.ResponseWriter, err error, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	if errors.Is(err, newsletter.ErrInvalidToken) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "This link is invalid or has expired.",
+			"code":    "INVALID_TOKEN",
+		})
+		return
+	} else if err != nil {
+		log.Printf("Error updating subscription: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
@@ -5,107 +5,60 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"path/filepath"
-	"strings"
 
-	"github.com/russross/blackfriday/v2"
+	"github.com/go-chi/chi/v5"
 
-	"github.com/gg582/chi-blog/blog-backend/models"
-	"github.com/gg582/chi-blog/blog-backend/utils"
+	"github.com/gg582/chi-blog/blog-backend/contact"
+	"github.com/gg582/chi-blog/blog-backend/content"
 )
 
-// GetAboutPageHandler handles fetching the content for the about page.
-func GetAboutPageHandler(w http.ResponseWriter, r *http.Request) {
-	filePath := filepath.Join("./about", "about.md") // Assuming about.md is in the 'posts' directory
-	
-	content, err := os.ReadFile(filePath)
+// pagesDir holds the static pages (about, contact, ...) as markdown files.
+const pagesDir = "./pages"
+
+// GetPagesHandler lists the static pages for the site navigation.
+func GetPagesHandler(w http.ResponseWriter, r *http.Request) {
+	nav, err := content.GetNavigation(pagesDir)
 	if err != nil {
-		if os.IsNotExist(err) {
-			http.Error(w, "About page content not found.", http.StatusNotFound)
-			return
-		}
-		http.Error(w, fmt.Sprintf("Error reading about file: %v", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	author, cleanedContent := utils.ParseAuthorAndCleanContent(content)
-	htmlContent := blackfriday.Run(cleanedContent)
-	
-	// Determine title from cleaned content, or use a default
-	title := "About Us"
-	lines := strings.Split(string(cleanedContent), "\n")
-	for _, line := range lines {
-		trimmedLine := strings.TrimSpace(line)
-		if trimmedLine != "" {
-			if strings.HasPrefix(trimmedLine, "#") {
-				title = strings.TrimSpace(strings.TrimPrefix(trimmedLine, "#"))
-			} else {
-				title = trimmedLine
-			}
-			break
-		}
-	}
-
-	fileInfo, _ := os.Stat(filePath)
-	createdAt := fileInfo.ModTime()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(nav)
+}
 
-	post := models.Post{
-		ID:          "about",
-		Title:       title,
-		ContentHTML: string(htmlContent),
-		Author:      author,
-		CreatedAt:   createdAt,
-		FileName:    "about.md",
-	}
+// GetPageHandler handles fetching a static page by its slug.
+func GetPageHandler(w http.ResponseWriter, r *http.Request) {
+	servePage(w, chi.URLParam(r, "slug"))
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(post)
+// GetAboutPageHandler handles fetching the content for the about page.
+// It is kept as an alias of /api/pages/about.
+func GetAboutPageHandler(w http.ResponseWriter, r *http.Request) {
+	servePage(w, "about")
 }
 
 // GetContactPageHandler handles fetching the content for the contact page.
+// It is kept as an alias of /api/pages/contact, and also hands out the
+// token the contact form sends back, in the X-Form-Token header.
 func GetContactPageHandler(w http.ResponseWriter, r *http.Request) {
-	filePath := filepath.Join("./contact", "contact.md") // Assuming contact.md is in the 'posts' directory
-	
-	content, err := os.ReadFile(filePath)
+	w.Header().Set("X-Form-Token", contact.FormToken())
+	w.Header().Set("Cache-Control", "no-store")
+	servePage(w, "contact")
+}
+
+// servePage writes the page slug as JSON, in the same shape as a post.
+func servePage(w http.ResponseWriter, slug string) {
+	page, err := content.LoadPage(pagesDir, slug)
 	if err != nil {
 		if os.IsNotExist(err) {
-			http.Error(w, "Contact page content not found.", http.StatusNotFound)
+			http.Error(w, "Page not found.", http.StatusNotFound)
 			return
 		}
-		http.Error(w, fmt.Sprintf("Error reading contact file: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error reading page: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	author, cleanedContent := utils.ParseAuthorAndCleanContent(content)
-	htmlContent := blackfriday.Run(cleanedContent)
-	
-	// Determine title from cleaned content, or use a default
-	title := "Contact Us"
-	lines := strings.Split(string(cleanedContent), "\n")
-	for _, line := range lines {
-		trimmedLine := strings.TrimSpace(line)
-		if trimmedLine != "" {
-			if strings.HasPrefix(trimmedLine, "#") {
-				title = strings.TrimSpace(strings.TrimPrefix(trimmedLine, "#"))
-			} else {
-				title = trimmedLine
-			}
-			break
-		}
-	}
-
-	fileInfo, _ := os.Stat(filePath)
-	createdAt := fileInfo.ModTime()
-
-	post := models.Post{
-		ID:          "contact",
-		Title:       title,
-		ContentHTML: string(htmlContent),
-		Author:      author,
-		CreatedAt:   createdAt,
-		FileName:    "contact.md",
-	}
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(post)
+	json.NewEncoder(w).Encode(page)
 }
@@ -3,27 +3,85 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
-        "github.com/russross/blackfriday/v2"
 
-	"github.com/gg582/chi-blog/blog-backend/models"    // Import models package
-	"github.com/gg582/chi-blog/blog-backend/utils" // Import utils package
+	"github.com/gg582/chi-blog/blog-backend/auth"
+	"github.com/gg582/chi-blog/blog-backend/comments"
+	"github.com/gg582/chi-blog/blog-backend/content"
+	"github.com/gg582/chi-blog/blog-backend/models"
+	"github.com/gg582/chi-blog/blog-backend/render"
+	"github.com/gg582/chi-blog/blog-backend/stats"
+	"github.com/gg582/chi-blog/blog-backend/utils"
 )
 
-// GetPostsHandler handles fetching all blog posts.
+// maxPostLimit caps the limit parameter of post listings.
+const maxPostLimit = 100
+
+// GetPostsHandler handles fetching all blog posts, newest first. The
+// optional query parameters limit and offset select a page; without limit
+// every post is returned. The total number of posts is returned in the
+// X-Total-Count header.
 func GetPostsHandler(w http.ResponseWriter, r *http.Request) {
-	posts, err := utils.GetPosts("./posts") // Use the utility function
+	limit, offset, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+	posts, err := content.Posts()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	writePostPage(w, posts, limit, offset)
+}
+
+// parsePage reads the limit and offset query parameters of a post listing.
+// A limit of 0 means no limit. It answers 400 and reports false when they
+// are invalid.
+func parsePage(w http.ResponseWriter, r *http.Request) (limit, offset int, ok bool) {
+	q := r.URL.Query()
+	var err error
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxPostLimit {
+			writeBadQuery(w, "limit must be between 1 and "+strconv.Itoa(maxPostLimit)+".")
+			return 0, 0, false
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			writeBadQuery(w, "offset must be a non-negative integer.")
+			return 0, 0, false
+		}
+	}
+	return limit, offset, true
+}
+
+// writePostPage writes the page of posts selected by limit and offset,
+// with their comment counts, and the total in the X-Total-Count header.
+func writePostPage(w http.ResponseWriter, posts []models.Post, limit, offset int) {
+	total := len(posts)
+	posts = posts[min(offset, total):]
+	if limit > 0 && limit < len(posts) {
+		posts = posts[:limit]
+	}
+	// Posts may come from the shared post index; count on a copy.
+	posts = append([]models.Post{}, posts...)
+	// A failing count only hides the numbers, the posts are still served.
+	if counts, err := comments.Counts(); err != nil {
+		log.Printf("Error counting comments: %v", err)
+	} else {
+		for i := range posts {
+			posts[i].CommentCount = counts[posts[i].ID]
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	json.NewEncoder(w).Encode(posts)
 }
 
@@ -32,8 +90,8 @@ func GetPostByIDHandler(w http.ResponseWriter, r *http.Request) {
 	postID := chi.URLParam(r, "id") // Get the post ID (slug) from the URL
 
 	filePath := filepath.Join("./posts", postID+".md") // Construct the file path
-	
-	content, err := os.ReadFile(filePath) // Use os.ReadFile
+
+	post, err := content.LoadPost(filePath, postID)
 	if err != nil {
 		if os.IsNotExist(err) {
 			http.Error(w, "Post not found.", http.StatusNotFound)
@@ -42,36 +100,54 @@ func GetPostByIDHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Error reading file: %v", err), http.StatusInternalServerError)
 		return
 	}
-
-	author, cleanedContent := utils.ParseAuthorAndCleanContent(content) // Use utility function
-	htmlContent := blackfriday.Run(cleanedContent)
-
-	title := strings.TrimSuffix(filepath.Base(filePath), ".md") // Default title to filename
-	lines := strings.Split(string(cleanedContent), "\n")
-	for _, line := range lines {
-		trimmedLine := strings.TrimSpace(line)
-		if trimmedLine != "" {
-			if strings.HasPrefix(trimmedLine, "#") {
-				title = strings.TrimSpace(strings.TrimPrefix(trimmedLine, "#"))
-			} else {
-				title = trimmedLine
-			}
-			break
-		}
+	if post.Draft && !canReadDrafts(r) {
+		http.Error(w, "Post not found.", http.StatusNotFound)
+		return
+	}
+	// The frontend passes on document.referrer, as the Referer of this
+	// request is the blog itself.
+	var req models.GetPostRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	if req.Referrer == "" {
+		req.Referrer = r.Referer()
+	}
+	if !post.Draft {
+		stats.Record(post.ID, utils.ClientIP(r), r.UserAgent(), req.Referrer)
 	}
 
-	fileInfo, _ := os.Stat(filePath)
-	createdAt := fileInfo.ModTime()
-
-	post := models.Post{ // Create a Post model instance
-		ID:          postID,
-		Title:       title,
-		ContentHTML: string(htmlContent),
-		Author:      author,
-		CreatedAt:   createdAt,
-		FileName:    postID + ".md",
+	if post.CommentCount, err = comments.Count(post.ID); err != nil {
+		log.Printf("Error counting comments on %s: %v", post.ID, err)
+	}
+	if err := content.AddNavigation(post); err != nil {
+		log.Printf("Error finding related and neighbouring posts of %s: %v", post.ID, err)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(post)
 }
+
+// canReadDrafts reports whether the caller of r may see draft posts: the
+// logged-in admin, or an API token with the read-drafts scope. Everyone else
+// gets a 404, as if the draft did not exist.
+func canReadDrafts(r *http.Request) bool {
+	p := auth.Optional(r)
+	return p != nil && p.HasScope(auth.ScopeReadDrafts)
+}
+
+// GetHighlightCSSHandler serves the stylesheet for server-side highlighted
+// code blocks. The chroma theme can be picked with ?style=, e.g. ?style=dracula.
+func GetHighlightCSSHandler(w http.ResponseWriter, r *http.Request) {
+	style := r.URL.Query().Get("style")
+	if style == "" {
+		style = render.DefaultHighlightStyle
+	}
+	if !render.HighlightStyleExists(style) {
+		http.Error(w, fmt.Sprintf("Unknown highlight style: %s", style), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	if err := render.WriteHighlightCSS(w, style); err != nil {
+		http.Error(w, fmt.Sprintf("Error writing stylesheet: %v", err), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/gg582/chi-blog/blog-backend/auth"
+	"github.com/gg582/chi-blog/blog-backend/database"
+)
+
+// setupPosts runs the test in a fresh directory with the given posts and
+// database.
+func setupPosts(t *testing.T, posts map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.Mkdir("posts", 0755); err != nil {
+		t.Fatal(err)
+	}
+	for slug, source := range posts {
+		if err := os.WriteFile(filepath.Join("posts", slug+".md"), []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	database.Path = filepath.Join(dir, "auth.db")
+	database.InitDatabase()
+	t.Cleanup(func() { database.DB.Close() })
+}
+
+// withID adds the chi URL parameter "id" to r.
+func withID(r *http.Request, id string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", id)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestDraftVisibility(t *testing.T) {
+	setupPosts(t, map[string]string{
+		"draft":     "---\ndraft: true\n---\n# Draft\n\nNot yet.\n",
+		"published": "# Published\n\nOut.\n",
+	})
+	session, _, err := auth.CreateSession("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, _, err := auth.CreateToken("admin", "preview", []string{auth.ScopeReadDrafts}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, _, err := auth.CreateToken("admin", "ci", []string{auth.ScopePostsWrite}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		post   string
+		bearer string
+		cookie string
+		want   int
+	}{
+		{"published, anonymous", "published", "", "", http.StatusOK},
+		{"draft, anonymous", "draft", "", "", http.StatusNotFound},
+		{"draft, token without read-drafts", "draft", writer, "", http.StatusNotFound},
+		{"draft, invalid token", "draft", "cbt_nope", "", http.StatusNotFound},
+		{"draft, read-drafts token", "draft", reader, "", http.StatusOK},
+		{"draft, session", "draft", "", session, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/posts/"+tt.post, nil)
+			if tt.bearer != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: auth.SessionCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			GetPostByIDHandler(w, withID(r, tt.post))
+			if w.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
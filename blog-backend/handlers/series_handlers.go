@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/gg582/chi-blog/blog-backend/comments"
+	"github.com/gg582/chi-blog/blog-backend/content"
+)
+
+// GetSeriesListHandler lists every multi-part series with its number of
+// posts.
+func GetSeriesListHandler(w http.ResponseWriter, r *http.Request) {
+	series, err := content.ListSeries()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(series)
+}
+
+// GetSeriesHandler returns a series with its posts in reading order, as
+// links with a summary rather than full posts.
+func GetSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	series, err := content.GetSeries(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if series == nil {
+		http.Error(w, "Series not found.", http.StatusNotFound)
+		return
+	}
+	if counts, err := comments.Counts(); err != nil {
+		log.Printf("Error counting comments: %v", err)
+	} else {
+		for i := range series.Posts {
+			series.Posts[i].CommentCount = counts[series.Posts[i].ID]
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(series)
+}
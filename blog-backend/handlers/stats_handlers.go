@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/stats"
+)
+
+const (
+	defaultStatsDays = 30
+	maxStatsDays     = 366
+)
+
+// GetStatsHandler reports post views per post, per day and per referring
+// site. It accepts the query parameters days (the number of days up to
+// today, 30 by default) or from and to (YYYY-MM-DD, UTC), and post to limit
+// the report to one post.
+func GetStatsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, 1-defaultStatsDays)
+
+	var err error
+	if v := q.Get("days"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 1 || days > maxStatsDays {
+			writeBadQuery(w, "days must be between 1 and "+strconv.Itoa(maxStatsDays)+".")
+			return
+		}
+		from = to.AddDate(0, 0, 1-days)
+	}
+	if v := q.Get("from"); v != "" {
+		if from, err = time.Parse(stats.DayFormat, v); err != nil {
+			writeBadQuery(w, "from must be a date such as 2025-01-31.")
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if to, err = time.Parse(stats.DayFormat, v); err != nil {
+			writeBadQuery(w, "to must be a date such as 2025-01-31.")
+			return
+		}
+	}
+	if to.Before(from) || to.Sub(from) >= maxStatsDays*24*time.Hour {
+		writeBadQuery(w, "from must be before to and at most "+strconv.Itoa(maxStatsDays)+" days apart.")
+		return
+	}
+
+	report, err := stats.Query(from, to, q.Get("post"))
+	if err != nil {
+		log.Printf("Error querying view stats: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
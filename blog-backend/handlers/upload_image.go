@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/gg582/chi-blog/blog-backend/audit"
 	"github.com/gg582/chi-blog/blog-backend/workerpool"
 )
 
@@ -82,9 +83,11 @@ func UploadFile(w http.ResponseWriter, r *http.Request) {
 
 			if result.Error != nil {
 				log.Printf("File upload failed for %s: %v", handler.Filename, result.Error)
+				audit.Log(r, actorOf(r), audit.ActionUpload, handler.Filename, audit.OutcomeFailure)
 				allSuccess = false
 				continue
 			}
+			audit.Log(r, actorOf(r), audit.ActionUpload, result.SavedFileName, audit.OutcomeSuccess)
 
 			// Construct the public URL for the saved file based on the current request host/protocol.
 			scheme := "http"
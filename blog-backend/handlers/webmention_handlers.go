@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/gg582/chi-blog/blog-backend/content"
+	"github.com/gg582/chi-blog/blog-backend/webmention"
+)
+
+// ReceiveWebmentionHandler accepts a webmention as form fields source and
+// target. The source is verified later by a worker, so a valid request is
+// answered with 202 Accepted.
+func ReceiveWebmentionHandler(w http.ResponseWriter, r *http.Request) {
+	source, target := r.PostFormValue("source"), r.PostFormValue("target")
+	postID, err := webmention.Validate(source, target)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": err.Error(),
+			"code":    "INVALID_WEBMENTION",
+		})
+		return
+	}
+
+	if !webmention.Enqueue(webmention.Job{Source: source, Target: target, PostID: postID}) {
+		log.Printf("Webmention queue is full. Dropped %s -> %s.", source, target)
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "Too many webmentions, try again later.", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Webmention queued for verification.",
+	})
+}
+
+// GetWebmentionsHandler lists the verified webmentions of a post. Their
+// number is returned in the X-Total-Count header.
+func GetWebmentionsHandler(w http.ResponseWriter, r *http.Request) {
+	postID := chi.URLParam(r, "id")
+	if _, ok := content.PostTitle(postID); !ok {
+		http.Error(w, "Post not found.", http.StatusNotFound)
+		return
+	}
+
+	mentions, err := webmention.List(postID)
+	if err != nil {
+		log.Printf("Error loading webmentions of %s: %v", postID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(mentions)))
+	json.NewEncoder(w).Encode(mentions)
+}
@@ -0,0 +1,134 @@
+// Package mail sends email through a pluggable Mailer: SMTP in production,
+// or a directory of .eml files for development and tests.
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Message is an email with a plain-text body.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+	// Headers are extra headers such as List-Unsubscribe.
+	Headers map[string]string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// SMTPMailer sends messages through an SMTP server, authenticating with
+// PLAIN auth when Username is set. Go's client upgrades to TLS with
+// STARTTLS when the server offers it.
+type SMTPMailer struct {
+	Addr     string // host:port
+	Username string
+	Password string
+	From     string
+}
+
+// Send implements Mailer.
+func (m SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, _ := strings.Cut(m.Addr, ":")
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	data, err := encode(m.From, msg)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(m.Addr, auth, m.From, []string{msg.To}, data)
+}
+
+// FileMailer writes every message as an .eml file into Dir instead of
+// sending it.
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+// Send implements Mailer.
+func (m FileMailer) Send(msg Message) error {
+	data, err := encode(m.From, msg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, 0o700); err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	name := time.Now().UTC().Format("20060102T150405.000000000Z") + "-" + hex.EncodeToString(suffix) + ".eml"
+	return os.WriteFile(filepath.Join(m.Dir, name), data, 0o600)
+}
+
+// FromEnv returns an SMTPMailer when SMTP_ADDR is set, using SMTP_USERNAME,
+// SMTP_PASSWORD and MAIL_FROM, and otherwise a FileMailer writing to
+// MAIL_DIR, ./mail-outbox by default.
+func FromEnv() Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "chi-blog <noreply@chatter.pw>"
+	}
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		return SMTPMailer{Addr: addr, Username: os.Getenv("SMTP_USERNAME"), Password: os.Getenv("SMTP_PASSWORD"), From: from}
+	}
+	dir := os.Getenv("MAIL_DIR")
+	if dir == "" {
+		dir = "./mail-outbox"
+	}
+	log.Printf("SMTP_ADDR is not set. Mail is written to %s instead of being sent.", dir)
+	return FileMailer{Dir: dir, From: from}
+}
+
+// encode formats msg as an RFC 5322 message from from.
+func encode(from string, msg Message) ([]byte, error) {
+	for _, v := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, fmt.Errorf("mail: line break in header value %q", v)
+		}
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	names := make([]string, 0, len(msg.Headers))
+	for name := range msg.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.ContainsAny(name+msg.Headers[name], "\r\n") {
+			return nil, fmt.Errorf("mail: line break in header %s", name)
+		}
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, msg.Headers[name])
+	}
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(msg.Body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -7,13 +7,26 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"fmt"
 
+	"github.com/gg582/chi-blog/blog-backend/activitypub"
+	"github.com/gg582/chi-blog/blog-backend/auth"
+	"github.com/gg582/chi-blog/blog-backend/contact"
 	"github.com/gg582/chi-blog/blog-backend/database"
+	"github.com/gg582/chi-blog/blog-backend/events"
+	"github.com/gg582/chi-blog/blog-backend/mail"
+	"github.com/gg582/chi-blog/blog-backend/newsletter"
+	"github.com/gg582/chi-blog/blog-backend/ratelimit"
+	"github.com/gg582/chi-blog/blog-backend/site"
+	"github.com/gg582/chi-blog/blog-backend/stats"
+	"github.com/gg582/chi-blog/blog-backend/webhooks"
+	"github.com/gg582/chi-blog/blog-backend/webmention"
 	"github.com/gg582/chi-blog/blog-backend/workerpool"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -28,6 +41,7 @@ import (
 const (
 	numWorkers  = 5
 	jobQueueSize = 48
+	viewQueueSize = 1024
 )
 
 func fileExists(path string) bool {
@@ -77,6 +91,7 @@ func main() {
 				ExposedHeaders: []string{
 					"Link",
 					"X-Total-Count",
+					"X-Form-Token",
 				},
 				// Allow credentials for cookie-based authentication
 				AllowCredentials: true,
@@ -91,23 +106,105 @@ func main() {
             // --- START OF CHANGES ---
             // 1. Rename ImageJobQueue to FileJobQueue
             handlers.FileJobQueue = make(chan workerpool.UploadJob, jobQueueSize)
-            workerpool.NewWorkerPool(numWorkers, handlers.FileJobQueue)
+            workerpool.Start(numWorkers, handlers.FileJobQueue, workerpool.ProcessUpload)
             // --- END OF CHANGES ---
 
+			webmention.JobQueue = make(chan webmention.Job, jobQueueSize)
+			workerpool.Start(numWorkers, webmention.JobQueue, webmention.Process)
+			activitypub.JobQueue = make(chan activitypub.Delivery, jobQueueSize)
+			workerpool.Start(numWorkers, activitypub.JobQueue, activitypub.Process)
+			webhooks.JobQueue = make(chan webhooks.Job, jobQueueSize)
+			workerpool.Start(numWorkers, webhooks.JobQueue, webhooks.Process)
+			events.Subscribe(webhooks.Dispatch)
+			stats.ViewQueue = make(chan stats.View, viewQueueSize)
+			stats.NewRecorder(stats.ViewQueue, 30*time.Second, 500)
+			// Write the view counts still batched in memory before exiting.
+			go func() {
+				sig := make(chan os.Signal, 1)
+				signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+				<-sig
+				stats.Stop()
+				os.Exit(0)
+			}()
+			mailer := mail.FromEnv()
+			newsletter.Mailer = mailer
+			// Contact messages are always kept in the inbox, and are also
+			// mailed to CONTACT_EMAIL and posted to CONTACT_WEBHOOK_URL.
+			if to := os.Getenv("CONTACT_EMAIL"); to != "" {
+				contact.Notifiers = append(contact.Notifiers, contact.MailNotifier{Mailer: mailer, To: to})
+			}
+			if hook := os.Getenv("CONTACT_WEBHOOK_URL"); hook != "" {
+				contact.Notifiers = append(contact.Notifiers, contact.WebhookNotifier{URL: hook})
+			}
+			// DIGEST_INTERVAL, e.g. 24h, mails new posts from the server
+			// itself. Without it, run "newsletter digest" from cron.
+			if v := os.Getenv("DIGEST_INTERVAL"); v != "" {
+				interval, err := time.ParseDuration(v)
+				if err != nil || interval < time.Minute {
+					log.Fatalf("Invalid DIGEST_INTERVAL %q, expected a duration of at least 1m", v)
+				}
+				newsletter.StartDigest(interval)
+			}
+			if siteURL := os.Getenv("SITE_URL"); siteURL != "" {
+				site.URL = strings.TrimSuffix(siteURL, "/")
+			}
+			if apiURL := os.Getenv("API_URL"); apiURL != "" {
+				site.APIURL = strings.TrimSuffix(apiURL, "/")
+			}
+
+			// Per-IP request limits. Reads are generous, writes are not.
+			readLimiter := ratelimit.NewLimiter(120, time.Minute, 30)
+			writeLimiter := ratelimit.NewLimiter(20, time.Minute, 5)
+			// Readers may post a few comments in a row, then one every two minutes.
+			commentLimiter := ratelimit.NewLimiter(5, 10*time.Minute, 3)
+			// Every subscription request sends a mail; keep that rare.
+			subscribeLimiter := ratelimit.NewLimiter(5, time.Hour, 3)
+			contactLimiter := ratelimit.NewLimiter(5, time.Hour, 3)
+
 			// Define your routes
-			r.Post("/api/posts", handlers.GetPostsHandler)
-			r.Post("/api/posts/{id}", handlers.GetPostByIDHandler)
+			r.With(readLimiter.Middleware).Post("/api/posts", handlers.GetPostsHandler)
+			r.With(readLimiter.Middleware).Post("/api/posts/{id}", handlers.GetPostByIDHandler)
+			r.With(readLimiter.Middleware).Get("/api/posts/{id}/comments", handlers.GetCommentsHandler)
+			r.With(commentLimiter.Middleware).Post("/api/posts/{id}/comments", handlers.CreateCommentHandler)
+			r.With(readLimiter.Middleware).Get("/api/posts/{id}/webmentions", handlers.GetWebmentionsHandler)
+			r.With(readLimiter.Middleware).Get("/api/series", handlers.GetSeriesListHandler)
+			r.With(readLimiter.Middleware).Get("/api/series/{id}", handlers.GetSeriesHandler)
+			r.With(readLimiter.Middleware).Get("/api/archive", handlers.GetArchiveHandler)
+			r.With(readLimiter.Middleware).Get("/api/archive/{year}/{month}", handlers.GetArchiveMonthHandler)
+			r.With(writeLimiter.Middleware).Post("/webmention", handlers.ReceiveWebmentionHandler)
+			r.Get("/.well-known/webfinger", handlers.WebFingerHandler)
+			r.Get("/ap/actor", handlers.ActorHandler)
+			r.Get("/ap/outbox", handlers.OutboxHandler)
+			r.Get("/ap/followers", handlers.FollowersHandler)
+			r.Get("/ap/posts/{id}", handlers.ArticleHandler)
+			r.With(writeLimiter.Middleware).Post("/ap/inbox", handlers.InboxHandler)
+			r.Get("/api/highlight.css", handlers.GetHighlightCSSHandler)
+			r.Get("/api/pages", handlers.GetPagesHandler)
+			r.Get("/api/pages/{slug}", handlers.GetPageHandler)
 			r.Get("/api/about", handlers.GetAboutPageHandler)
 			r.Get("/api/contact", handlers.GetContactPageHandler)
-			r.Post("/api/new-post/{id}", handlers.CreateNewPostHandler) 
+			r.With(contactLimiter.Middleware).Post("/api/contact", handlers.CreateContactMessageHandler)
+			r.With(writeLimiter.Middleware, auth.RequireAuth, auth.RequireScope(auth.ScopePostsWrite), auth.CSRF).Post("/api/new-post/{id}", handlers.CreateNewPostHandler) 
             
             // --- START OF CHANGES ---
             // 2. Rename route from /api/upload-image to /api/upload-file
             // 3. Rename handler from handlers.UploadImage to handlers.UploadFile
-            r.Post("/api/upload-file", handlers.UploadFile)
+            r.With(writeLimiter.Middleware, auth.RequireAuth, auth.RequireScope(auth.ScopeUploadsWrite), auth.CSRF).Post("/api/upload-file", handlers.UploadFile)
             // --- END OF CHANGES ---
             
+			r.With(subscribeLimiter.Middleware).Post("/api/subscribe", handlers.SubscribeHandler)
+			r.With(writeLimiter.Middleware).Post("/api/subscribe/confirm", handlers.ConfirmSubscriptionHandler)
+			r.With(writeLimiter.Middleware).Post("/api/unsubscribe", handlers.UnsubscribeHandler)
 			r.Post("/api/login", handlers.LoginHandler)
+			r.Post("/api/login/2fa", handlers.TwoFactorLoginHandler)
+			r.With(auth.RequireSession).Get("/api/csrf-token", handlers.CSRFTokenHandler)
+			r.With(auth.RequireSession, auth.CSRF).Post("/api/logout", handlers.LogoutHandler)
+			r.With(auth.RequireSession).Get("/api/audit", handlers.GetAuditLogHandler)
+			r.With(auth.RequireSession).Get("/api/stats", handlers.GetStatsHandler)
+			r.With(auth.RequireSession).Get("/api/comments", handlers.GetCommentQueueHandler)
+			r.With(auth.RequireSession, auth.CSRF).Post("/api/comments/{commentID}/{action}", handlers.ModerateCommentHandler)
+			r.With(auth.RequireSession).Get("/api/contact/messages", handlers.GetContactMessagesHandler)
+			r.With(auth.RequireSession, auth.CSRF).Post("/api/contact/messages/{messageID}/{status}", handlers.MarkContactMessageHandler)
             fileServer := http.FileServer(http.Dir("./posts/assets")) 
         	r.Handle("/assets/*", http.StripPrefix("/assets/", fileServer))
 
@@ -240,6 +337,13 @@ func main() {
 	}
 
 	chiBlog.AddCommand(initAdmin)
+	chiBlog.AddCommand(newUserCommand())
+	chiBlog.AddCommand(newTokenCommand())
+	chiBlog.AddCommand(newAuditCommand())
+	chiBlog.AddCommand(newCheckCommand())
+	chiBlog.AddCommand(newBackfillDatesCommand())
+	chiBlog.AddCommand(newNewsletterCommand())
+	chiBlog.AddCommand(newWebhookCommand())
 	// Execute the blog command
 	if err := chiBlog.Execute(); err != nil {
 		log.Println(err)
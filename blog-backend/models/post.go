@@ -4,17 +4,154 @@ import "time"
 
 // Post struct defines the data for a blog post.
 type Post struct {
-	ID          string    `json:"id"`
-	Title       string    `json:"title"`
-	ContentHTML string    `json:"contentHtml"` // Markdown content rendered to HTML
-	Author      string    `json:"author"`
-	CreatedAt   time.Time `json:"createdAt"`
-	FileName    string    `json:"fileName"` // Original markdown file name (for debugging)
+	ID           string     `json:"id"`
+	Title        string     `json:"title"`
+	ContentHTML  string     `json:"contentHtml"` // Markdown content rendered to HTML
+	Author       string     `json:"author"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	FileName     string     `json:"fileName"`     // Original markdown file name (for debugging)
+	TOC          []TOCEntry `json:"toc"`          // Table of contents built from the post's headings
+	Summary      string     `json:"summary"`      // Plain-text excerpt for listings
+	WordCount    int        `json:"wordCount"`    // Words, with each CJK character counted as one
+	ReadingTime  int        `json:"readingTime"`  // Estimated reading time in minutes
+	CommentCount int        `json:"commentCount"` // Approved comments
+	Tags         []string   `json:"tags"`         // From the "tags" front matter key, lower-cased
+	Series       *SeriesNav `json:"series,omitempty"`
+	Draft        bool       `json:"draft,omitempty"` // Left out of listings, the archive and navigation
+	// Related lists similar posts, and Previous and Next are the posts
+	// published before and after this one. They are only filled in for a
+	// single post.
+	Related  []RelatedPost `json:"related,omitempty"`
+	Previous *PostLink     `json:"previous,omitempty"`
+	Next     *PostLink     `json:"next,omitempty"`
+}
+
+// PostLink points to another post.
+type PostLink struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// SeriesNav places a post in the series named by its "series" front matter
+// key. Part, Total, Previous and Next are only filled in for a single post.
+type SeriesNav struct {
+	ID       string    `json:"id"` // Slug of the series title
+	Title    string    `json:"title"`
+	Order    int       `json:"order"` // From the "series_order" front matter key
+	Part     int       `json:"part,omitempty"`
+	Total    int       `json:"total,omitempty"`
+	Previous *PostLink `json:"previous,omitempty"`
+	Next     *PostLink `json:"next,omitempty"`
+}
+
+// Series is a multi-part series with its posts in reading order.
+type Series struct {
+	ID    string       `json:"id"`
+	Title string       `json:"title"`
+	Posts []SeriesPart `json:"posts"`
+}
+
+// SeriesPart is a post as listed in its series, without its content.
+type SeriesPart struct {
+	ID           string    `json:"id"`
+	Title        string    `json:"title"`
+	Summary      string    `json:"summary"`
+	CreatedAt    time.Time `json:"createdAt"`
+	ReadingTime  int       `json:"readingTime"`
+	CommentCount int       `json:"commentCount"`
+}
+
+// ArchiveYear is a year of the archive with its number of posts.
+type ArchiveYear struct {
+	Year   int            `json:"year"`
+	Count  int            `json:"count"`
+	Months []ArchiveMonth `json:"months"` // Newest first, only months with posts
+}
+
+// ArchiveMonth is a month of the archive with its number of posts.
+type ArchiveMonth struct {
+	Month int `json:"month"` // 1 to 12
+	Count int `json:"count"`
+}
+
+// SeriesEntry is a series as listed by the series index.
+type SeriesEntry struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	PostCount int    `json:"postCount"`
+}
+
+// RelatedPost is a post similar to the one being read.
+type RelatedPost struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+}
+
+// TOCEntry is a heading in a post's table of contents. Children holds the
+// headings nested below it.
+type TOCEntry struct {
+	ID       string     `json:"id"` // Anchor of the heading, usable as "#id"
+	Title    string     `json:"title"`
+	Level    int        `json:"level"`
+	Children []TOCEntry `json:"children,omitempty"`
+}
+
+// GetPostRequest is the optional JSON body for fetching a single post.
+type GetPostRequest struct {
+	Referrer string `json:"referrer"` // document.referrer of the reader's page, for view stats
 }
 
 // NewPostRequest struct defines the expected JSON structure for creating a new post.
+// The optional fields set front matter keys; when one is left out, an
+// existing post keeps its value.
 type NewPostRequest struct {
-	Title   string `json:"title"`
-	Author  string `json:"author"`
-	Content string `json:"content"` // Markdown content
-}
\ No newline at end of file
+	Title       string   `json:"title"`
+	Author      string   `json:"author"`
+	Content     string   `json:"content"` // Markdown content
+	Trusted     bool     `json:"trusted"` // Skip HTML sanitization; only honoured for logged-in admins
+	Draft       *bool    `json:"draft"`
+	Tags        []string `json:"tags"` // [] removes the tags
+	Series      *string  `json:"series"`
+	SeriesOrder *int     `json:"seriesOrder"`
+	Summary     *string  `json:"summary"`
+}
+
+// NewCommentRequest is the JSON body for posting a comment.
+type NewCommentRequest struct {
+	Author   string `json:"author"`
+	Email    string `json:"email"`    // Optional, only stored hashed
+	Body     string `json:"body"`     // Markdown, rendered with a restricted policy
+	ParentID int64  `json:"parentId"` // Comment being replied to, 0 for none
+	Website  string `json:"website"`  // Honeypot, left empty by people
+}
+
+// SubscribeRequest is the JSON body for subscribing to the newsletter.
+type SubscribeRequest struct {
+	Email   string `json:"email"`
+	Website string `json:"website"` // Honeypot, left empty by people
+}
+
+// ContactRequest is the JSON body of a message sent through the contact
+// form.
+type ContactRequest struct {
+	Name      string `json:"name"`
+	Email     string `json:"email"` // Optional, for replies
+	Subject   string `json:"subject"`
+	Message   string `json:"message"`
+	Website   string `json:"website"`   // Honeypot, left empty by people
+	FormToken string `json:"formToken"` // From GET /api/contact, records when the form was shown
+}
+
+// TokenRequest carries a token from a link in a mail.
+type TokenRequest struct {
+	Token string `json:"token"`
+}
+
+// NavEntry is a static page as listed in the site navigation.
+type NavEntry struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	NavTitle  string `json:"navTitle"`  // Menu label, from nav_title or the page title
+	MenuOrder int    `json:"menuOrder"` // Lower comes first
+}
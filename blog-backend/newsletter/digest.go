@@ -0,0 +1,165 @@
+package newsletter
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/content"
+	"github.com/gg582/chi-blog/blog-backend/database"
+	"github.com/gg582/chi-blog/blog-backend/mail"
+	"github.com/gg582/chi-blog/blog-backend/models"
+	"github.com/gg582/chi-blog/blog-backend/site"
+)
+
+// baselineMarker is the newsletter_posts row written by the first digest run.
+// Posts that existed then are treated as already sent.
+const baselineMarker = ""
+
+// Digest mails every confirmed subscriber the posts published since the
+// last digest and returns how many posts and mails went out. The first run
+// only records the existing posts, so subscribers are not sent the archive.
+// Posts stay unsent, and are retried next time, if no mail could be sent.
+func Digest() (posts, sent int, err error) {
+	all, err := content.Posts()
+	if err != nil {
+		return 0, 0, err
+	}
+	done := map[string]bool{}
+	rows, err := database.DB.Query("SELECT post_id FROM newsletter_posts")
+	if err != nil {
+		return 0, 0, err
+	}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		done[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	if !done[baselineMarker] {
+		ids := []string{baselineMarker}
+		for _, p := range all {
+			ids = append(ids, p.ID)
+		}
+		log.Printf("First newsletter digest: marking %d existing posts as sent.", len(all))
+		return 0, 0, markSent(ids)
+	}
+
+	var fresh []models.Post
+	for _, p := range all {
+		if !done[p.ID] {
+			fresh = append(fresh, p)
+		}
+	}
+	if len(fresh) == 0 {
+		return 0, 0, nil
+	}
+	sort.Slice(fresh, func(i, j int) bool { return fresh[i].CreatedAt.Before(fresh[j].CreatedAt) })
+
+	type recipient struct{ email, unsubscribeToken string }
+	var recipients []recipient
+	rows, err = database.DB.Query("SELECT email, unsubscribe_token FROM subscribers WHERE status = ?", StatusConfirmed)
+	if err != nil {
+		return 0, 0, err
+	}
+	for rows.Next() {
+		var r recipient
+		if err := rows.Scan(&r.email, &r.unsubscribeToken); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		recipients = append(recipients, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	subject := fmt.Sprintf("%d new posts on %s", len(fresh), site.Host())
+	if len(fresh) == 1 {
+		subject = fresh[0].Title + " - " + site.Host()
+	}
+	body := digestBody(fresh)
+	var lastErr error
+	for _, r := range recipients {
+		unsubscribe := site.URL + "/newsletter/unsubscribe?token=" + url.QueryEscape(r.unsubscribeToken)
+		err := Mailer.Send(mail.Message{
+			To:      r.email,
+			Subject: subject,
+			Body:    body + "\n--\nUnsubscribe: " + unsubscribe + "\n",
+			Headers: map[string]string{
+				// One-click unsubscribe (RFC 8058) posts to the API directly.
+				"List-Unsubscribe":      "<" + site.APIURL + "/api/unsubscribe?token=" + url.QueryEscape(r.unsubscribeToken) + ">",
+				"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+			},
+		})
+		if err != nil {
+			log.Printf("Failed to mail digest to subscriber: %v", err)
+			lastErr = err
+			continue
+		}
+		sent++
+	}
+	if len(recipients) > 0 && sent == 0 {
+		return 0, 0, fmt.Errorf("no digest could be sent: %w", lastErr)
+	}
+
+	ids := make([]string, len(fresh))
+	for i, p := range fresh {
+		ids[i] = p.ID
+	}
+	return len(fresh), sent, markSent(ids)
+}
+
+func digestBody(posts []models.Post) string {
+	var b strings.Builder
+	b.WriteString("New on " + site.URL + ":\n")
+	for _, p := range posts {
+		b.WriteString("\n" + p.Title + "\n" + site.PostURL(p.ID) + "\n")
+		if p.Summary != "" {
+			b.WriteString("\n" + p.Summary + "\n")
+		}
+	}
+	return b.String()
+}
+
+func markSent(ids []string) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	now := Now().Unix()
+	for _, id := range ids {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO newsletter_posts (post_id, sent_at) VALUES (?, ?)", id, now); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// StartDigest runs Digest every interval in the background.
+func StartDigest(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			posts, sent, err := Digest()
+			if err != nil {
+				log.Printf("Newsletter digest failed: %v", err)
+				continue
+			}
+			if posts > 0 {
+				log.Printf("Newsletter digest: %d posts mailed to %d subscribers.", posts, sent)
+			}
+		}
+	}()
+}
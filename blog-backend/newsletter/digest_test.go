@@ -0,0 +1,116 @@
+package newsletter
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gg582/chi-blog/blog-backend/mail"
+)
+
+func writePost(t *testing.T, slug, source string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join("posts", slug+".md"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// subscribe adds a confirmed subscriber for each address.
+func subscribe(t *testing.T, outbox string, emails ...string) {
+	t.Helper()
+	for _, email := range emails {
+		if err := Subscribe(email); err != nil {
+			t.Fatal(err)
+		}
+		if err := Confirm(confirmToken(t, outbox)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// recipients returns the sorted To addresses of sent, and fails the test
+// unless every mail lists exactly the posts titled want.
+func recipients(t *testing.T, sent []string, want ...string) []string {
+	t.Helper()
+	var to []string
+	for _, m := range sent {
+		for _, line := range strings.Split(m, "\r\n") {
+			if addr, ok := strings.CutPrefix(line, "To: "); ok {
+				to = append(to, addr)
+			}
+		}
+		for _, title := range []string{"Old", "Draft", "First", "Second"} {
+			if listed := strings.Contains(m, "\n"+title+"\r\n"); listed != slices.Contains(want, title) {
+				t.Errorf("digest lists %q: %v, want only %v:\n%s", title, listed, want, m)
+			}
+		}
+	}
+	slices.Sort(to)
+	return to
+}
+
+func TestDigest(t *testing.T) {
+	outbox, _ := setupNewsletter(t)
+	writePost(t, "old", "---\ndate: 2026-01-01\n---\n# Old\n")
+	subscribe(t, outbox, "ann@example.com", "bob@example.com")
+	// A subscriber who has not confirmed gets nothing.
+	if err := Subscribe("pending@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	mails(t, outbox)
+
+	// The first run only records the archive.
+	if posts, sent, err := Digest(); err != nil || posts != 0 || sent != 0 {
+		t.Fatalf("first Digest = %d, %d, %v, want nothing sent", posts, sent, err)
+	}
+	if sent := mails(t, outbox); len(sent) != 0 {
+		t.Fatalf("first Digest mailed %d, want none", len(sent))
+	}
+
+	writePost(t, "draft", "---\ndate: 2026-10-01\ndraft: true\n---\n# Draft\n")
+	writePost(t, "first", "---\ndate: 2026-10-02\n---\n# First\n")
+	writePost(t, "second", "---\ndate: 2026-10-03\n---\n# Second\n")
+	posts, n, err := Digest()
+	if err != nil || posts != 2 || n != 2 {
+		t.Fatalf("Digest = %d posts to %d, %v, want 2 to 2", posts, n, err)
+	}
+	if to := recipients(t, mails(t, outbox), "First", "Second"); !slices.Equal(to, []string{"ann@example.com", "bob@example.com"}) {
+		t.Errorf("digest went to %v, want ann and bob", to)
+	}
+
+	// Nothing new, nothing sent.
+	if posts, n, err := Digest(); err != nil || posts != 0 || n != 0 {
+		t.Errorf("Digest without new posts = %d, %d, %v", posts, n, err)
+	}
+	if sent := mails(t, outbox); len(sent) != 0 {
+		t.Errorf("Digest without new posts mailed %d", len(sent))
+	}
+}
+
+func TestDigestMailFailure(t *testing.T) {
+	outbox, _ := setupNewsletter(t)
+	subscribe(t, outbox, "ann@example.com")
+	if _, _, err := Digest(); err != nil {
+		t.Fatal(err)
+	}
+	writePost(t, "first", "---\ndate: 2026-10-02\n---\n# First\n")
+
+	// A file where the outbox directory should be makes every mail fail.
+	blocked := filepath.Join(t.TempDir(), "blocked")
+	if err := os.WriteFile(blocked, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	Mailer = mail.FileMailer{Dir: blocked, From: "blog@example.com"}
+	if posts, n, err := Digest(); err == nil || posts != 0 || n != 0 {
+		t.Fatalf("Digest with a failing mailer = %d, %d, %v, want an error", posts, n, err)
+	}
+
+	// The post was not marked as sent, so the next run mails it.
+	Mailer = mail.FileMailer{Dir: outbox, From: "blog@example.com"}
+	if posts, n, err := Digest(); err != nil || posts != 1 || n != 1 {
+		t.Fatalf("Digest after the failure = %d, %d, %v, want 1 post to 1", posts, n, err)
+	}
+	recipients(t, mails(t, outbox), "First")
+}
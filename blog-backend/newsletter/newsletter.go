@@ -0,0 +1,181 @@
+// Package newsletter manages email subscribers with double opt-in and mails
+// them a digest of newly published posts.
+package newsletter
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+	"github.com/gg582/chi-blog/blog-backend/mail"
+	"github.com/gg582/chi-blog/blog-backend/site"
+)
+
+// Subscriber states.
+const (
+	StatusPending      = "pending"
+	StatusConfirmed    = "confirmed"
+	StatusUnsubscribed = "unsubscribed"
+)
+
+// ConfirmTTL is how long a confirmation link stays valid.
+const ConfirmTTL = 48 * time.Hour
+
+// Now returns the current time. Tests may replace it.
+var Now = time.Now
+
+// ErrInvalidToken is returned for unknown or expired tokens.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Mailer sends confirmation and digest mails. The server sets it from the
+// environment with mail.FromEnv.
+var Mailer mail.Mailer
+
+// Subscriber is an email address on the list.
+type Subscriber struct {
+	ID          int64      `json:"id"`
+	Email       string     `json:"email"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ConfirmedAt *time.Time `json:"confirmedAt,omitempty"`
+}
+
+// Normalize returns the form in which email is stored.
+func Normalize(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Subscribe adds email as a pending subscriber and mails it a confirmation
+// link. Asking again sends a new link once the last one has expired, so the
+// form cannot be used to flood an address. Confirmed subscribers are left
+// alone and get no mail, so the result does not reveal who is subscribed.
+func Subscribe(email string) error {
+	email = Normalize(email)
+	now := Now()
+	var status, unsubscribeToken string
+	var expires int64
+	err := database.DB.QueryRow("SELECT status, unsubscribe_token, confirm_expires_at FROM subscribers WHERE email = ?", email).Scan(&status, &unsubscribeToken, &expires)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if status == StatusConfirmed || status == StatusPending && expires >= now.Unix() {
+		return nil
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+	if unsubscribeToken == "" {
+		if unsubscribeToken, err = randomToken(); err != nil {
+			return err
+		}
+	}
+	_, err = database.DB.Exec(
+		`INSERT INTO subscribers (email, status, confirm_token_hash, confirm_expires_at, unsubscribe_token, created_at)
+		VALUES (?,?,?,?,?,?)
+		ON CONFLICT (email) DO UPDATE SET status = excluded.status, confirm_token_hash = excluded.confirm_token_hash,
+			confirm_expires_at = excluded.confirm_expires_at`,
+		email, StatusPending, hashToken(token), now.Add(ConfirmTTL).Unix(), unsubscribeToken, now.Unix(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return Mailer.Send(mail.Message{
+		To:      email,
+		Subject: "Confirm your subscription to " + site.Host(),
+		Body: "Someone, hopefully you, asked to receive new posts from " + site.URL + " by email.\n\n" +
+			"Confirm your subscription within 48 hours by opening this link:\n\n" +
+			site.URL + "/newsletter/confirm?token=" + url.QueryEscape(token) + "\n\n" +
+			"If this was not you, ignore this message and nothing will be sent.\n",
+	})
+}
+
+// Confirm activates the subscription that token was mailed for.
+func Confirm(token string) error {
+	res, err := database.DB.Exec(
+		`UPDATE subscribers SET status = ?, confirmed_at = ?, confirm_token_hash = ''
+		WHERE confirm_token_hash = ? AND confirm_expires_at >= ? AND status = ?`,
+		StatusConfirmed, Now().Unix(), hashToken(token), Now().Unix(), StatusPending,
+	)
+	return tokenResult(res, err, token)
+}
+
+// Unsubscribe ends the subscription with the unsubscribe token from a
+// digest mail. Unsubscribing twice is not an error.
+func Unsubscribe(token string) error {
+	res, err := database.DB.Exec(
+		"UPDATE subscribers SET status = ?, unsubscribed_at = COALESCE(unsubscribed_at, ?) WHERE unsubscribe_token = ?",
+		StatusUnsubscribed, Now().Unix(), token,
+	)
+	return tokenResult(res, err, token)
+}
+
+func tokenResult(res sql.Result, err error, token string) error {
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return ErrInvalidToken
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// Subscribers lists the subscribers with status, or all when it is empty.
+func Subscribers(status string) ([]Subscriber, error) {
+	query := "SELECT id, email, status, created_at, confirmed_at FROM subscribers"
+	var args []any
+	if status != "" {
+		query += " WHERE status = ?"
+		args = append(args, status)
+	}
+	rows, err := database.DB.Query(query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscribers := []Subscriber{}
+	for rows.Next() {
+		var s Subscriber
+		var created int64
+		var confirmed sql.NullInt64
+		if err := rows.Scan(&s.ID, &s.Email, &s.Status, &created, &confirmed); err != nil {
+			return nil, err
+		}
+		s.CreatedAt = time.Unix(created, 0)
+		if confirmed.Valid {
+			t := time.Unix(confirmed.Int64, 0)
+			s.ConfirmedAt = &t
+		}
+		subscribers = append(subscribers, s)
+	}
+	return subscribers, rows.Err()
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken returns the form in which confirmation tokens are stored, so a
+// copy of the database cannot confirm addresses.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
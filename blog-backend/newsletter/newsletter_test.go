@@ -0,0 +1,202 @@
+package newsletter
+
+import (
+	"errors"
+	"io"
+	"mime/quotedprintable"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+	"github.com/gg582/chi-blog/blog-backend/mail"
+)
+
+// setupNewsletter runs the test in a fresh directory with an empty ./posts
+// and database, freezes the clock and writes mail to the returned
+// directory.
+func setupNewsletter(t *testing.T) (outbox string, now *time.Time) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.Mkdir("posts", 0755); err != nil {
+		t.Fatal(err)
+	}
+	database.Path = filepath.Join(dir, "auth.db")
+	database.InitDatabase()
+	t.Cleanup(func() { database.DB.Close() })
+
+	outbox = filepath.Join(dir, "outbox")
+	oldMailer := Mailer
+	Mailer = mail.FileMailer{Dir: outbox, From: "blog@example.com"}
+	t.Cleanup(func() { Mailer = oldMailer })
+
+	at := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	Now = func() time.Time { return at }
+	t.Cleanup(func() { Now = time.Now })
+	return outbox, &at
+}
+
+// mails returns the mails written to outbox since the last call, with
+// their bodies decoded, and removes them.
+func mails(t *testing.T, outbox string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(outbox, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent []string
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, body, _ := strings.Cut(string(data), "\r\n\r\n")
+		decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sent = append(sent, header+"\r\n\r\n"+string(decoded))
+		os.Remove(f)
+	}
+	return sent
+}
+
+var confirmLink = regexp.MustCompile(`confirm\?token=(\S+)`)
+
+// confirmToken returns the token from the single confirmation mail in
+// outbox.
+func confirmToken(t *testing.T, outbox string) string {
+	t.Helper()
+	sent := mails(t, outbox)
+	if len(sent) != 1 {
+		t.Fatalf("%d confirmation mails, want 1", len(sent))
+	}
+	m := confirmLink.FindStringSubmatch(sent[0])
+	if m == nil {
+		t.Fatalf("no confirmation link in:\n%s", sent[0])
+	}
+	token, err := url.QueryUnescape(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func status(t *testing.T, email string) string {
+	t.Helper()
+	var s string
+	if err := database.DB.QueryRow("SELECT status FROM subscribers WHERE email = ?", email).Scan(&s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestConfirm(t *testing.T) {
+	outbox, now := setupNewsletter(t)
+
+	if err := Subscribe(" Ann@Example.com "); err != nil {
+		t.Fatal(err)
+	}
+	token := confirmToken(t, outbox)
+	if err := Confirm("wrong"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Confirm with a wrong token: %v, want ErrInvalidToken", err)
+	}
+	if err := Confirm(""); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Confirm with no token: %v, want ErrInvalidToken", err)
+	}
+	*now = now.Add(ConfirmTTL - time.Minute)
+	if err := Confirm(token); err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	if got := status(t, "ann@example.com"); got != StatusConfirmed {
+		t.Errorf("status = %s, want confirmed", got)
+	}
+	if err := Confirm(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("second Confirm: %v, want ErrInvalidToken", err)
+	}
+
+	// Subscribing again does not tell a confirmed address anything.
+	if err := Subscribe("ann@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if sent := mails(t, outbox); len(sent) != 0 {
+		t.Errorf("confirmed subscriber got %d mails, want none", len(sent))
+	}
+}
+
+func TestConfirmExpired(t *testing.T) {
+	outbox, now := setupNewsletter(t)
+
+	if err := Subscribe("bob@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token := confirmToken(t, outbox)
+
+	// While the link is valid, asking again sends nothing.
+	*now = now.Add(ConfirmTTL / 2)
+	if err := Subscribe("bob@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if sent := mails(t, outbox); len(sent) != 0 {
+		t.Errorf("%d mails while the first link is valid, want none", len(sent))
+	}
+
+	*now = now.Add(ConfirmTTL)
+	if err := Confirm(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Confirm with an expired token: %v, want ErrInvalidToken", err)
+	}
+	if got := status(t, "bob@example.com"); got != StatusPending {
+		t.Errorf("status = %s, want pending", got)
+	}
+
+	// Once it has expired, a new link is sent and works.
+	if err := Subscribe("bob@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Confirm(confirmToken(t, outbox)); err != nil {
+		t.Errorf("Confirm with the new token: %v", err)
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	outbox, _ := setupNewsletter(t)
+
+	if err := Subscribe("cid@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Confirm(confirmToken(t, outbox)); err != nil {
+		t.Fatal(err)
+	}
+	var token string
+	if err := database.DB.QueryRow("SELECT unsubscribe_token FROM subscribers WHERE email = ?", "cid@example.com").Scan(&token); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bad := range []string{"", "wrong", strings.ToUpper(token)} {
+		if err := Unsubscribe(bad); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Unsubscribe(%q): %v, want ErrInvalidToken", bad, err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := Unsubscribe(token); err != nil {
+			t.Fatalf("Unsubscribe #%d: %v", i+1, err)
+		}
+	}
+	if got := status(t, "cid@example.com"); got != StatusUnsubscribed {
+		t.Errorf("status = %s, want unsubscribed", got)
+	}
+
+	// Subscribing again asks for confirmation anew.
+	if err := Subscribe("cid@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	confirmToken(t, outbox)
+	if got := status(t, "cid@example.com"); got != StatusPending {
+		t.Errorf("status after subscribing again = %s, want pending", got)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+	"github.com/gg582/chi-blog/blog-backend/mail"
+	"github.com/gg582/chi-blog/blog-backend/newsletter"
+)
+
+// newNewsletterCommand builds the "newsletter" command tree.
+func newNewsletterCommand() *cobra.Command {
+	var newsletterCmd = &cobra.Command{
+		Use:   "newsletter",
+		Short: "Manage newsletter subscribers and send digests",
+	}
+
+	var digest = &cobra.Command{
+		Use:   "digest",
+		Short: "Mail newly published posts to confirmed subscribers",
+		Long: `Mail the posts published since the last digest to every confirmed subscriber.
+The first run only records the existing posts. Mail goes through SMTP_ADDR, or
+into MAIL_DIR when it is not set. Suitable for cron.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			database.InitDatabase()
+			newsletter.Mailer = mail.FromEnv()
+			posts, sent, err := newsletter.Digest()
+			if err != nil {
+				log.Fatalf("Failed to send digest: %v", err)
+			}
+			log.Printf("Digest of %d posts mailed to %d subscribers.", posts, sent)
+		},
+	}
+
+	var status string
+	var list = &cobra.Command{
+		Use:   "list",
+		Short: "List subscribers",
+		Run: func(cmd *cobra.Command, args []string) {
+			database.InitDatabase()
+			subscribers, err := newsletter.Subscribers(status)
+			if err != nil {
+				log.Fatalf("Failed to list subscribers: %v", err)
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tEMAIL\tSTATUS\tCREATED\tCONFIRMED")
+			for _, s := range subscribers {
+				confirmed := "-"
+				if s.ConfirmedAt != nil {
+					confirmed = s.ConfirmedAt.Format("2006-01-02 15:04")
+				}
+				fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", s.ID, s.Email, s.Status, s.CreatedAt.Format("2006-01-02 15:04"), confirmed)
+			}
+			tw.Flush()
+		},
+	}
+	list.Flags().StringVar(&status, "status", "", "only subscribers with this status (pending, confirmed, unsubscribed)")
+
+	newsletterCmd.AddCommand(digest, list)
+	return newsletterCmd
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+)
+
+// Backoff tuning for login attempts. The first freeAttempts failures are not
+// delayed; after that every failure doubles the wait, starting at baseDelay and
+// capped at maxDelay. Reaching lockoutThreshold locks the key for
+// lockoutDuration. Counters are forgotten after resetWindow without failures.
+const (
+	freeAttempts     = 3
+	baseDelay        = 2 * time.Second
+	maxDelay         = 5 * time.Minute
+	lockoutThreshold = 10
+	lockoutDuration  = 30 * time.Minute
+	resetWindow      = 24 * time.Hour
+)
+
+// Now is the clock used by the login limiter. Tests may replace it.
+var Now = time.Now
+
+// IPKey and UserKey build the keys under which login attempts are tracked.
+func IPKey(ip string) string     { return "ip:" + ip }
+func UserKey(name string) string { return "user:" + name }
+
+// Attempt reserves a login attempt for key before the password is checked.
+// The attempt is counted as a failure up front, together with the backoff it
+// causes, in a single statement, so concurrent requests cannot all slip
+// through before the first one fails; Reset clears the count when the login
+// succeeds. If key is still backing off, nothing is counted and the wait is
+// returned instead. locked is true when this attempt, should it fail, starts
+// a lockout: the first one at lockoutThreshold failures, and again for every
+// further failure after a lockout ends.
+func Attempt(key string) (wait time.Duration, locked bool, err error) {
+	now := Now().Unix()
+	// failures is the count including this attempt; SET expressions all see
+	// the row as it was, so it is spelled out wherever it is needed.
+	const failures = `(CASE WHEN ?1 - last_failure > ?2 THEN 1 ELSE failures + 1 END)`
+	var n int
+	err = database.DB.QueryRow(`INSERT INTO login_attempts (key, failures, last_failure, blocked_until) VALUES (?3, 1, ?1, ?4)
+		ON CONFLICT(key) DO UPDATE SET
+			failures = `+failures+`,
+			last_failure = ?1,
+			blocked_until = CASE
+				WHEN `+failures+` >= ?5 THEN ?1 + ?6
+				WHEN `+failures+` > ?7 THEN ?1 + MIN(?8 << (`+failures+` - ?7 - 1), ?9)
+				ELSE 0 END
+		WHERE login_attempts.blocked_until <= ?1
+		RETURNING failures`,
+		now, int64(resetWindow.Seconds()), key, blockedUntil(now, 1),
+		lockoutThreshold, int64(lockoutDuration.Seconds()), freeAttempts,
+		int64(baseDelay.Seconds()), int64(maxDelay.Seconds())).Scan(&n)
+	if err == nil {
+		return 0, n >= lockoutThreshold, nil
+	} else if err != sql.ErrNoRows {
+		return 0, false, err
+	}
+
+	// The update was skipped: key is blocked.
+	var until int64
+	if err := database.DB.QueryRow("SELECT blocked_until FROM login_attempts WHERE key = ?", key).Scan(&until); err != nil {
+		return 0, false, err
+	}
+	return time.Duration(until-now) * time.Second, false, nil
+}
+
+// blockedUntil returns when a key with failures failed attempts may try
+// again, or 0 if it need not wait. It mirrors the SQL in Attempt.
+func blockedUntil(now int64, failures int) int64 {
+	switch {
+	case failures >= lockoutThreshold:
+		return now + int64(lockoutDuration.Seconds())
+	case failures > freeAttempts:
+		delay := baseDelay << (failures - freeAttempts - 1)
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+		return now + int64(delay.Seconds())
+	}
+	return 0
+}
+
+// Reset clears the failure counter for key after a successful login.
+func Reset(key string) error {
+	_, err := database.DB.Exec("DELETE FROM login_attempts WHERE key = ?", key)
+	return err
+}
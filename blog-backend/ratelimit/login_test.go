@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+)
+
+func setupLogin(t *testing.T, now time.Time) *time.Time {
+	t.Helper()
+	database.Path = filepath.Join(t.TempDir(), "auth.db")
+	database.InitDatabase()
+	t.Cleanup(func() { database.DB.Close() })
+	clock := now
+	Now = func() time.Time { return clock }
+	t.Cleanup(func() { Now = time.Now })
+	return &clock
+}
+
+func TestAttemptBackoff(t *testing.T) {
+	clock := setupLogin(t, time.Unix(1700000000, 0))
+	key := IPKey("192.0.2.1")
+
+	for i := 1; i <= freeAttempts+1; i++ {
+		if wait, _, err := Attempt(key); err != nil || wait != 0 {
+			t.Fatalf("attempt %d: wait = %v, err = %v", i, wait, err)
+		}
+	}
+	wait, _, err := Attempt(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait != baseDelay {
+		t.Fatalf("wait after %d attempts = %v, want %v", freeAttempts+1, wait, baseDelay)
+	}
+
+	// Blocked attempts are not counted: the next wait is still doubled once.
+	*clock = clock.Add(baseDelay)
+	if wait, _, _ := Attempt(key); wait != 0 {
+		t.Fatalf("attempt after the delay: wait = %v", wait)
+	}
+	if wait, _, _ := Attempt(key); wait != 2*baseDelay {
+		t.Errorf("second delay = %v, want %v", wait, 2*baseDelay)
+	}
+
+	if err := Reset(key); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _, _ := Attempt(key); wait != 0 {
+		t.Errorf("attempt after Reset: wait = %v", wait)
+	}
+}
+
+func TestAttemptLockout(t *testing.T) {
+	clock := setupLogin(t, time.Unix(1700000000, 0))
+	key := UserKey("admin")
+
+	for i := 1; i <= lockoutThreshold; i++ {
+		wait, locked, err := Attempt(key)
+		for err == nil && wait > 0 {
+			*clock = clock.Add(wait)
+			wait, locked, err = Attempt(key)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked != (i == lockoutThreshold) {
+			t.Errorf("attempt %d: locked = %v", i, locked)
+		}
+	}
+	if wait, _, _ := Attempt(key); wait != lockoutDuration {
+		t.Errorf("wait after lockout = %v, want %v", wait, lockoutDuration)
+	}
+
+	// Each failure after a lockout ends locks the key again, and is
+	// reported so that it is audited.
+	for i := 1; i <= 2; i++ {
+		*clock = clock.Add(lockoutDuration)
+		wait, locked, err := Attempt(key)
+		if err != nil || wait != 0 || !locked {
+			t.Fatalf("attempt %d after lockout: wait = %v, locked = %v, err = %v", i, wait, locked, err)
+		}
+		if wait, _, _ := Attempt(key); wait != lockoutDuration {
+			t.Errorf("wait after lockout %d = %v, want %v", i+1, wait, lockoutDuration)
+		}
+	}
+
+	// The counter is forgotten after resetWindow.
+	*clock = clock.Add(resetWindow + time.Second)
+	for i := 1; i <= freeAttempts+1; i++ {
+		if wait, _, _ := Attempt(key); wait != 0 {
+			t.Fatalf("attempt %d after resetWindow: wait = %v", i, wait)
+		}
+	}
+}
+
+// TestAttemptConcurrent checks that parallel guesses cannot all pass before
+// the first of them fails.
+func TestAttemptConcurrent(t *testing.T) {
+	setupLogin(t, time.Unix(1700000000, 0))
+	key := IPKey("192.0.2.2")
+
+	const requests = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait, _, err := Attempt(key)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if wait == 0 {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != freeAttempts+1 {
+		t.Errorf("%d of %d concurrent attempts allowed, want %d", allowed, requests, freeAttempts+1)
+	}
+}
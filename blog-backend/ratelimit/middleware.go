@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/utils"
+)
+
+// bucket is a token bucket for a single client.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is an in-memory, per-IP token bucket limiter. Unlike the login
+// limiter it is not persisted; it only smooths out request floods.
+type Limiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens added per second
+	burst   float64
+	buckets map[string]*bucket
+}
+
+// NewLimiter allows requests per interval for each client IP, with bursts of
+// up to burst requests.
+func NewLimiter(requests int, interval time.Duration, burst int) *Limiter {
+	l := &Limiter{
+		rate:    float64(requests) / interval.Seconds(),
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+	go l.cleanup(10 * time.Minute)
+	return l
+}
+
+// Allow takes a token for key and reports whether the request may proceed.
+// When it may not, the returned duration tells when the next token is due.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// cleanup drops buckets that have been idle long enough to be full again.
+func (l *Limiter) cleanup(every time.Duration) {
+	for range time.Tick(every) {
+		l.mu.Lock()
+		for key, b := range l.buckets {
+			if time.Since(b.last).Seconds()*l.rate >= l.burst {
+				delete(l.buckets, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+// Middleware rejects requests with 429 Too Many Requests once a client IP
+// runs out of tokens.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := l.Allow(utils.ClientIP(r)); !ok {
+			TooManyRequests(w, wait)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// TooManyRequests writes a 429 response with a Retry-After header.
+func TooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]any{
+		"message":    "Too many requests. Please try again later.",
+		"code":       "RATE_LIMITED",
+		"retryAfter": seconds,
+	})
+}
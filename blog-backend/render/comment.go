@@ -0,0 +1,41 @@
+package render
+
+import (
+	"bytes"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// commentMarkdown renders reader comments. Raw HTML is dropped rather than
+// passed through, and only plain links are turned into anchors.
+var commentMarkdown = goldmark.New(goldmark.WithExtensions(
+	extension.Linkify,
+	extension.Strikethrough,
+))
+
+// commentPolicy allows the formatting a comment needs and nothing else:
+// no headings, images, tables or classes. Links are marked nofollow and
+// external ones open in a new tab.
+var commentPolicy = newCommentPolicy()
+
+func newCommentPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "em", "strong", "del", "code", "pre", "blockquote", "ul", "ol", "li")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowStandardURLs()
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	return p
+}
+
+// Comment renders a reader comment written in markdown to HTML.
+func Comment(source []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := commentMarkdown.Convert(source, &buf); err != nil {
+		return nil, err
+	}
+	return commentPolicy.SanitizeBytes(buf.Bytes()), nil
+}
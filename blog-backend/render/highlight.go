@@ -0,0 +1,46 @@
+package render
+
+import (
+	"io"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+)
+
+// DefaultHighlightStyle is the chroma style served when none is requested.
+// It matches the GitHub theme the frontend already uses.
+const DefaultHighlightStyle = "github"
+
+// highlightFormatOptions are shared by the renderer and the stylesheet so the
+// emitted class names always match the CSS.
+var highlightFormatOptions = []chromahtml.Option{
+	chromahtml.WithClasses(true),
+	chromahtml.LineNumbersInTable(true),
+}
+
+// newHighlighting highlights fenced code blocks on the server. Output uses
+// CSS classes instead of inline styles, so any theme from the stylesheet
+// endpoint applies. The fence info string accepts attributes, e.g.
+//
+//	```go {linenos=true hl_lines=[2,"4-6"] linenostart=10}
+//
+// Blocks in languages chroma does not know are left as plain <pre><code>.
+func newHighlighting() goldmark.Extender {
+	return highlighting.NewHighlighting(
+		highlighting.WithStyle(DefaultHighlightStyle),
+		highlighting.WithFormatOptions(highlightFormatOptions...),
+	)
+}
+
+// HighlightStyleExists reports whether name is a known chroma style.
+func HighlightStyleExists(name string) bool {
+	_, ok := styles.Registry[name]
+	return ok
+}
+
+// WriteHighlightCSS writes the stylesheet for the chroma style name.
+func WriteHighlightCSS(w io.Writer, name string) error {
+	return chromahtml.New(highlightFormatOptions...).WriteCSS(w, styles.Get(name))
+}
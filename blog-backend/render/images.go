@@ -0,0 +1,254 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif" // Register decoders for image.DecodeConfig.
+	_ "image/jpeg"
+	_ "image/png"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// AssetsDir is where images linked as /assets/<name> are stored.
+var AssetsDir = "./posts/assets"
+
+// SiteHosts are the host names, without port, that posts use in absolute
+// links to their own assets, e.g. https://chatter.pw:8080/assets/Tux.png.
+var SiteHosts = []string{"chatter.pw", "localhost"}
+
+// Figure is an image with a title standing alone in a paragraph. The title
+// becomes the caption.
+type Figure struct {
+	ast.BaseBlock
+}
+
+// KindFigure is the NodeKind of Figure.
+var KindFigure = ast.NewNodeKind("Figure")
+
+// Kind implements ast.Node.
+func (n *Figure) Kind() ast.NodeKind { return KindFigure }
+
+// Dump implements ast.Node.
+func (n *Figure) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// imageExtension adds loading="lazy" and decoding="async" to every image.
+// Images from AssetsDir also get their width and height, so the page does
+// not jump while they load, and a srcset listing resized variants. A
+// variant of photo.png is stored next to it as photo-<width>w.png.
+//
+// A paragraph holding only an image with a title, ![alt](src "Caption"),
+// becomes a <figure> with the title as <figcaption>.
+type imageExtension struct{}
+
+func (imageExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(figureTransformer{}, 100)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(imageRenderer{}, 100)))
+}
+
+type figureTransformer struct{}
+
+// Transform replaces paragraphs that only hold a titled image with figures.
+func (figureTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	var paragraphs []*ast.Paragraph
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		p, ok := n.(*ast.Paragraph)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		if img, ok := p.FirstChild().(*ast.Image); ok && p.ChildCount() == 1 && len(img.Title) > 0 {
+			paragraphs = append(paragraphs, p)
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	for _, p := range paragraphs {
+		f := &Figure{}
+		f.AppendChild(f, p.FirstChild())
+		p.Parent().ReplaceChild(p.Parent(), p, f)
+	}
+}
+
+type imageRenderer struct{}
+
+func (imageRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindImage, renderImage)
+	reg.Register(KindFigure, renderFigure)
+}
+
+func renderImage(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		writeImage(w, source, node.(*ast.Image), true)
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func renderFigure(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		img := node.FirstChild().(*ast.Image)
+		w.WriteString("<figure>")
+		writeImage(w, source, img, false)
+		w.WriteString("<figcaption>")
+		html.DefaultWriter.Write(w, img.Title)
+		w.WriteString("</figcaption></figure>\n")
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// writeImage writes n as an <img> with its loading hints. withTitle adds
+// the title attribute; figures show the title as caption instead.
+func writeImage(w util.BufWriter, source []byte, n *ast.Image, withTitle bool) {
+	src := util.URLEscape(n.Destination, true)
+	w.WriteString(`<img src="`)
+	w.Write(util.EscapeHTML(src))
+	w.WriteString(`" alt="`)
+	w.Write(util.EscapeHTML([]byte(inlineText(n, source, false))))
+	w.WriteByte('"')
+	if withTitle && n.Title != nil {
+		w.WriteString(` title="`)
+		html.DefaultWriter.Write(w, n.Title)
+		w.WriteByte('"')
+	}
+	if info, ok := localImage(string(n.Destination)); ok {
+		fmt.Fprintf(w, ` width="%d" height="%d"`, info.width, info.height)
+		if len(info.variants) > 0 {
+			w.WriteString(` srcset="`)
+			w.Write(util.EscapeHTML([]byte(info.srcset(string(src)))))
+			w.WriteByte('"')
+		}
+	}
+	if n.Attributes() != nil {
+		html.RenderAttributes(w, n, html.ImageAttributeFilter)
+	}
+	w.WriteString(` loading="lazy" decoding="async">`)
+}
+
+// imageInfo describes a local image and its resized variants.
+type imageInfo struct {
+	width, height int
+	// variants maps the width of each resized copy to its file name.
+	variants map[int]string
+}
+
+// srcset lists the variants and the original, addressed like the escaped
+// URL src.
+func (info imageInfo) srcset(src string) string {
+	widths := make([]int, 0, len(info.variants))
+	for width := range info.variants {
+		widths = append(widths, width)
+	}
+	sort.Ints(widths)
+	dir := src[:strings.LastIndex(src, "/")+1]
+	var parts []string
+	for _, width := range widths {
+		parts = append(parts, dir+url.PathEscape(info.variants[width])+" "+strconv.Itoa(width)+"w")
+	}
+	return strings.Join(append(parts, src+" "+strconv.Itoa(info.width)+"w"), ", ")
+}
+
+// cachedImage is an imageInfo remembered until the image or its directory
+// changes.
+type cachedImage struct {
+	info             imageInfo
+	modTime, dirTime time.Time
+}
+
+var imageCache sync.Map // path -> cachedImage
+
+// localImage returns the size and variants of src when it refers to a file
+// in AssetsDir that can be decoded.
+func localImage(src string) (imageInfo, bool) {
+	u, err := url.Parse(src)
+	if err != nil || !strings.HasPrefix(u.Path, "/assets/") {
+		return imageInfo{}, false
+	}
+	if u.Host != "" && !isSiteHost(u.Hostname()) {
+		return imageInfo{}, false
+	}
+	name := strings.TrimPrefix(u.Path, "/assets/")
+	p := filepath.Join(AssetsDir, filepath.FromSlash(path.Clean("/"+name)))
+
+	stat, err := os.Stat(p)
+	if err != nil {
+		return imageInfo{}, false
+	}
+	dirStat, err := os.Stat(filepath.Dir(p))
+	if err != nil {
+		return imageInfo{}, false
+	}
+	if c, ok := imageCache.Load(p); ok {
+		c := c.(cachedImage)
+		if c.modTime.Equal(stat.ModTime()) && c.dirTime.Equal(dirStat.ModTime()) {
+			return c.info, c.info.width > 0
+		}
+	}
+
+	info := readImageInfo(p)
+	imageCache.Store(p, cachedImage{info: info, modTime: stat.ModTime(), dirTime: dirStat.ModTime()})
+	return info, info.width > 0
+}
+
+// variantWidth matches the "-<width>w" suffix of resized variants.
+var variantWidth = regexp.MustCompile(`^-(\d+)w$`)
+
+// readImageInfo decodes the size of the image at p and finds its variants.
+// A zero width means the file is not an image we can decode.
+func readImageInfo(p string) imageInfo {
+	f, err := os.Open(p)
+	if err != nil {
+		return imageInfo{}
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return imageInfo{}
+	}
+
+	info := imageInfo{width: cfg.Width, height: cfg.Height}
+	ext := filepath.Ext(p)
+	base := strings.TrimSuffix(filepath.Base(p), ext)
+	entries, _ := os.ReadDir(filepath.Dir(p))
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || filepath.Ext(name) != ext || !strings.HasPrefix(name, base) {
+			continue
+		}
+		m := variantWidth.FindStringSubmatch(strings.TrimSuffix(strings.TrimPrefix(name, base), ext))
+		if m == nil {
+			continue
+		}
+		if width, err := strconv.Atoi(m[1]); err == nil && width > 0 && width < info.width {
+			if info.variants == nil {
+				info.variants = map[int]string{}
+			}
+			info.variants[width] = name
+		}
+	}
+	return info
+}
+
+func isSiteHost(host string) bool {
+	for _, h := range SiteHosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
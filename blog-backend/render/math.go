@@ -0,0 +1,210 @@
+package render
+
+import (
+	"bytes"
+	"html"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Math is an inline formula, $...$ or $$...$$ inside a paragraph.
+type Math struct {
+	ast.BaseInline
+	Formula []byte
+	Display bool
+}
+
+// KindMath is the NodeKind of Math.
+var KindMath = ast.NewNodeKind("Math")
+
+// Kind implements ast.Node.
+func (n *Math) Kind() ast.NodeKind { return KindMath }
+
+// Dump implements ast.Node.
+func (n *Math) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Formula": string(n.Formula)}, nil)
+}
+
+// MathBlock is a display formula between lines starting and ending with $$.
+type MathBlock struct {
+	ast.BaseBlock
+	// closed is set once the closing $$ has been read.
+	closed bool
+}
+
+// KindMathBlock is the NodeKind of MathBlock.
+var KindMathBlock = ast.NewNodeKind("MathBlock")
+
+// Kind implements ast.Node.
+func (n *MathBlock) Kind() ast.NodeKind { return KindMathBlock }
+
+// IsRaw implements ast.Node.
+func (n *MathBlock) IsRaw() bool { return true }
+
+// Dump implements ast.Node.
+func (n *MathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// mathExtension adds $...$ and $$...$$ formulas. They are converted to
+// MathML on the server; formulas TeXToMathML cannot handle are emitted as
+// \(...\) or \[...\] inside a span or div with class "math", the delimiters
+// KaTeX's auto-render looks for.
+//
+// A single dollar only opens a formula when followed by a non-space, and
+// the next dollar must be preceded by a non-space and not followed by a
+// digit to close it, so "$5 and $10" stays text.
+type mathExtension struct{}
+
+func (mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(mathBlockParser{}, 150)),
+		parser.WithInlineParsers(util.Prioritized(mathInlineParser{}, 150)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(mathRenderer{}, 150)))
+}
+
+var mathDelimiter = []byte("$$")
+
+type mathBlockParser struct{}
+
+func (mathBlockParser) Trigger() []byte { return []byte{'$'} }
+
+func (mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], mathDelimiter) {
+		return nil, parser.NoChildren
+	}
+	node := &MathBlock{}
+	start := segment.Start + pos + len(mathDelimiter)
+	rest := util.TrimRightSpace(line[pos+len(mathDelimiter):])
+	reader.AdvanceToEOL()
+	// $$ formula $$ on a single line.
+	if len(rest) >= len(mathDelimiter) && bytes.HasSuffix(rest, mathDelimiter) {
+		node.Lines().Append(text.NewSegment(start, start+len(rest)-len(mathDelimiter)))
+		node.closed = true
+	} else if !util.IsBlank(rest) {
+		node.Lines().Append(text.NewSegment(start, segment.Stop))
+	}
+	return node, parser.NoChildren
+}
+
+func (mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, segment := reader.PeekLine()
+	if line == nil || node.(*MathBlock).closed {
+		return parser.Close
+	}
+	trimmed := util.TrimRightSpace(line)
+	reader.AdvanceToEOL()
+	if bytes.HasSuffix(trimmed, mathDelimiter) {
+		if len(trimmed) > len(mathDelimiter) {
+			node.Lines().Append(text.NewSegment(segment.Start, segment.Start+len(trimmed)-len(mathDelimiter)))
+		}
+		return parser.Close
+	}
+	node.Lines().Append(segment)
+	return parser.Continue | parser.NoChildren
+}
+
+func (mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (mathBlockParser) CanInterruptParagraph() bool { return true }
+
+func (mathBlockParser) CanAcceptIndentedLine() bool { return false }
+
+type mathInlineParser struct{}
+
+func (mathInlineParser) Trigger() []byte { return []byte{'$'} }
+
+func (mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	if bytes.HasPrefix(line, mathDelimiter) {
+		end := bytes.Index(line[2:], mathDelimiter)
+		if end <= 0 {
+			return nil
+		}
+		block.Advance(end + 4)
+		return &Math{Formula: line[2 : end+2], Display: true}
+	}
+	if len(line) < 3 || util.IsSpace(line[1]) {
+		return nil
+	}
+	for i := 2; i < len(line); i++ {
+		if line[i] == '\n' {
+			return nil
+		}
+		if line[i] != '$' || escaped(line, i) {
+			continue
+		}
+		// The first unescaped dollar has to close the formula.
+		if util.IsSpace(line[i-1]) || i+1 < len(line) && line[i+1] >= '0' && line[i+1] <= '9' {
+			return nil
+		}
+		block.Advance(i + 1)
+		return &Math{Formula: line[1:i]}
+	}
+	return nil
+}
+
+// escaped reports whether the character at i follows an odd number of
+// backslashes. In "\\$" the backslashes are a TeX line break and the
+// dollar still closes the formula.
+func escaped(line []byte, i int) bool {
+	n := 0
+	for j := i - 1; j >= 0 && line[j] == '\\'; j-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+type mathRenderer struct{}
+
+func (mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindMath, renderMath)
+	reg.Register(KindMathBlock, renderMathBlock)
+}
+
+func renderMath(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		m := n.(*Math)
+		writeMath(w, string(m.Formula), m.Display, false)
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func renderMathBlock(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		var formula bytes.Buffer
+		lines := n.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			seg := lines.At(i)
+			formula.Write(seg.Value(source))
+		}
+		writeMath(w, string(bytes.TrimSpace(formula.Bytes())), true, true)
+		w.WriteByte('\n')
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// writeMath writes formula as MathML, or as its escaped TeX source when it
+// cannot be converted.
+func writeMath(w util.BufWriter, formula string, display, block bool) {
+	if mathml, err := TeXToMathML(formula, display); err == nil {
+		w.WriteString(mathml)
+		return
+	}
+	switch {
+	case block:
+		w.WriteString(`<div class="math math-display">\[` + html.EscapeString(formula) + `\]</div>`)
+	case display:
+		w.WriteString(`<span class="math math-display">\[` + html.EscapeString(formula) + `\]</span>`)
+	default:
+		w.WriteString(`<span class="math math-inline">\(` + html.EscapeString(formula) + `\)</span>`)
+	}
+}
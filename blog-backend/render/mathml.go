@@ -0,0 +1,612 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// TeXToMathML converts a TeX formula to presentation MathML. It understands
+// the subset of LaTeX math that posts use: scripts, fractions, roots,
+// Greek letters and common symbols, font commands, accents, \left/\right
+// delimiters and the matrix, cases and aligned environments. Anything else
+// is reported as an error so the caller can fall back to the TeX source.
+//
+// The TeX source is kept in an annotation, so it can still be copied or
+// re-rendered by a client side library.
+func TeXToMathML(tex string, display bool) (string, error) {
+	p := &texParser{toks: tokenizeTeX(tex)}
+	row, err := p.parseRow()
+	if err != nil {
+		return "", err
+	}
+	if t := p.peek(); t != "" {
+		return "", fmt.Errorf("unexpected %q", t)
+	}
+
+	var b strings.Builder
+	b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML"`)
+	if display {
+		b.WriteString(` display="block"`)
+	}
+	b.WriteString(`><semantics><mrow>`)
+	b.WriteString(strings.Join(row, ""))
+	b.WriteString(`</mrow><annotation encoding="application/x-tex">`)
+	b.WriteString(html.EscapeString(tex))
+	b.WriteString(`</annotation></semantics></math>`)
+	return b.String(), nil
+}
+
+// tokenizeTeX splits tex into commands ("\frac", "\,", "\\"), digit runs and
+// single characters. Runs of whitespace become a single " " token, which only
+// matters inside \text.
+func tokenizeTeX(tex string) []string {
+	var toks []string
+	rs := []rune(tex)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case r == '\\' && i+1 < len(rs) && isASCIILetter(rs[i+1]):
+			j := i + 1
+			for j < len(rs) && isASCIILetter(rs[j]) {
+				j++
+			}
+			toks = append(toks, string(rs[i:j]))
+			i = j
+		case r == '\\' && i+1 < len(rs):
+			toks = append(toks, string(rs[i:i+2]))
+			i += 2
+		case r >= '0' && r <= '9':
+			j := i
+			for j < len(rs) && (rs[j] >= '0' && rs[j] <= '9' || rs[j] == '.' && j+1 < len(rs) && rs[j+1] >= '0' && rs[j+1] <= '9') {
+				j++
+			}
+			toks = append(toks, string(rs[i:j]))
+			i = j
+		case unicode.IsSpace(r):
+			for i < len(rs) && unicode.IsSpace(rs[i]) {
+				i++
+			}
+			toks = append(toks, " ")
+		default:
+			toks = append(toks, string(r))
+			i++
+		}
+	}
+	return toks
+}
+
+func isASCIILetter(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+type texParser struct {
+	toks []string
+	pos  int
+	// variant is the mathvariant applied to identifiers inside \mathbf and
+	// friends.
+	variant string
+}
+
+// peek returns the next token that is not a space, or "" at the end.
+func (p *texParser) peek() string {
+	for p.pos < len(p.toks) && p.toks[p.pos] == " " {
+		p.pos++
+	}
+	if p.pos == len(p.toks) {
+		return ""
+	}
+	return p.toks[p.pos]
+}
+
+func (p *texParser) take() string {
+	t := p.peek()
+	if t != "" {
+		p.pos++
+	}
+	return t
+}
+
+func (p *texParser) expect(tok string) error {
+	if t := p.take(); t != tok {
+		if t == "" {
+			return fmt.Errorf("expected %q, found end of formula", tok)
+		}
+		return fmt.Errorf("expected %q, found %q", tok, t)
+	}
+	return nil
+}
+
+// parseRow parses atoms up to the end of the formula or a token that closes
+// the current construct, which is left for the caller. Extra closing tokens
+// may be passed in stops.
+func (p *texParser) parseRow(stops ...string) ([]string, error) {
+	var row []string
+	for {
+		switch t := p.peek(); t {
+		case "", "}", "&", `\\`, `\end`, `\right`:
+			return row, nil
+		default:
+			for _, stop := range stops {
+				if t == stop {
+					return row, nil
+				}
+			}
+		}
+		atom, err := p.parseScripted()
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, atom)
+	}
+}
+
+// parseScripted parses an atom with its optional sub- and superscripts.
+func (p *texParser) parseScripted() (string, error) {
+	base, limits, err := p.parseAtom()
+	if err != nil {
+		return "", err
+	}
+	var sub, sup string
+	for {
+		t := p.peek()
+		if t == `\limits` || t == `\nolimits` {
+			p.take()
+			limits = t == `\limits`
+			continue
+		}
+		if t != "_" && t != "^" {
+			break
+		}
+		p.take()
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", err
+		}
+		if t == "_" {
+			if sub != "" {
+				return "", fmt.Errorf("double subscript")
+			}
+			sub = arg
+		} else {
+			if sup != "" {
+				return "", fmt.Errorf("double superscript")
+			}
+			sup = arg
+		}
+	}
+	switch {
+	case sub != "" && sup != "" && limits:
+		return "<munderover>" + base + sub + sup + "</munderover>", nil
+	case sub != "" && sup != "":
+		return "<msubsup>" + base + sub + sup + "</msubsup>", nil
+	case sub != "" && limits:
+		return "<munder>" + base + sub + "</munder>", nil
+	case sub != "":
+		return "<msub>" + base + sub + "</msub>", nil
+	case sup != "" && limits:
+		return "<mover>" + base + sup + "</mover>", nil
+	case sup != "":
+		return "<msup>" + base + sup + "</msup>", nil
+	}
+	return base, nil
+}
+
+// parseArg parses a command argument or script: a braced group or a single
+// atom.
+func (p *texParser) parseArg() (string, error) {
+	switch p.peek() {
+	case "":
+		return "", fmt.Errorf("missing argument at end of formula")
+	case "{":
+		return p.parseGroup()
+	}
+	// An unbraced argument is a single character, so \frac12 is ½ and x^23
+	// is x² followed by 3.
+	if t := p.peek(); len(t) > 1 && t[0] >= '0' && t[0] <= '9' {
+		rest := tokenizeTeX(t[1:])
+		p.toks = append(p.toks[:p.pos+1], append(rest, p.toks[p.pos+1:]...)...)
+		p.toks[p.pos] = t[:1]
+	}
+	atom, _, err := p.parseAtom()
+	return atom, err
+}
+
+func (p *texParser) parseGroup() (string, error) {
+	if err := p.expect("{"); err != nil {
+		return "", err
+	}
+	row, err := p.parseRow()
+	if err != nil {
+		return "", err
+	}
+	if err := p.expect("}"); err != nil {
+		return "", err
+	}
+	return mrow(row), nil
+}
+
+// rawGroup returns the source of a braced group without interpreting it, for
+// \text and environment names.
+func (p *texParser) rawGroup() (string, error) {
+	if err := p.expect("{"); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for depth := 0; ; p.pos++ {
+		if p.pos == len(p.toks) {
+			return "", fmt.Errorf("unclosed group")
+		}
+		switch t := p.toks[p.pos]; t {
+		case "{":
+			depth++
+		case "}":
+			if depth == 0 {
+				p.pos++
+				return b.String(), nil
+			}
+			depth--
+		default:
+			if len(t) == 2 && t[0] == '\\' && !isASCIILetter(rune(t[1])) {
+				t = t[1:]
+			}
+			b.WriteString(t)
+		}
+	}
+}
+
+func mrow(row []string) string {
+	if len(row) == 1 {
+		return row[0]
+	}
+	return "<mrow>" + strings.Join(row, "") + "</mrow>"
+}
+
+func (p *texParser) mi(s string) string {
+	if p.variant != "" {
+		return `<mi mathvariant="` + p.variant + `">` + html.EscapeString(s) + "</mi>"
+	}
+	return "<mi>" + html.EscapeString(s) + "</mi>"
+}
+
+func mo(s string) string {
+	return "<mo>" + html.EscapeString(s) + "</mo>"
+}
+
+// parseAtom parses a single atom. limits reports whether scripts on it go
+// above and below, as on \sum or \lim.
+func (p *texParser) parseAtom() (atom string, limits bool, err error) {
+	t := p.take()
+	switch {
+	case t == "{":
+		p.pos--
+		atom, err = p.parseGroup()
+		return atom, false, err
+	case t[0] >= '0' && t[0] <= '9':
+		if p.variant == "bold" {
+			return `<mn mathvariant="bold">` + t + "</mn>", false, nil
+		}
+		return "<mn>" + t + "</mn>", false, nil
+	case t[0] == '\\':
+		return p.parseCommand(t)
+	}
+	switch t {
+	case "-":
+		return mo("−"), false, nil
+	case "'":
+		return mo("′"), false, nil
+	case "~":
+		return `<mspace width="0.25em"></mspace>`, false, nil
+	case "}", "&", "#", "%", "$":
+		return "", false, fmt.Errorf("unexpected %q", t)
+	case "^", "_":
+		return "", false, fmt.Errorf("script %q without a base", t)
+	}
+	if r := []rune(t)[0]; unicode.IsLetter(r) {
+		return p.mi(t), false, nil
+	}
+	return mo(t), false, nil
+}
+
+func (p *texParser) parseCommand(cmd string) (string, bool, error) {
+	name := cmd[1:]
+	if s, ok := texIdentifiers[name]; ok {
+		return p.mi(s), false, nil
+	}
+	if s, ok := texUprightIdentifiers[name]; ok {
+		return `<mi mathvariant="normal">` + s + "</mi>", false, nil
+	}
+	if s, ok := texOperators[name]; ok {
+		return mo(s), false, nil
+	}
+	if s, ok := texLargeOperators[name]; ok {
+		return `<mo largeop="true" movablelimits="true">` + s + "</mo>", true, nil
+	}
+	if s, ok := texIntegrals[name]; ok {
+		return `<mo largeop="true">` + s + "</mo>", false, nil
+	}
+	if texFunctions[name] {
+		return "<mi>" + name + "</mi>", false, nil
+	}
+	if texLimitFunctions[name] {
+		return `<mo movablelimits="true" form="prefix">` + name + "</mo>", true, nil
+	}
+	if w, ok := texSpaces[name]; ok {
+		return `<mspace width="` + w + `"></mspace>`, false, nil
+	}
+	if a, ok := texAccents[name]; ok {
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		return `<mover accent="true">` + arg + a + "</mover>", false, nil
+	}
+	if v, ok := texVariants[name]; ok {
+		saved := p.variant
+		p.variant = v
+		arg, err := p.parseArg()
+		p.variant = saved
+		return arg, false, err
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "binom":
+		num, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		den, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		if name == "binom" {
+			return `<mrow><mo>(</mo><mfrac linethickness="0">` + num + den + `</mfrac><mo>)</mo></mrow>`, false, nil
+		}
+		return "<mfrac>" + num + den + "</mfrac>", false, nil
+	case "sqrt":
+		if p.peek() == "[" {
+			p.take()
+			row, err := p.parseRow("]")
+			if err != nil {
+				return "", false, err
+			}
+			if err := p.expect("]"); err != nil {
+				return "", false, err
+			}
+			arg, err := p.parseArg()
+			if err != nil {
+				return "", false, err
+			}
+			return "<mroot>" + arg + mrow(row) + "</mroot>", false, nil
+		}
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		return "<msqrt>" + arg + "</msqrt>", false, nil
+	case "text", "textrm", "textnormal", "mbox", "textbf", "textit":
+		s, err := p.rawGroup()
+		if err != nil {
+			return "", false, err
+		}
+		attr := ""
+		switch name {
+		case "textbf":
+			attr = ` mathvariant="bold"`
+		case "textit":
+			attr = ` mathvariant="italic"`
+		}
+		return "<mtext" + attr + ">" + html.EscapeString(s) + "</mtext>", false, nil
+	case "operatorname":
+		s, err := p.rawGroup()
+		if err != nil {
+			return "", false, err
+		}
+		return "<mi>" + html.EscapeString(strings.TrimSpace(s)) + "</mi>", false, nil
+	case "left":
+		open, err := p.delimiter()
+		if err != nil {
+			return "", false, err
+		}
+		row, err := p.parseRow()
+		if err != nil {
+			return "", false, err
+		}
+		if err := p.expect(`\right`); err != nil {
+			return "", false, err
+		}
+		closing, err := p.delimiter()
+		if err != nil {
+			return "", false, err
+		}
+		return "<mrow>" + fence(open) + strings.Join(row, "") + fence(closing) + "</mrow>", false, nil
+	case "middle", "big", "Big", "bigg", "Bigg", "bigl", "bigr", "Bigl", "Bigr", "biggl", "biggr", "Biggl", "Biggr":
+		d, err := p.delimiter()
+		if err != nil {
+			return "", false, err
+		}
+		return mo(d), false, nil
+	case "begin":
+		return p.parseEnvironment()
+	case "displaystyle", "textstyle":
+		return "", false, nil
+	}
+	return "", false, fmt.Errorf("unsupported command %s", cmd)
+}
+
+// delimiter reads the delimiter after \left, \right or \big. "." stands for
+// no delimiter and yields "".
+func (p *texParser) delimiter() (string, error) {
+	t := p.take()
+	switch {
+	case t == "":
+		return "", fmt.Errorf("missing delimiter at end of formula")
+	case t == ".":
+		return "", nil
+	case t[0] == '\\':
+		if s, ok := texOperators[t[1:]]; ok {
+			return s, nil
+		}
+		return "", fmt.Errorf("unsupported delimiter %s", t)
+	}
+	return t, nil
+}
+
+func fence(d string) string {
+	if d == "" {
+		return ""
+	}
+	return `<mo fence="true" stretchy="true">` + html.EscapeString(d) + "</mo>"
+}
+
+// texEnvironments maps the supported environments to the delimiters around
+// them and their column alignment.
+var texEnvironments = map[string]struct{ open, close, align string }{
+	"matrix":   {},
+	"pmatrix":  {"(", ")", ""},
+	"bmatrix":  {"[", "]", ""},
+	"Bmatrix":  {"{", "}", ""},
+	"vmatrix":  {"|", "|", ""},
+	"Vmatrix":  {"‖", "‖", ""},
+	"cases":    {"{", "", "left left"},
+	"array":    {},
+	"aligned":  {"", "", "right left"},
+	"align":    {"", "", "right left"},
+	"align*":   {"", "", "right left"},
+	"gathered": {},
+}
+
+func (p *texParser) parseEnvironment() (string, bool, error) {
+	name, err := p.rawGroup()
+	if err != nil {
+		return "", false, err
+	}
+	env, ok := texEnvironments[name]
+	if !ok {
+		return "", false, fmt.Errorf("unsupported environment %s", name)
+	}
+	if name == "array" && p.peek() == "{" {
+		// Column specification; alignment is left to the renderer.
+		if _, err := p.rawGroup(); err != nil {
+			return "", false, err
+		}
+	}
+
+	var table strings.Builder
+	table.WriteString("<mtable")
+	if env.align != "" {
+		table.WriteString(` columnalign="` + env.align + `"`)
+	}
+	table.WriteString("><mtr>")
+	for {
+		row, err := p.parseRow()
+		if err != nil {
+			return "", false, err
+		}
+		table.WriteString("<mtd>" + strings.Join(row, "") + "</mtd>")
+		switch p.take() {
+		case "&":
+			continue
+		case `\\`:
+			table.WriteString("</mtr><mtr>")
+			continue
+		case `\end`:
+			end, err := p.rawGroup()
+			if err != nil {
+				return "", false, err
+			}
+			if end != name {
+				return "", false, fmt.Errorf(`\begin{%s} closed by \end{%s}`, name, end)
+			}
+			table.WriteString("</mtr></mtable>")
+			return "<mrow>" + fence(env.open) + table.String() + fence(env.close) + "</mrow>", false, nil
+		default:
+			return "", false, fmt.Errorf(`unclosed \begin{%s}`, name)
+		}
+	}
+}
+
+var texIdentifiers = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ",
+	"varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ",
+	"iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ",
+	"pi": "π", "varpi": "ϖ", "rho": "ρ", "varrho": "ϱ", "sigma": "σ",
+	"varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ", "varphi": "φ",
+	"chi": "χ", "psi": "ψ", "omega": "ω",
+	"ell": "ℓ", "hbar": "ℏ", "imath": "ı", "jmath": "ȷ", "wp": "℘",
+}
+
+var texUprightIdentifiers = map[string]string{
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ",
+	"Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ",
+	"Omega": "Ω",
+	"infty": "∞", "partial": "∂", "nabla": "∇", "emptyset": "∅",
+	"varnothing": "∅", "aleph": "ℵ", "Re": "ℜ", "Im": "ℑ",
+}
+
+var texOperators = map[string]string{
+	"pm": "±", "mp": "∓", "times": "×", "div": "÷", "cdot": "⋅", "ast": "∗",
+	"star": "⋆", "circ": "∘", "bullet": "∙", "oplus": "⊕", "ominus": "⊖",
+	"otimes": "⊗", "odot": "⊙", "setminus": "∖", "cup": "∪", "cap": "∩",
+	"wedge": "∧", "land": "∧", "vee": "∨", "lor": "∨", "neg": "¬", "lnot": "¬",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠",
+	"approx": "≈", "equiv": "≡", "sim": "∼", "simeq": "≃", "cong": "≅",
+	"propto": "∝", "ll": "≪", "gg": "≫", "prec": "≺", "succ": "≻",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "supset": "⊃",
+	"subseteq": "⊆", "supseteq": "⊇", "forall": "∀", "exists": "∃",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←",
+	"Rightarrow": "⇒", "Leftarrow": "⇐", "leftrightarrow": "↔",
+	"Leftrightarrow": "⇔", "iff": "⟺", "implies": "⟹", "mapsto": "↦",
+	"longrightarrow": "⟶", "longleftarrow": "⟵", "uparrow": "↑",
+	"downarrow": "↓", "ldots": "…", "dots": "…", "cdots": "⋯", "vdots": "⋮",
+	"ddots": "⋱", "langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋",
+	"lceil": "⌈", "rceil": "⌉", "mid": "∣", "parallel": "∥", "perp": "⊥",
+	"vert": "|", "Vert": "‖", "colon": ":", "vdash": "⊢", "models": "⊨",
+	"top": "⊤", "bot": "⊥", "angle": "∠", "triangle": "△", "prime": "′",
+	"{": "{", "}": "}", "|": "‖", "%": "%", "#": "#", "&": "&", "_": "_",
+	"$": "$", "lbrace": "{", "rbrace": "}", "lvert": "|", "rvert": "|",
+	"lVert": "‖", "rVert": "‖",
+}
+
+var texLargeOperators = map[string]string{
+	"sum": "∑", "prod": "∏", "coprod": "∐", "bigcup": "⋃", "bigcap": "⋂",
+	"bigoplus": "⨁", "bigotimes": "⨂", "bigvee": "⋁", "bigwedge": "⋀",
+}
+
+var texIntegrals = map[string]string{
+	"int": "∫", "iint": "∬", "iiint": "∭", "oint": "∮",
+}
+
+var texFunctions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "cot": true, "sec": true,
+	"csc": true, "arcsin": true, "arccos": true, "arctan": true,
+	"sinh": true, "cosh": true, "tanh": true, "log": true, "ln": true,
+	"lg": true, "exp": true, "dim": true, "ker": true, "deg": true,
+	"arg": true, "hom": true,
+}
+
+var texLimitFunctions = map[string]bool{
+	"lim": true, "limsup": true, "liminf": true, "max": true, "min": true,
+	"sup": true, "inf": true, "det": true, "gcd": true, "Pr": true,
+}
+
+var texSpaces = map[string]string{
+	",": "0.1667em", ":": "0.2222em", ">": "0.2222em", ";": "0.2778em",
+	"!": "-0.1667em", " ": "0.25em", "quad": "1em", "qquad": "2em",
+}
+
+var texAccents = map[string]string{
+	"hat": "<mo>^</mo>", "widehat": `<mo stretchy="true">^</mo>`,
+	"bar": "<mo>¯</mo>", "overline": `<mo stretchy="true">‾</mo>`,
+	"vec": "<mo>→</mo>", "overrightarrow": `<mo stretchy="true">→</mo>`,
+	"tilde": "<mo>~</mo>", "widetilde": `<mo stretchy="true">~</mo>`,
+	"dot": "<mo>˙</mo>", "ddot": "<mo>¨</mo>",
+}
+
+var texVariants = map[string]string{
+	"mathrm": "normal", "mathbf": "bold", "boldsymbol": "bold-italic",
+	"mathit": "italic", "mathbb": "double-struck", "mathcal": "script",
+	"mathscr": "script", "mathfrak": "fraktur", "mathsf": "sans-serif",
+	"mathtt": "monospace",
+}
@@ -0,0 +1,153 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	mathOpen  = `<math xmlns="http://www.w3.org/1998/Math/MathML"><semantics><mrow>`
+	mathClose = `</mrow><annotation encoding="application/x-tex">`
+)
+
+// mathBody returns the MathML of tex without the wrapper and annotation.
+func mathBody(t *testing.T, tex string) (string, error) {
+	t.Helper()
+	out, err := TeXToMathML(tex, false)
+	if err != nil {
+		return "", err
+	}
+	end := strings.Index(out, mathClose)
+	if !strings.HasPrefix(out, mathOpen) || end < 0 {
+		t.Fatalf("TeXToMathML(%q) = %q, not wrapped in math and semantics", tex, out)
+	}
+	return out[len(mathOpen):end], nil
+}
+
+func TestTeXToMathML(t *testing.T) {
+	const (
+		lparen = `<mo fence="true" stretchy="true">(</mo>`
+		rparen = `<mo fence="true" stretchy="true">)</mo>`
+	)
+	tests := []struct {
+		name, tex, want string
+	}{
+		// Fractions
+		{"frac", `\frac{a}{b}`, `<mfrac><mi>a</mi><mi>b</mi></mfrac>`},
+		{"frac of digits", `\frac12`, `<mfrac><mn>1</mn><mn>2</mn></mfrac>`},
+		{"dfrac with a row", `\dfrac{1}{x+1}`, `<mfrac><mn>1</mn><mrow><mi>x</mi><mo>+</mo><mn>1</mn></mrow></mfrac>`},
+		{"binom", `\binom{n}{k}`, `<mrow><mo>(</mo><mfrac linethickness="0"><mi>n</mi><mi>k</mi></mfrac><mo>)</mo></mrow>`},
+		{"sqrt", `\sqrt{x}`, `<msqrt><mi>x</mi></msqrt>`},
+		{"root", `\sqrt[3]{x}`, `<mroot><mi>x</mi><mn>3</mn></mroot>`},
+
+		// Scripts
+		{"superscript", `x^2`, `<msup><mi>x</mi><mn>2</mn></msup>`},
+		{"superscript takes one digit", `x^23`, `<msup><mi>x</mi><mn>2</mn></msup><mn>3</mn>`},
+		{"sub and superscript", `x_i^2`, `<msubsup><mi>x</mi><mi>i</mi><mn>2</mn></msubsup>`},
+		{"braced subscript", `a_{ij}`, `<msub><mi>a</mi><mrow><mi>i</mi><mi>j</mi></mrow></msub>`},
+		{"sum limits", `\sum_{i=1}^n i`, `<munderover><mo largeop="true" movablelimits="true">∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></munderover><mi>i</mi>`},
+		{"integral", `\int_0^1 f`, `<msubsup><mo largeop="true">∫</mo><mn>0</mn><mn>1</mn></msubsup><mi>f</mi>`},
+		{"lim", `\lim_{x\to 0}`, `<munder><mo movablelimits="true" form="prefix">lim</mo><mrow><mi>x</mi><mo>→</mo><mn>0</mn></mrow></munder>`},
+		{"prime", `x'`, `<mi>x</mi><mo>′</mo>`},
+
+		// Delimiters
+		{"left right", `\left( \frac{a}{b} \right)`, `<mrow>` + lparen + `<mfrac><mi>a</mi><mi>b</mi></mfrac>` + rparen + `</mrow>`},
+		{"empty left", `\left. x \right|`, `<mrow><mi>x</mi><mo fence="true" stretchy="true">|</mo></mrow>`},
+		{"command delimiters", `\left\langle x \right\rangle`, `<mrow><mo fence="true" stretchy="true">⟨</mo><mi>x</mi><mo fence="true" stretchy="true">⟩</mo></mrow>`},
+
+		// Environments
+		{"pmatrix", `\begin{pmatrix} a & b \\ c & d \end{pmatrix}`,
+			`<mrow>` + lparen + `<mtable><mtr><mtd><mi>a</mi></mtd><mtd><mi>b</mi></mtd></mtr><mtr><mtd><mi>c</mi></mtd><mtd><mi>d</mi></mtd></mtr></mtable>` + rparen + `</mrow>`},
+		{"cases", `\begin{cases} 1 & x > 0 \\ 0 & \text{otherwise} \end{cases}`,
+			`<mrow><mo fence="true" stretchy="true">{</mo><mtable columnalign="left left"><mtr><mtd><mn>1</mn></mtd><mtd><mi>x</mi><mo>&gt;</mo><mn>0</mn></mtd></mtr><mtr><mtd><mn>0</mn></mtd><mtd><mtext>otherwise</mtext></mtd></mtr></mtable></mrow>`},
+		{"aligned", `\begin{aligned} a &= b \\ &= c \end{aligned}`,
+			`<mrow><mtable columnalign="right left"><mtr><mtd><mi>a</mi></mtd><mtd><mo>=</mo><mi>b</mi></mtd></mtr><mtr><mtd></mtd><mtd><mo>=</mo><mi>c</mi></mtd></mtr></mtable></mrow>`},
+
+		// Symbols, fonts and text
+		{"greek", `\alpha + \Gamma`, `<mi>α</mi><mo>+</mo><mi mathvariant="normal">Γ</mi>`},
+		{"font", `\mathbb{R}`, `<mi mathvariant="double-struck">R</mi>`},
+		{"accent", `\hat{x}`, `<mover accent="true"><mi>x</mi><mo>^</mo></mover>`},
+		{"escaped operator", `a < b`, `<mi>a</mi><mo>&lt;</mo><mi>b</mi>`},
+		{"text", `\text{a {b} <c>}`, `<mtext>a b &lt;c&gt;</mtext>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mathBody(t, tt.tex)
+			if err != nil {
+				t.Fatalf("TeXToMathML(%q): %v", tt.tex, err)
+			}
+			if got != tt.want {
+				t.Errorf("TeXToMathML(%q)\n got %s\nwant %s", tt.tex, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTeXToMathMLErrors(t *testing.T) {
+	tests := []struct {
+		tex, want string
+	}{
+		{`\foo`, `unsupported command \foo`},
+		{`\begin{foo} x \end{foo}`, "unsupported environment foo"},
+		{`\left( a`, `expected "\\right", found end of formula`},
+		{`\left\foo a \right)`, `unsupported delimiter \foo`},
+		{`\begin{matrix} a \end{bmatrix}`, `\begin{matrix} closed by \end{bmatrix}`},
+		{`\begin{matrix} a`, `unclosed \begin{matrix}`},
+		{`\frac{a}`, "missing argument at end of formula"},
+		{`x^`, "missing argument at end of formula"},
+		{`x^2^3`, "double superscript"},
+		{`x_1_2`, "double subscript"},
+		{`^2`, `script "^" without a base`},
+		{`{a`, `expected "}", found end of formula`},
+		{`a}`, `unexpected "}"`},
+		{`a & b`, `unexpected "&"`},
+		{`\text{a`, "unclosed group"},
+	}
+	for _, tt := range tests {
+		_, err := TeXToMathML(tt.tex, false)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("TeXToMathML(%q) error = %v, want %q", tt.tex, err, tt.want)
+		}
+	}
+}
+
+func TestTeXToMathMLDisplay(t *testing.T) {
+	out, err := TeXToMathML(`a<b`, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, `<math xmlns="http://www.w3.org/1998/Math/MathML" display="block">`) {
+		t.Errorf("display formula = %s", out)
+	}
+	if !strings.HasSuffix(out, `<annotation encoding="application/x-tex">a&lt;b</annotation></semantics></math>`) {
+		t.Errorf("annotation not escaped: %s", out)
+	}
+}
+
+// TestMathInMarkdown checks how formulas are found in text, and that ones
+// TeXToMathML rejects are kept as escaped TeX for a client side renderer.
+func TestMathInMarkdown(t *testing.T) {
+	tests := []struct {
+		name, source, want string
+	}{
+		{"inline", `see $x^2$ here`, `<p>see ` + mathOpen + `<msup><mi>x</mi><mn>2</mn></msup>` + mathClose + `x^2</annotation></semantics></math> here</p>`},
+		// A line break is not an escaped dollar; the formula still closes.
+		{"line break before the closing dollar", `$a\\$ b`, `<p><span class="math math-inline">\(a\\\)</span> b</p>`},
+		{"escaped dollar", `$a\$ b$`, `<p>` + mathOpen + `<mi>a</mi><mo>$</mo><mi>b</mi>` + mathClose + `a\$ b</annotation></semantics></math></p>`},
+		{"prices", `$5 and $10`, `<p>$5 and $10</p>`},
+		{"space before closing", `$a $`, `<p>$a $</p>`},
+		{"unsupported command", `$\foo{x}$`, `<p><span class="math math-inline">\(\foo{x}\)</span></p>`},
+		{"unsupported display", "$$\n\\foo < 1\n$$", `<div class="math math-display">\[\foo &lt; 1\]</div>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Markdown.Render([]byte(tt.source), Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(string(result.HTML)); got != tt.want {
+				t.Errorf("Render(%q)\n got %s\nwant %s", tt.source, got, tt.want)
+			}
+		})
+	}
+}
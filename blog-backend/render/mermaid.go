@@ -0,0 +1,89 @@
+package render
+
+import (
+	"bytes"
+	"html"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// MermaidLanguage is the fence language of Mermaid diagrams.
+const MermaidLanguage = "mermaid"
+
+// Mermaid is a ```mermaid fenced block.
+type Mermaid struct {
+	ast.BaseBlock
+}
+
+// KindMermaid is the NodeKind of Mermaid.
+var KindMermaid = ast.NewNodeKind("Mermaid")
+
+// Kind implements ast.Node.
+func (n *Mermaid) Kind() ast.NodeKind { return KindMermaid }
+
+// IsRaw implements ast.Node.
+func (n *Mermaid) IsRaw() bool { return true }
+
+// Dump implements ast.Node.
+func (n *Mermaid) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// mermaidExtension renders ```mermaid fences as <pre class="mermaid"> with
+// the escaped diagram source instead of highlighting them. mermaid.js draws
+// every element with that class, and feed readers without scripts still
+// show the source as preformatted text.
+type mermaidExtension struct{}
+
+func (mermaidExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(mermaidTransformer{}, 100)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(mermaidRenderer{}, 100)))
+}
+
+type mermaidTransformer struct{}
+
+// Transform replaces mermaid fenced code blocks with Mermaid nodes, before
+// the highlighter gets to see them.
+func (mermaidTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	var blocks []*ast.FencedCodeBlock
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if b, ok := n.(*ast.FencedCodeBlock); ok && entering {
+			if string(b.Language(source)) == MermaidLanguage {
+				blocks = append(blocks, b)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	for _, b := range blocks {
+		m := &Mermaid{}
+		m.SetLines(b.Lines())
+		b.Parent().ReplaceChild(b.Parent(), b, m)
+	}
+}
+
+type mermaidRenderer struct{}
+
+func (mermaidRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindMermaid, renderMermaid)
+}
+
+func renderMermaid(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		var diagram bytes.Buffer
+		lines := n.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			seg := lines.At(i)
+			diagram.Write(seg.Value(source))
+		}
+		w.WriteString(`<pre class="mermaid">`)
+		w.WriteString(html.EscapeString(diagram.String()))
+		w.WriteString("</pre>\n")
+	}
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,95 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+
+	"github.com/gg582/chi-blog/blog-backend/models"
+)
+
+// Options adjust how a single document is rendered.
+type Options struct {
+	// Trusted skips HTML sanitization. Only admins may set it, through the
+	// "trusted: true" front matter key.
+	Trusted bool
+}
+
+// Result is a rendered document.
+type Result struct {
+	HTML []byte
+	TOC  []models.TOCEntry
+	// Text is the document without markup, one line per block.
+	Text string
+	// FirstParagraph is the text of the first paragraph, for excerpts.
+	FirstParagraph string
+}
+
+// Renderer turns markdown source into HTML.
+type Renderer interface {
+	Render(source []byte, opts Options) (*Result, error)
+}
+
+// Markdown is the renderer used for posts and pages.
+var Markdown Renderer = NewGoldmark()
+
+// Goldmark is a CommonMark compliant Renderer with the GitHub Flavored
+// Markdown extensions (tables, task lists, strikethrough, autolinks) plus
+// footnotes, heading anchors, a table of contents, server-side code
+// highlighting, TeX math, Mermaid diagrams and sized, lazily loaded images.
+type Goldmark struct {
+	md goldmark.Markdown
+}
+
+// goldmarkParser parses snippets that only need their text extracted.
+var goldmarkParser = goldmark.New(goldmark.WithExtensions(extension.GFM)).Parser()
+
+// NewGoldmark returns a Goldmark renderer. Raw HTML in the source is passed
+// through, as blackfriday did, and then filtered by Sanitize unless the
+// document is trusted.
+func NewGoldmark() *Goldmark {
+	return &Goldmark{md: goldmark.New(
+		goldmark.WithExtensions(
+			extension.GFM,
+			extension.Footnote,
+			mathExtension{},
+			mermaidExtension{},
+			imageExtension{},
+			newHighlighting(),
+		),
+		goldmark.WithRendererOptions(
+			html.WithUnsafe(),
+		),
+	)}
+}
+
+// Render implements Renderer. A paragraph consisting of just "[TOC]" is
+// replaced by the table of contents.
+func (g *Goldmark) Render(source []byte, opts Options) (*Result, error) {
+	doc := g.md.Parser().Parse(text.NewReader(source))
+	assignHeadingIDs(doc, source)
+
+	var buf bytes.Buffer
+	if err := g.md.Renderer().Render(&buf, source, doc); err != nil {
+		return nil, err
+	}
+	out := buf.Bytes()
+	if !opts.Trusted {
+		out = Sanitize(out)
+	}
+
+	toc := buildTOC(doc, source)
+	if bytes.Contains(out, []byte(tocMarker)) {
+		out = []byte(strings.Replace(string(out), tocMarker, renderTOC(toc), 1))
+	}
+	return &Result{
+		HTML:           out,
+		TOC:            toc,
+		Text:           documentText(doc, source),
+		FirstParagraph: firstParagraph(doc, source),
+	}, nil
+}
@@ -0,0 +1,84 @@
+package render
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gg582/chi-blog/blog-backend/utils"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// postsDir holds the published posts, relative to this package.
+const postsDir = "../posts"
+
+// TestPostsGolden renders every post and compares the HTML with
+// testdata/golden/<slug>.html. Run "go test ./render -update" after an
+// intended change to the output and review the diff of the golden files.
+func TestPostsGolden(t *testing.T) {
+	AssetsDir = filepath.Join(postsDir, "assets")
+
+	paths, err := filepath.Glob(filepath.Join(postsDir, "*.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("no posts found in %s", postsDir)
+	}
+	for _, path := range paths {
+		slug := strings.TrimSuffix(filepath.Base(path), ".md")
+		t.Run(slug, func(t *testing.T) {
+			source, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			meta, body := utils.ParseFrontMatter(source)
+			result, err := Markdown.Render(body, Options{Trusted: meta.Bool("trusted")})
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+
+			golden := filepath.Join("testdata", "golden", slug+".html")
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(golden, result.HTML, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(result.HTML, want) {
+				t.Errorf("rendered HTML differs from %s (run with -update to accept):\n%s", golden, firstDiff(want, result.HTML))
+			}
+		})
+	}
+}
+
+// firstDiff shows the first line where got differs from want.
+func firstDiff(want, got []byte) string {
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(string(got), "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d:\n  want: %s\n  got:  %s", i+1, w, g)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+package render
+
+import (
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// policy is the allowlist applied to rendered posts. It starts from
+// bluemonday's user generated content policy, which already drops scripts,
+// event handlers, javascript: URLs and the like, and adds what our markdown
+// output and existing posts rely on.
+var policy = newPolicy()
+
+var (
+	classNames = regexp.MustCompile(`^[\w\- ]+$`)
+	mediaType  = regexp.MustCompile(`^[\w.+\-]+/[\w.+\-]+$`)
+)
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// Posts are written by the blog admins, their links are not user spam.
+	p.RequireNoFollowOnLinks(false)
+
+	// Heading anchors and footnote references.
+	p.AllowAttrs("id").OnElements("h1", "h2", "h3", "h4", "h5", "h6", "li", "sup", "div")
+	// Chroma highlighting and language hints on code blocks.
+	p.AllowAttrs("class").Matching(classNames).OnElements("pre", "code", "span", "div", "table", "td", "a", "sup", "section", "hr", "li", "ul", "input", "p")
+	// GFM task lists.
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	p.AllowElements("input")
+	// Colored text in older posts, e.g. <font style="color:coral">.
+	p.AllowAttrs("color").OnElements("font")
+	p.AllowElements("font")
+	p.AllowStyles("color").OnElements("font", "span")
+	// Media inserted by the editor's upload button.
+	p.AllowElements("video", "audio", "source")
+	p.AllowAttrs("controls", "width", "height", "preload", "poster").OnElements("video", "audio")
+	p.AllowAttrs("src").OnElements("video", "audio", "source")
+	p.AllowAttrs("type").Matching(mediaType).OnElements("source")
+	// Figures and image loading hints.
+	p.AllowElements("figure", "figcaption")
+	p.AllowAttrs("width", "height", "srcset", "sizes").OnElements("img")
+	p.AllowAttrs("loading").Matching(regexp.MustCompile(`^(lazy|eager)$`)).OnElements("img")
+	p.AllowAttrs("decoding").Matching(regexp.MustCompile(`^(async|sync|auto)$`)).OnElements("img")
+
+	// Shortcode embeds: YouTube players and gists for the frontend to load.
+	p.AllowElements("iframe")
+	p.AllowAttrs("src").Matching(regexp.MustCompile(`^https://www\.youtube-nocookie\.com/embed/[\w-]{11}(\?start=\d+)?$`)).OnElements("iframe")
+	p.AllowAttrs("title").OnElements("iframe")
+	p.AllowAttrs("allowfullscreen").OnElements("iframe")
+	p.AllowAttrs("loading").Matching(regexp.MustCompile(`^lazy$`)).OnElements("iframe")
+	p.AllowAttrs("data-gist").Matching(regexp.MustCompile(`^[\w-]+/[0-9a-fA-F]+$`)).OnElements("div")
+	p.AllowAttrs("data-gist-file").Matching(regexp.MustCompile(`^[\w.\-]+$`)).OnElements("div")
+
+	// MathML from TeX formulas.
+	p.AllowElements(mathMLElements...)
+	p.AllowNoAttrs().OnElements(mathMLElements...)
+	p.AllowAttrs("xmlns").Matching(regexp.MustCompile(`^http://www\.w3\.org/1998/Math/MathML$`)).OnElements("math")
+	p.AllowAttrs("display").Matching(regexp.MustCompile(`^(block|inline)$`)).OnElements("math")
+	p.AllowAttrs("encoding").Matching(mediaType).OnElements("annotation")
+	p.AllowAttrs("mathvariant").Matching(regexp.MustCompile(`^[a-z\-]+$`)).OnElements("mi", "mn", "mo", "mtext")
+	p.AllowAttrs("fence", "stretchy", "largeop", "movablelimits", "accent").Matching(regexp.MustCompile(`^(true|false)$`)).OnElements("mo", "mover")
+	p.AllowAttrs("form").Matching(regexp.MustCompile(`^(prefix|infix|postfix)$`)).OnElements("mo")
+	p.AllowAttrs("width", "linethickness").Matching(regexp.MustCompile(`^-?[\d.]+(em)?$`)).OnElements("mspace", "mfrac")
+	p.AllowAttrs("columnalign").Matching(regexp.MustCompile(`^(left|center|right)( (left|center|right))*$`)).OnElements("mtable")
+
+	return p
+}
+
+// mathMLElements are the presentation MathML elements TeXToMathML emits.
+var mathMLElements = []string{
+	"math", "semantics", "annotation", "mrow", "mi", "mn", "mo", "mtext",
+	"mspace", "msub", "msup", "msubsup", "munder", "mover", "munderover",
+	"mfrac", "msqrt", "mroot", "mtable", "mtr", "mtd",
+}
+
+// Sanitize strips anything from rendered HTML that is not on the allowlist.
+func Sanitize(html []byte) []byte {
+	return policy.SanitizeBytes(html)
+}
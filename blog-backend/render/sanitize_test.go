@@ -0,0 +1,96 @@
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSanitizeXSS renders each testdata/xss/*.md and checks that nothing
+// executable survives, while the markup posts rely on still does.
+func TestSanitizeXSS(t *testing.T) {
+	tests := []struct {
+		file string
+		// forbidden must not appear in the output, compared case-insensitively.
+		forbidden []string
+		// want must still appear.
+		want []string
+	}{
+		{
+			file:      "script.md",
+			forbidden: []string{"<script", "evil.example", "document.cookie</script"},
+			want:      []string{"Inline", "in a paragraph."},
+		},
+		{
+			file:      "event-handlers.md",
+			forbidden: []string{"onerror", "onclick", "onmouseover", "onloadstart", "alert("},
+			want:      []string{`src="/assets/a.png"`, `href="/posts/a"`, "<video controls", `type="video/mp4"`},
+		},
+		{
+			file:      "javascript-urls.md",
+			forbidden: []string{"javascript:"},
+			want:      []string{"markdown link", "html link", "mixed case"},
+		},
+		{
+			file:      "data-urls.md",
+			forbidden: []string{"data:", "<iframe", "<script"},
+			want:      []string{"<p>link</p>"},
+		},
+		{
+			file:      "iframes.md",
+			forbidden: []string{"evil.example", "www.youtube.com"},
+			want:      []string{`<iframe src="https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ" title="Talk">`},
+		},
+		{
+			file:      "styles.md",
+			forbidden: []string{"position", "background", "font-size", "evil.example"},
+			want:      []string{"overlay", "tracked", `<span style="color: coral">colored</span>`},
+		},
+		{
+			file:      "svg.md",
+			forbidden: []string{"<svg", "onload", "<script", "javascript:", "<use", "evil.example", "<circle"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			source, err := os.ReadFile(filepath.Join("testdata", "xss", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := Markdown.Render(source, Options{})
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			out := string(result.HTML)
+			for _, f := range tt.forbidden {
+				if strings.Contains(strings.ToLower(out), strings.ToLower(f)) {
+					t.Errorf("output contains %q:\n%s", f, out)
+				}
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(out, w) {
+					t.Errorf("output lacks %q:\n%s", w, out)
+				}
+			}
+		})
+	}
+}
+
+// TestSanitizeTrusted checks that Trusted is the one switch that lets raw
+// HTML through. Who may set it is enforced when a post is saved, see
+// TestTrustedRequiresSession in the handlers package.
+func TestSanitizeTrusted(t *testing.T) {
+	source, err := os.ReadFile(filepath.Join("testdata", "xss", "script.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Markdown.Render(source, Options{Trusted: true})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if !strings.Contains(string(result.HTML), "<script>alert(1)</script>") {
+		t.Errorf("trusted output was sanitized:\n%s", result.HTML)
+	}
+}
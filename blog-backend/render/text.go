@@ -0,0 +1,113 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// documentText returns the text of doc with markup stripped, one line per
+// block. Code blocks are kept since they take time to read too.
+func documentText(doc ast.Node, source []byte) string {
+	var buf bytes.Buffer
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch t := n.(type) {
+		case *ast.Text:
+			if entering {
+				writeText(&buf, t, source)
+			}
+		case *ast.String:
+			if entering {
+				buf.Write(t.Value)
+			}
+		case *Math:
+			if entering {
+				buf.Write(t.Formula)
+			}
+		case *ast.CodeBlock, *ast.FencedCodeBlock:
+			if entering {
+				lines := n.Lines()
+				for i := 0; i < lines.Len(); i++ {
+					seg := lines.At(i)
+					buf.Write(seg.Value(source))
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		if !entering && n.Type() == ast.TypeBlock {
+			buf.WriteByte('\n')
+		}
+		return ast.WalkContinue, nil
+	})
+	return buf.String()
+}
+
+// firstParagraph returns the text of the first paragraph that has any text
+// besides images, or "" if there is none. The [TOC] marker is skipped.
+func firstParagraph(doc ast.Node, source []byte) string {
+	var found string
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if found != "" {
+			return ast.WalkStop, nil
+		}
+		p, ok := n.(*ast.Paragraph)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		if s := inlineText(p, source, true); s != "" && s != "[TOC]" {
+			found = s
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	return found
+}
+
+// inlineText returns the text of the inline content of n without markup.
+// With skipImages set, image alt texts are left out.
+func inlineText(n ast.Node, source []byte, skipImages bool) string {
+	var buf bytes.Buffer
+	ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := c.(type) {
+		case *ast.Image:
+			if skipImages {
+				return ast.WalkSkipChildren, nil
+			}
+		case *ast.Text:
+			writeText(&buf, t, source)
+		case *ast.String:
+			buf.Write(t.Value)
+		case *Math:
+			buf.Write(t.Formula)
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(buf.String())
+}
+
+// writeText writes the literal value of t, with backslash escapes and
+// entities resolved.
+func writeText(buf *bytes.Buffer, t *ast.Text, source []byte) {
+	value := t.Segment.Value(source)
+	if !t.IsRaw() {
+		value = util.UnescapePunctuations(value)
+		value = util.ResolveNumericReferences(value)
+		value = util.ResolveEntityNames(value)
+	}
+	buf.Write(value)
+	if t.SoftLineBreak() || t.HardLineBreak() {
+		buf.WriteByte(' ')
+	}
+}
+
+// PlainText renders a short markdown snippet, such as a front matter summary,
+// to text without markup.
+func PlainText(markdown []byte) string {
+	doc := goldmarkParser.Parse(text.NewReader(markdown))
+	return strings.TrimSpace(strings.Join(strings.Fields(documentText(doc, markdown)), " "))
+}
@@ -0,0 +1,106 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+
+	"github.com/gg582/chi-blog/blog-backend/models"
+	"github.com/gg582/chi-blog/blog-backend/utils"
+)
+
+// tocMarker is the paragraph a post can contain to have its table of
+// contents rendered in place.
+const tocMarker = "<p>[TOC]</p>"
+
+// assignHeadingIDs gives every heading an anchor generated with the same
+// rules as post slugs, so Hangul and other non-ASCII headings keep readable
+// IDs. Markup is ignored, and repeated headings get "-1", "-2", ... suffixes.
+func assignHeadingIDs(doc ast.Node, source []byte) {
+	used := map[string]bool{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		base := utils.GenerateSlug(plainText(h, source))
+		if base == utils.UntitledSlug {
+			base = "section"
+		}
+		id := base
+		for i := 1; used[id]; i++ {
+			id = fmt.Sprintf("%s-%d", base, i)
+		}
+		used[id] = true
+		h.SetAttributeString("id", []byte(id))
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// buildTOC collects the headings of doc into a tree. A heading that opens
+// the document is the post's title and is left out; all other headings are
+// listed whatever their level, as many posts use "#" for their sections.
+func buildTOC(doc ast.Node, source []byte) []models.TOCEntry {
+	var root []models.TOCEntry
+	// path holds pointers to the last entry at each open nesting depth.
+	var path []*models.TOCEntry
+	title, _ := doc.FirstChild().(*ast.Heading)
+
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		if h == title {
+			return ast.WalkSkipChildren, nil
+		}
+		id, _ := h.AttributeString("id")
+		idBytes, _ := id.([]byte)
+		entry := models.TOCEntry{ID: string(idBytes), Title: plainText(h, source), Level: h.Level}
+
+		for len(path) > 0 && path[len(path)-1].Level >= h.Level {
+			path = path[:len(path)-1]
+		}
+		if len(path) == 0 {
+			root = append(root, entry)
+			path = append(path, &root[len(root)-1])
+		} else {
+			parent := path[len(path)-1]
+			parent.Children = append(parent.Children, entry)
+			path = append(path, &parent.Children[len(parent.Children)-1])
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	return root
+}
+
+// plainText returns the text content of n without markup.
+func plainText(n ast.Node, source []byte) string {
+	return inlineText(n, source, false)
+}
+
+// renderTOC writes toc as nested lists inside a <nav class="toc">.
+func renderTOC(toc []models.TOCEntry) string {
+	if len(toc) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(`<nav class="toc">`)
+	writeTOCList(&b, toc)
+	b.WriteString(`</nav>`)
+	return b.String()
+}
+
+func writeTOCList(b *strings.Builder, entries []models.TOCEntry) {
+	b.WriteString("<ul>")
+	for _, e := range entries {
+		fmt.Fprintf(b, `<li><a href="#%s">%s</a>`, html.EscapeString(e.ID), html.EscapeString(e.Title))
+		if len(e.Children) > 0 {
+			writeTOCList(b, e.Children)
+		}
+		b.WriteString("</li>")
+	}
+	b.WriteString("</ul>")
+}
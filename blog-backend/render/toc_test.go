@@ -0,0 +1,51 @@
+package render
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gg582/chi-blog/blog-backend/models"
+)
+
+func TestTOC(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string // "level:id" of the top-level entries
+	}{
+		{"h1 sections after the title", "# Title\n\ntext\n\n# One\n\n## One A\n\n# Two\n", []string{"1:one", "1:two"}},
+		{"h2 sections", "# Title\n\n## One\n\n## Two\n", []string{"2:one", "2:two"}},
+		{"no title heading", "Intro\n\n# One\n\n# Two\n", []string{"1:one", "1:two"}},
+		{"title only", "# Title\n\ntext\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Markdown.Render([]byte(tt.source), Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range result.TOC {
+				got = append(got, entryKey(e))
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("TOC = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("TOC = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+
+	result, _ := Markdown.Render([]byte("# Title\n\n# One\n\n## One A\n"), Options{})
+	if len(result.TOC) != 1 || len(result.TOC[0].Children) != 1 || result.TOC[0].Children[0].ID != "one-a" {
+		t.Errorf("subsection not nested under its section: %+v", result.TOC)
+	}
+}
+
+func entryKey(e models.TOCEntry) string {
+	return fmt.Sprintf("%d:%s", e.Level, e.ID)
+}
@@ -0,0 +1,168 @@
+package shortcode
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	Register("youtube", Shortcode{Render: youtube})
+	Register("gist", Shortcode{Render: gist})
+	Register("figure", Shortcode{Render: figure})
+	Register("callout", Shortcode{Paired: true, Render: callout})
+	Register("admonition", Shortcode{Paired: true, Render: callout})
+	Register("post", Shortcode{Render: postLink})
+}
+
+var (
+	youtubeID = regexp.MustCompile(`^[\w-]{11}$`)
+	gistUser  = regexp.MustCompile(`^[\w-]+$`)
+	gistID    = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+	gistFile  = regexp.MustCompile(`^[\w.\-]+$`)
+	digits    = regexp.MustCompile(`^\d+$`)
+)
+
+// youtube embeds a video through the privacy-enhanced domain:
+//
+//	{{< youtube dQw4w9WgXcQ start=42 title="Talk" >}}
+func youtube(c *Call) (string, error) {
+	id := c.Arg("id", 0)
+	if !youtubeID.MatchString(id) {
+		return "", fmt.Errorf("invalid video id %q", id)
+	}
+	src := "https://www.youtube-nocookie.com/embed/" + id
+	if start := c.Arg("start", 1); start != "" {
+		if !digits.MatchString(start) {
+			return "", fmt.Errorf("start must be a number of seconds, got %q", start)
+		}
+		src += "?start=" + start
+	}
+	title := c.Arg("title", -1)
+	if title == "" {
+		title = "YouTube video"
+	}
+	return fmt.Sprintf(`<div class="video-embed"><iframe src="%s" title="%s" loading="lazy" allowfullscreen></iframe></div>`,
+		src, html.EscapeString(title)), nil
+}
+
+// gist links a GitHub gist; the frontend turns the link into an embed.
+//
+//	{{< gist user 0123abcd [file.go] >}}
+func gist(c *Call) (string, error) {
+	user, id, file := c.Arg("user", 0), c.Arg("id", 1), c.Arg("file", 2)
+	if !gistUser.MatchString(user) {
+		return "", fmt.Errorf("invalid user %q", user)
+	}
+	if !gistID.MatchString(id) {
+		return "", fmt.Errorf("invalid gist id %q", id)
+	}
+	attrs := fmt.Sprintf(`data-gist="%s/%s"`, user, id)
+	href := "https://gist.github.com/" + user + "/" + id
+	if file != "" {
+		if !gistFile.MatchString(file) {
+			return "", fmt.Errorf("invalid file name %q", file)
+		}
+		attrs += fmt.Sprintf(` data-gist-file="%s"`, file)
+		href += "#file-" + strings.ReplaceAll(strings.ToLower(file), ".", "-")
+	}
+	return fmt.Sprintf(`<div class="gist" %s><a href="%s">View gist %s/%s on GitHub</a></div>`, attrs, href, user, id), nil
+}
+
+// figure shows an image with a caption:
+//
+//	{{< figure src="/assets/diagram.png" alt="Operators" caption="Operator graph" >}}
+func figure(c *Call) (string, error) {
+	src := c.Arg("src", 0)
+	if src == "" {
+		return "", errors.New("src is required")
+	}
+	u, err := url.Parse(src)
+	if err != nil || (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("invalid src %q", src)
+	}
+	caption := c.Arg("caption", 1)
+	alt := c.Arg("alt", -1)
+	if alt == "" {
+		alt = caption
+	}
+
+	var b strings.Builder
+	b.WriteString("<figure>")
+	fmt.Fprintf(&b, `<img src="%s" alt="%s"`, html.EscapeString(u.String()), html.EscapeString(alt))
+	for _, attr := range []string{"width", "height"} {
+		if v := c.Arg(attr, -1); v != "" {
+			if !digits.MatchString(v) {
+				return "", fmt.Errorf("%s must be a number, got %q", attr, v)
+			}
+			fmt.Fprintf(&b, ` %s="%s"`, attr, v)
+		}
+	}
+	b.WriteString(` loading="lazy" decoding="async">`)
+	if caption != "" {
+		b.WriteString("<figcaption>" + html.EscapeString(caption) + "</figcaption>")
+	}
+	b.WriteString("</figure>")
+	return b.String(), nil
+}
+
+// calloutKinds are the callout types with their default titles.
+var calloutKinds = map[string]string{
+	"note":    "Note",
+	"info":    "Info",
+	"tip":     "Tip",
+	"warning": "Warning",
+	"danger":  "Danger",
+}
+
+// callout puts markdown into a highlighted box:
+//
+//	{{< callout warning title="Careful" >}}
+//	Markdown **content**.
+//	{{< /callout >}}
+func callout(c *Call) (string, error) {
+	kind := c.Arg("type", 0)
+	if kind == "" {
+		kind = "note"
+	}
+	title, ok := calloutKinds[kind]
+	if !ok {
+		return "", fmt.Errorf("unknown type %q, expected note, info, tip, warning or danger", kind)
+	}
+	if t := c.Arg("title", -1); t != "" {
+		title = t
+	}
+	// The blank lines end the HTML blocks, so the content is parsed as
+	// markdown.
+	return fmt.Sprintf("<div class=\"callout callout-%s\">\n<p class=\"callout-title\">%s</p>\n\n%s\n\n</div>",
+		kind, html.EscapeString(title), c.Inner), nil
+}
+
+// postLink links another post by slug, with its title as the default text:
+//
+//	{{< post "stream-processing-basics" ["link text"] >}}
+func postLink(c *Call) (string, error) {
+	slug := c.Arg("slug", 0)
+	if slug == "" {
+		return "", errors.New("slug is required")
+	}
+	if c.Posts == nil {
+		return "", errors.New("post links are not available here")
+	}
+	title, ok := c.Posts(slug)
+	if text := c.Arg("text", 1); text != "" {
+		title = text
+	} else if !ok {
+		title = slug
+	}
+	if !ok {
+		if c.Lenient {
+			return fmt.Sprintf(`<span class="post-link-missing">%s</span>`, html.EscapeString(title)), nil
+		}
+		return "", fmt.Errorf("post %q does not exist", slug)
+	}
+	return fmt.Sprintf(`<a href="/posts/%s">%s</a>`, url.PathEscape(slug), html.EscapeString(title)), nil
+}
@@ -0,0 +1,322 @@
+// Package shortcode expands {{< name args >}} directives in post markdown
+// before it is rendered. Each shortcode is a Go handler in the registry;
+// the built-in ones live in builtin.go.
+//
+// Arguments are positional or name=value, and values containing spaces are
+// quoted: {{< figure src="/assets/a.png" caption="A caption" >}}. Paired
+// shortcodes wrap markdown and are closed by {{< /name >}}. Shortcodes inside
+// code blocks and code spans are left alone, and {{</* name */>}} produces
+// the literal text {{< name >}}.
+package shortcode
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PostLookup returns the title of the post with the given slug, and whether
+// that post exists.
+type PostLookup func(slug string) (title string, ok bool)
+
+// Call is a single use of a shortcode.
+type Call struct {
+	Name string
+	// Args are the positional arguments, Named the name=value ones.
+	Args  []string
+	Named map[string]string
+	// Inner is the markdown wrapped by a paired shortcode, with nested
+	// shortcodes already expanded.
+	Inner string
+	// Posts checks links to other posts.
+	Posts PostLookup
+	// Lenient is set by ExpandLenient.
+	Lenient bool
+}
+
+// Arg returns the named argument name, or else the positional argument at
+// index i, or "" if neither is given.
+func (c *Call) Arg(name string, i int) string {
+	if v, ok := c.Named[name]; ok {
+		return v
+	}
+	if i >= 0 && i < len(c.Args) {
+		return c.Args[i]
+	}
+	return ""
+}
+
+// Shortcode is a registered shortcode handler.
+type Shortcode struct {
+	// Paired shortcodes wrap content and must be closed with {{< /name >}}.
+	Paired bool
+	// Render returns the markdown or HTML that replaces the call.
+	Render func(c *Call) (string, error)
+}
+
+var registry = map[string]Shortcode{}
+
+// Register adds a shortcode under name, replacing any earlier one.
+func Register(name string, s Shortcode) {
+	registry[name] = s
+}
+
+// Names returns the registered shortcode names in order.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Error is a shortcode that could not be expanded.
+type Error struct {
+	// Line is the 1-based line of the shortcode in the source.
+	Line int
+	Name string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: shortcode %q: %v", e.Line, e.Name, e.Err)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+var (
+	tagPattern     = regexp.MustCompile(`\{\{<\s*(/?)\s*([\w-]+)((?:"(?:[^"\\]|\\.)*"|[^"])*?)\s*>\}\}`)
+	escapedPattern = regexp.MustCompile(`\{\{<\s*/\*(.*?)\*/\s*>\}\}`)
+	argPattern     = regexp.MustCompile("^\\s*(?:([\\w-]+)=)?(\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`|[^\\s\"`]+)")
+)
+
+// frame collects the output of a paired shortcode until it is closed.
+type frame struct {
+	call *Call
+	line int
+	out  strings.Builder
+}
+
+// Expand replaces every shortcode in source with its output. The first
+// shortcode that fails, is unknown or is not closed stops expansion with an
+// *Error.
+func Expand(source []byte, posts PostLookup) ([]byte, error) {
+	return expand(source, posts, false)
+}
+
+// ExpandLenient is Expand for posts that were checked when they were saved.
+// Links to posts that have since been renamed, deleted or turned back into
+// drafts render as plain text instead of failing the whole post; the check
+// command reports them.
+func ExpandLenient(source []byte, posts PostLookup) ([]byte, error) {
+	return expand(source, posts, true)
+}
+
+func expand(source []byte, posts PostLookup, lenient bool) ([]byte, error) {
+	src := string(source)
+	// Tags are searched for with the code blanked out, so that a "{{<" in
+	// code cannot start a match that swallows a shortcode after it.
+	masked := mask(src, codeRanges(src))
+	if err := checkMalformed(masked); err != nil {
+		return nil, err
+	}
+	stack := []*frame{{}}
+	last := 0
+	for _, m := range tagPattern.FindAllStringSubmatchIndex(masked, -1) {
+		line := strings.Count(src[:m[0]], "\n") + 1
+		top := stack[len(stack)-1]
+		top.out.WriteString(src[last:m[0]])
+		last = m[1]
+
+		closing, name := src[m[2]:m[3]] == "/", src[m[4]:m[5]]
+		sc, ok := registry[name]
+		if !ok {
+			return nil, &Error{Line: line, Name: name, Err: fmt.Errorf("unknown shortcode, expected one of %s", strings.Join(Names(), ", "))}
+		}
+		if closing {
+			if top.call == nil || top.call.Name != name {
+				return nil, &Error{Line: line, Name: name, Err: fmt.Errorf("closing tag without an opening one")}
+			}
+			stack = stack[:len(stack)-1]
+			top.call.Inner = strings.Trim(top.out.String(), "\n")
+			out, err := sc.Render(top.call)
+			if err != nil {
+				return nil, &Error{Line: top.line, Name: name, Err: err}
+			}
+			stack[len(stack)-1].out.WriteString(out)
+			continue
+		}
+
+		call, err := parseArgs(src[m[6]:m[7]])
+		if err != nil {
+			return nil, &Error{Line: line, Name: name, Err: err}
+		}
+		call.Name, call.Posts, call.Lenient = name, posts, lenient
+		if sc.Paired {
+			stack = append(stack, &frame{call: call, line: line})
+			continue
+		}
+		out, err := sc.Render(call)
+		if err != nil {
+			return nil, &Error{Line: line, Name: name, Err: err}
+		}
+		top.out.WriteString(out)
+	}
+	if len(stack) > 1 {
+		open := stack[len(stack)-1]
+		return nil, &Error{Line: open.line, Name: open.call.Name, Err: fmt.Errorf("missing {{< /%s >}}", open.call.Name)}
+	}
+	stack[0].out.WriteString(src[last:])
+	return []byte(unescape(stack[0].out.String())), nil
+}
+
+// checkMalformed reports a "{{<" that does not start a shortcode, such as
+// one with an unterminated quote. Code must already be masked out of src.
+func checkMalformed(src string) error {
+	valid := map[int]bool{}
+	for _, m := range tagPattern.FindAllStringIndex(src, -1) {
+		valid[m[0]] = true
+	}
+	for _, m := range escapedPattern.FindAllStringIndex(src, -1) {
+		valid[m[0]] = true
+	}
+	for pos := 0; ; pos++ {
+		i := strings.Index(src[pos:], "{{<")
+		if i < 0 {
+			return nil
+		}
+		pos += i
+		if !valid[pos] {
+			return &Error{Line: strings.Count(src[:pos], "\n") + 1, Err: fmt.Errorf("malformed shortcode, expected {{< name args >}}")}
+		}
+	}
+}
+
+// parseArgs splits the argument list of a shortcode.
+func parseArgs(s string) (*Call, error) {
+	call := &Call{Named: map[string]string{}}
+	for strings.TrimSpace(s) != "" {
+		m := argPattern.FindStringSubmatch(s)
+		if m == nil {
+			return nil, fmt.Errorf("malformed arguments %q", strings.TrimSpace(s))
+		}
+		s = s[len(m[0]):]
+		value := m[2]
+		if value[0] == '"' || value[0] == '`' {
+			var err error
+			if value, err = strconv.Unquote(value); err != nil {
+				return nil, fmt.Errorf("malformed argument %s", m[2])
+			}
+		}
+		if m[1] != "" {
+			call.Named[m[1]] = value
+		} else {
+			call.Args = append(call.Args, value)
+		}
+	}
+	return call, nil
+}
+
+// unescape turns {{</* name */>}} outside of code into {{< name >}}.
+func unescape(s string) string {
+	code := codeRanges(s)
+	var b strings.Builder
+	last := 0
+	for _, m := range escapedPattern.FindAllStringSubmatchIndex(s, -1) {
+		if inRanges(code, m[0]) {
+			continue
+		}
+		b.WriteString(s[last:m[0]])
+		b.WriteString("{{<" + s[m[2]:m[3]] + ">}}")
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// mask replaces the bytes of s in ranges with spaces, keeping line breaks so
+// that line numbers stay the same.
+func mask(s string, ranges [][2]int) string {
+	b := []byte(s)
+	for _, r := range ranges {
+		for i := r[0]; i < r[1]; i++ {
+			if b[i] != '\n' {
+				b[i] = ' '
+			}
+		}
+	}
+	return string(b)
+}
+
+// codeRanges returns the byte ranges of fenced code blocks and code spans in
+// s, where shortcodes are not expanded.
+func codeRanges(s string) [][2]int {
+	var ranges [][2]int
+	var fence string
+	fenceStart := 0
+	for pos := 0; pos < len(s); {
+		end := strings.IndexByte(s[pos:], '\n')
+		if end < 0 {
+			end = len(s)
+		} else {
+			end += pos + 1
+		}
+		line := strings.TrimLeft(s[pos:end], " ")
+		switch {
+		case fence != "":
+			if strings.HasPrefix(line, fence) && strings.TrimSpace(strings.TrimLeft(line, fence[:1])) == "" {
+				ranges = append(ranges, [2]int{fenceStart, end})
+				fence = ""
+			}
+		case len(s[pos:end])-len(line) < 4 && (strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~")):
+			n := len(line) - len(strings.TrimLeft(line, line[:1]))
+			fence, fenceStart = line[:n], pos
+		default:
+			ranges = append(ranges, codeSpans(s, pos, end)...)
+		}
+		pos = end
+	}
+	if fence != "" {
+		ranges = append(ranges, [2]int{fenceStart, len(s)})
+	}
+	return ranges
+}
+
+// codeSpans returns the code spans on the line s[start:end].
+func codeSpans(s string, start, end int) [][2]int {
+	var ranges [][2]int
+	for i := start; i < end; {
+		if s[i] != '`' {
+			i++
+			continue
+		}
+		n := i
+		for n < end && s[n] == '`' {
+			n++
+		}
+		ticks := s[i:n]
+		closing := strings.Index(s[n:end], ticks)
+		if closing < 0 {
+			i = n
+			continue
+		}
+		ranges = append(ranges, [2]int{i, n + closing + len(ticks)})
+		i = n + closing + len(ticks)
+	}
+	return ranges
+}
+
+func inRanges(ranges [][2]int, pos int) bool {
+	for _, r := range ranges {
+		if pos >= r[0] && pos < r[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package shortcode
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func init() {
+	// echo shows the arguments it received.
+	Register("echo", Shortcode{Render: func(c *Call) (string, error) {
+		return "[" + strings.Join(c.Args, "|") + "]", nil
+	}})
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args  string
+		want  []string
+		named map[string]string
+	}{
+		{"", nil, map[string]string{}},
+		{" a b ", []string{"a", "b"}, map[string]string{}},
+		{`"two words" b`, []string{"two words", "b"}, map[string]string{}},
+		{`src="/a b.png" caption="A \"quoted\" caption"`, nil, map[string]string{"src": "/a b.png", "caption": `A "quoted" caption`}},
+		{"x `raw \\n` n=1", []string{"x", `raw \n`}, map[string]string{"n": "1"}},
+		{`id start=42 title="Talk"`, []string{"id"}, map[string]string{"start": "42", "title": "Talk"}},
+	}
+	for _, tt := range tests {
+		call, err := parseArgs(tt.args)
+		if err != nil {
+			t.Errorf("parseArgs(%q): %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(call.Args, tt.want) || !reflect.DeepEqual(call.Named, tt.named) {
+			t.Errorf("parseArgs(%q) = %q %q, want %q %q", tt.args, call.Args, call.Named, tt.want, tt.named)
+		}
+	}
+
+	for _, args := range []string{`"unterminated`, `a="x"y"`} {
+		if _, err := parseArgs(args); err == nil {
+			t.Errorf("parseArgs(%q) accepted malformed arguments", args)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		name, source, want string
+	}{
+		{"positional and quoted", `a {{< echo x "y z" >}} b`, "a [x|y z] b"},
+		{"no spaces", `{{<echo x>}}`, "[x]"},
+		{"escaped", `{{</* echo x */>}}`, "{{< echo x >}}"},
+		{"code span", "`{{< echo x >}}` {{< echo y >}}", "`{{< echo x >}}` [y]"},
+		{"double backtick span", "``a ` {{< nope >}}`` ok", "``a ` {{< nope >}}`` ok"},
+		{"fenced code", "```\n{{< nope >}}\n{{< bad\n```\n{{< echo y >}}", "```\n{{< nope >}}\n{{< bad\n```\n[y]"},
+		{"tilde fence", "~~~go\n{{< nope >}}\n~~~\n", "~~~go\n{{< nope >}}\n~~~\n"},
+		{"unclosed fence", "```\n{{< nope >}}\n", "```\n{{< nope >}}\n"},
+		{"escaped in code stays", "`{{</* echo */>}}`", "`{{</* echo */>}}`"},
+		{"paired", "{{< callout tip >}}\n**Hi** {{< echo x >}}\n{{< /callout >}}",
+			"<div class=\"callout callout-tip\">\n<p class=\"callout-title\">Tip</p>\n\n**Hi** [x]\n\n</div>"},
+		{"nested paired", "{{< callout >}}{{< admonition warning title=\"T\" >}}x{{< /admonition >}}{{< /callout >}}",
+			"<div class=\"callout callout-note\">\n<p class=\"callout-title\">Note</p>\n\n<div class=\"callout callout-warning\">\n<p class=\"callout-title\">T</p>\n\nx\n\n</div>\n\n</div>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Expand([]byte(tt.source), nil)
+			if err != nil {
+				t.Fatalf("Expand: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expand(%q)\n got %q\nwant %q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	tests := []struct {
+		name, source string
+		line         int
+		shortcode    string
+		want         string
+	}{
+		{"unknown", "text\n\n{{< nope >}}", 3, "nope", "unknown shortcode"},
+		{"unclosed", "{{< callout >}}\n\ntext", 1, "callout", "missing {{< /callout >}}"},
+		{"closing without opening", "a\n{{< /callout >}}", 2, "callout", "closing tag without an opening one"},
+		{"crossed", "{{< callout >}}\n{{< admonition >}}\n{{< /callout >}}", 3, "callout", "closing tag without an opening one"},
+		{"malformed", "a\nb\n{{< echo \"x >}}", 3, "", "malformed shortcode"},
+		{"handler error", "\n{{< youtube short >}}", 2, "youtube", `invalid video id "short"`},
+		{"paired handler error on the opening line", "\n{{< callout purple >}}\nx\n{{< /callout >}}", 2, "callout", `unknown type "purple"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Expand([]byte(tt.source), nil)
+			var scErr *Error
+			if !errors.As(err, &scErr) {
+				t.Fatalf("Expand error = %v, want an *Error", err)
+			}
+			if scErr.Line != tt.line || scErr.Name != tt.shortcode || !strings.Contains(scErr.Err.Error(), tt.want) {
+				t.Errorf("Expand error = line %d, %q, %v; want line %d, %q, %q", scErr.Line, scErr.Name, scErr.Err, tt.line, tt.shortcode, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostLinks(t *testing.T) {
+	posts := func(slug string) (string, bool) {
+		if slug == "a" {
+			return "A <Post>", true
+		}
+		return "", false
+	}
+	tests := []struct {
+		source, want, lenient string
+	}{
+		{`{{< post a >}}`, `<a href="/posts/a">A &lt;Post&gt;</a>`, ""},
+		{`{{< post "a" "other text" >}}`, `<a href="/posts/a">other text</a>`, ""},
+		{`{{< post slug=gone >}}`, "", `<span class="post-link-missing">gone</span>`},
+		{`{{< post gone "Old <title>" >}}`, "", `<span class="post-link-missing">Old &lt;title&gt;</span>`},
+	}
+	for _, tt := range tests {
+		got, err := Expand([]byte(tt.source), posts)
+		if tt.want != "" {
+			if err != nil || string(got) != tt.want {
+				t.Errorf("Expand(%q) = %q, %v; want %q", tt.source, got, err, tt.want)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), "does not exist") {
+			t.Errorf("Expand(%q) = %q, %v; want a missing post error", tt.source, got, err)
+		}
+
+		want := tt.lenient
+		if want == "" {
+			want = tt.want
+		}
+		if got, err := ExpandLenient([]byte(tt.source), posts); err != nil || string(got) != want {
+			t.Errorf("ExpandLenient(%q) = %q, %v; want %q", tt.source, got, err, want)
+		}
+	}
+
+	// Lenient mode only forgives missing posts.
+	if _, err := ExpandLenient([]byte(`{{< post >}}`), posts); err == nil {
+		t.Error("ExpandLenient accepted a post link without a slug")
+	}
+	if _, err := ExpandLenient([]byte(`{{< nope >}}`), posts); err == nil {
+		t.Error("ExpandLenient accepted an unknown shortcode")
+	}
+	if _, err := Expand([]byte(`{{< post a >}}`), nil); err == nil {
+		t.Error("post link expanded without a post lookup")
+	}
+}
@@ -0,0 +1,58 @@
+// Package site knows the public addresses of the blog, for links that leave
+// the server such as webmention sources and ActivityPub ids.
+package site
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/gg582/chi-blog/blog-backend/render"
+)
+
+// URL is the public base URL of the blog, without a trailing slash. Posts
+// are served at URL/posts/<slug>.
+var URL = "https://chatter.pw"
+
+// APIURL is the public base URL of this backend, without a trailing slash.
+// Documents other servers fetch, such as ActivityPub actors, live under it.
+var APIURL = "https://chatter.pw:8080"
+
+// Host returns the host name of URL, the domain of the blog's handles.
+func Host() string {
+	if u, err := url.Parse(URL); err == nil {
+		return u.Hostname()
+	}
+	return ""
+}
+
+// PostURL returns the public URL of the post slug.
+func PostURL(slug string) string {
+	return URL + "/posts/" + url.PathEscape(slug)
+}
+
+// PostSlug returns the slug of the post that u points to, if u is a post on
+// this blog. Hosts in render.SiteHosts count as this blog on any port.
+func PostSlug(u *url.URL) (string, bool) {
+	if u.Scheme != "http" && u.Scheme != "https" || !IsHost(u.Hostname()) {
+		return "", false
+	}
+	slug, ok := strings.CutPrefix(u.Path, "/posts/")
+	slug = strings.TrimSuffix(slug, "/")
+	if !ok || slug == "" || strings.Contains(slug, "/") {
+		return "", false
+	}
+	return slug, true
+}
+
+// IsHost reports whether host, without port, is a name of this blog.
+func IsHost(host string) bool {
+	if strings.EqualFold(Host(), host) {
+		return true
+	}
+	for _, h := range render.SiteHosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package stats
+
+import (
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+)
+
+// PostViews is the number of views of a post.
+type PostViews struct {
+	ID    string `json:"id"`
+	Views int    `json:"views"`
+}
+
+// DayViews is the number of views on a day.
+type DayViews struct {
+	Day   string `json:"day"`
+	Views int    `json:"views"`
+}
+
+// ReferrerViews is the number of views coming from a site. An empty
+// referrer stands for direct visits and links within the blog.
+type ReferrerViews struct {
+	Referrer string `json:"referrer"`
+	Views    int    `json:"views"`
+}
+
+// Report summarizes the views between two days.
+type Report struct {
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Total     int             `json:"total"`
+	Posts     []PostViews     `json:"posts"`     // Most viewed first
+	Days      []DayViews      `json:"days"`      // Every day from From to To
+	Referrers []ReferrerViews `json:"referrers"` // Most views first
+}
+
+// Query reports the views from the UTC day of from to that of to, both
+// inclusive. A non-empty postID limits it to one post. Views from the last
+// few seconds may not be written yet.
+func Query(from, to time.Time, postID string) (*Report, error) {
+	from, to = from.UTC().Truncate(24*time.Hour), to.UTC().Truncate(24*time.Hour)
+	rep := &Report{
+		From:      from.Format(DayFormat),
+		To:        to.Format(DayFormat),
+		Posts:     []PostViews{},
+		Days:      []DayViews{},
+		Referrers: []ReferrerViews{},
+	}
+	where := " FROM post_views WHERE day >= ? AND day <= ?"
+	args := []any{rep.From, rep.To}
+	if postID != "" {
+		where += " AND post_id = ?"
+		args = append(args, postID)
+	}
+
+	byDay := map[string]int{}
+	err := scan("SELECT day, SUM(views)"+where+" GROUP BY day", args, func(key string, views int) {
+		byDay[key] = views
+		rep.Total += views
+	})
+	if err != nil {
+		return nil, err
+	}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		day := d.Format(DayFormat)
+		rep.Days = append(rep.Days, DayViews{Day: day, Views: byDay[day]})
+	}
+
+	err = scan("SELECT post_id, SUM(views) AS n"+where+" GROUP BY post_id ORDER BY n DESC, post_id", args, func(key string, views int) {
+		rep.Posts = append(rep.Posts, PostViews{ID: key, Views: views})
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = scan("SELECT referrer, SUM(views) AS n"+where+" GROUP BY referrer ORDER BY n DESC, referrer", args, func(key string, views int) {
+		rep.Referrers = append(rep.Referrers, ReferrerViews{Referrer: key, Views: views})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
+
+// scan runs a query returning (key, views) rows and calls fn for each.
+func scan(query string, args []any, fn func(key string, views int)) error {
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		var views int
+		if err := rows.Scan(&key, &views); err != nil {
+			return err
+		}
+		fn(key, views)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,214 @@
+// Package stats counts post views without cookies or third-party scripts.
+// A visitor is identified by a hash of their IP address and user agent with
+// a salt that is kept in memory only and replaced every day, so views can be
+// deduplicated within a day but not linked across days or to a person.
+package stats
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+	"github.com/gg582/chi-blog/blog-backend/site"
+)
+
+// DayFormat is the layout of the days counts are grouped by, in UTC.
+const DayFormat = "2006-01-02"
+
+// View is a deduplicated view of a post waiting to be written.
+type View struct {
+	PostID   string
+	Day      string
+	Referrer string // host of the referring site, "" when direct or internal
+}
+
+// ViewQueue feeds the recorder. It is nil until the server starts it, and
+// views are dropped while it is.
+var ViewQueue chan View
+
+// seenBits is the size of the filter of visitors seen in a day. At 128 KiB
+// it stays under 2% false positives up to about 100,000 visits a day; past
+// that, some first views are taken for repeats and not counted.
+const seenBits = 1 << 20
+
+// seenFilter is a bloom filter of the visitor hashes seen in a day. Its size
+// is fixed, so a flood of distinct visitors cannot grow it.
+type seenFilter [seenBits / 64]uint64
+
+// testAndSet reports whether sum was probably added before, and adds it.
+// The bit positions are taken from the first 16 bytes of the hash.
+func (f *seenFilter) testAndSet(sum []byte) bool {
+	seen := true
+	for i := 0; i < 4; i++ {
+		bit := binary.BigEndian.Uint32(sum[4*i:]) % seenBits
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if f[word]&mask == 0 {
+			seen = false
+			f[word] |= mask
+		}
+	}
+	return seen
+}
+
+// daily holds the salt of the current day and the visitors seen with it.
+var daily struct {
+	sync.Mutex
+	day  string
+	salt []byte
+	seen *seenFilter
+}
+
+// Record counts a view of postID by the visitor with ip and userAgent,
+// unless they already viewed it today or look like a crawler. referrer is
+// the page the visitor came from, if known. It never blocks.
+func Record(postID, ip, userAgent, referrer string) {
+	if isBot(userAgent) {
+		return
+	}
+	now := time.Now().UTC()
+	day := now.Format(DayFormat)
+
+	daily.Lock()
+	if daily.day != day {
+		daily.day = day
+		daily.salt = make([]byte, 32)
+		rand.Read(daily.salt)
+		daily.seen = new(seenFilter)
+	}
+	h := sha256.New()
+	h.Write(daily.salt)
+	h.Write([]byte(ip + "\x00" + userAgent + "\x00" + postID))
+	seen := daily.seen.testAndSet(h.Sum(nil))
+	daily.Unlock()
+	if seen {
+		return
+	}
+
+	select {
+	case ViewQueue <- View{PostID: postID, Day: day, Referrer: referrerHost(referrer)}:
+	default:
+		// Counting is best effort; a full queue must not slow down reads.
+	}
+}
+
+// isBot reports whether userAgent belongs to a crawler or is missing.
+func isBot(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		return true
+	}
+	for _, s := range []string{"bot", "crawl", "spider", "slurp", "curl/", "wget/", "python-requests", "go-http-client"} {
+		if strings.Contains(ua, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// referrerHost reduces a referrer to its host name, so that no paths or
+// query strings of other sites are stored. Links within the blog count as
+// no referrer.
+func referrerHost(referrer string) string {
+	u, err := url.Parse(referrer)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" || site.IsHost(u.Hostname()) {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// stopRecorder asks the recorder to write what it has and exit. It is nil
+// until NewRecorder is called.
+var stopRecorder chan chan struct{}
+
+// NewRecorder starts writing views from queue to the database, batched
+// every interval or when maxBatch distinct counts are pending.
+func NewRecorder(queue chan View, interval time.Duration, maxBatch int) {
+	stop := make(chan chan struct{})
+	stopRecorder = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		pending := map[View]int{}
+		for {
+			select {
+			case done := <-stop:
+				for drained := false; !drained; {
+					select {
+					case v := <-queue:
+						pending[v]++
+					default:
+						drained = true
+					}
+				}
+				if len(pending) > 0 {
+					flush(pending)
+				}
+				close(done)
+				return
+			case v, ok := <-queue:
+				if !ok {
+					flush(pending)
+					return
+				}
+				pending[v]++
+				if len(pending) < maxBatch {
+					continue
+				}
+			case <-ticker.C:
+			}
+			if len(pending) > 0 {
+				flush(pending)
+				pending = map[View]int{}
+			}
+		}
+	}()
+}
+
+// Stop writes the views queued and pending in the recorder to the database
+// and stops it. Views recorded afterwards are not written. It is meant for
+// shutdown, where the queue cannot be closed because requests may still be
+// sending to it.
+func Stop() {
+	if stopRecorder == nil {
+		return
+	}
+	done := make(chan struct{})
+	stopRecorder <- done
+	<-done
+	stopRecorder = nil
+}
+
+// flush adds the pending counts to the database in one transaction.
+func flush(pending map[View]int) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		log.Printf("Failed to write %d view counts: %v", len(pending), err)
+		return
+	}
+	stmt, err := tx.Prepare(
+		`INSERT INTO post_views (post_id, day, referrer, views) VALUES (?,?,?,?)
+		ON CONFLICT (post_id, day, referrer) DO UPDATE SET views = views + excluded.views`,
+	)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Failed to write %d view counts: %v", len(pending), err)
+		return
+	}
+	defer stmt.Close()
+	for v, n := range pending {
+		if _, err := stmt.Exec(v.PostID, v.Day, v.Referrer, n); err != nil {
+			tx.Rollback()
+			log.Printf("Failed to write %d view counts: %v", len(pending), err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to write %d view counts: %v", len(pending), err)
+	}
+}
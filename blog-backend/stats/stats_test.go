@@ -0,0 +1,65 @@
+package stats
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+)
+
+const browser = "Mozilla/5.0 (X11; Linux x86_64)"
+
+func TestRecordDeduplicates(t *testing.T) {
+	old := ViewQueue
+	ViewQueue = make(chan View, 10)
+	t.Cleanup(func() { ViewQueue = old })
+
+	Record("a", "192.0.2.1", browser, "https://news.example/item")
+	Record("a", "192.0.2.1", browser, "")
+	Record("b", "192.0.2.1", browser, "")
+	Record("a", "192.0.2.2", browser, "")
+	Record("a", "192.0.2.3", "Googlebot/2.1", "")
+	if len(ViewQueue) != 3 {
+		t.Fatalf("%d views queued, want 3", len(ViewQueue))
+	}
+	if v := <-ViewQueue; v.PostID != "a" || v.Referrer != "news.example" {
+		t.Errorf("first view = %+v", v)
+	}
+}
+
+func TestSeenFilter(t *testing.T) {
+	var f seenFilter
+	a := []byte("0123456789abcdef0123456789abcdef")
+	b := []byte("fedcba9876543210fedcba9876543210")
+	if f.testAndSet(a) || f.testAndSet(b) {
+		t.Fatal("new hashes reported as seen")
+	}
+	if !f.testAndSet(a) || !f.testAndSet(b) {
+		t.Error("added hashes reported as unseen")
+	}
+}
+
+// TestStopFlushes checks that views still batched or queued are written
+// when the recorder is stopped, long before its interval.
+func TestStopFlushes(t *testing.T) {
+	database.Path = filepath.Join(t.TempDir(), "auth.db")
+	database.InitDatabase()
+	t.Cleanup(func() { database.DB.Close() })
+
+	queue := make(chan View, 10)
+	NewRecorder(queue, time.Hour, 100)
+	queue <- View{PostID: "a", Day: "2026-10-19"}
+	queue <- View{PostID: "a", Day: "2026-10-19"}
+	queue <- View{PostID: "b", Day: "2026-10-19", Referrer: "news.example"}
+	Stop()
+
+	var views, rows int
+	if err := database.DB.QueryRow("SELECT COALESCE(SUM(views), 0), COUNT(*) FROM post_views").Scan(&views, &rows); err != nil {
+		t.Fatal(err)
+	}
+	if views != 3 || rows != 2 {
+		t.Errorf("%d views in %d rows written, want 3 in 2", views, rows)
+	}
+	Stop() // Stopping again does nothing.
+}
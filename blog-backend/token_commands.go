@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gg582/chi-blog/blog-backend/auth"
+	"github.com/gg582/chi-blog/blog-backend/database"
+)
+
+// newTokenCommand builds the "token" command tree for personal API tokens.
+func newTokenCommand() *cobra.Command {
+	var token = &cobra.Command{
+		Use:   "token",
+		Short: "Manage personal API tokens for scripted publishing",
+		Long: `Manage personal API tokens. Tokens are sent as "Authorization: Bearer <token>"
+and are limited to the scopes they were created with: ` + strings.Join(auth.Scopes, ", ") + `.`,
+	}
+
+	var username, name string
+	var scopes []string
+	var ttl time.Duration
+	var create = &cobra.Command{
+		Use:   "create",
+		Short: "Create a new API token",
+		Run: func(cmd *cobra.Command, args []string) {
+			database.InitDatabase()
+			mustUserExist(username)
+			plain, t, err := auth.CreateToken(username, name, scopes, ttl)
+			if err != nil {
+				log.Fatalf("Failed to create token: %v", err)
+			}
+			log.Printf("Token %d (%s) created for %s with scopes: %s", t.ID, t.Name, t.Username, strings.Join(t.Scopes, ", "))
+			if t.ExpiresAt != nil {
+				log.Printf("It expires on %s.", t.ExpiresAt.Format("2006-01-02 15:04"))
+			}
+			fmt.Println("Copy the token now, it will not be shown again:")
+			fmt.Println(plain)
+		},
+	}
+	create.Flags().StringVarP(&username, "user", "u", "", "admin the token acts as")
+	create.Flags().StringVarP(&name, "name", "n", "", "label to recognise the token by, e.g. \"ci\"")
+	create.Flags().StringSliceVarP(&scopes, "scope", "s", nil, "scope to grant; repeat or comma-separate ("+strings.Join(auth.Scopes, ", ")+")")
+	create.Flags().DurationVar(&ttl, "expires-in", 0, "lifetime of the token, e.g. 2160h for 90 days; 0 never expires")
+	create.MarkFlagRequired("user")
+	create.MarkFlagRequired("name")
+	create.MarkFlagRequired("scope")
+
+	var list = &cobra.Command{
+		Use:   "list",
+		Short: "List API tokens",
+		Run: func(cmd *cobra.Command, args []string) {
+			database.InitDatabase()
+			tokens, err := auth.ListTokens()
+			if err != nil {
+				log.Fatalf("Failed to list tokens: %v", err)
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tNAME\tUSER\tSCOPES\tCREATED\tLAST USED\tSTATUS")
+			for _, t := range tokens {
+				lastUsed, status := "never", "active"
+				if t.LastUsedAt != nil {
+					lastUsed = t.LastUsedAt.Format("2006-01-02 15:04")
+				}
+				switch {
+				case t.RevokedAt != nil:
+					status = "revoked " + t.RevokedAt.Format("2006-01-02")
+				case t.Expired():
+					status = "expired " + t.ExpiresAt.Format("2006-01-02")
+				case t.ExpiresAt != nil:
+					status = "active until " + t.ExpiresAt.Format("2006-01-02")
+				}
+				fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", t.ID, t.Name, t.Username,
+					strings.Join(t.Scopes, ","), t.CreatedAt.Format("2006-01-02 15:04"), lastUsed, status)
+			}
+			tw.Flush()
+		},
+	}
+
+	var revoke = &cobra.Command{
+		Use:   "revoke <id>",
+		Short: "Revoke an API token",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				log.Fatalf("Invalid token id %q", args[0])
+			}
+			database.InitDatabase()
+			ok, err := auth.RevokeToken(id)
+			if err != nil {
+				log.Fatalf("Failed to revoke token: %v", err)
+			}
+			if !ok {
+				log.Printf("No active token with id %d.", id)
+				os.Exit(1)
+			}
+			log.Printf("Token %d revoked.", id)
+		},
+	}
+
+	token.AddCommand(create, list, revoke)
+	return token
+}
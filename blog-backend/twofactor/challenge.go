@@ -0,0 +1,70 @@
+package twofactor
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// A challenge is handed out after the password step succeeds for a user with
+// two-factor enabled. The client sends it back together with a code.
+const (
+	challengeTTL         = 5 * time.Minute
+	maxChallengeAttempts = 5
+)
+
+type challenge struct {
+	username string
+	expires  time.Time
+	attempts int
+}
+
+var (
+	challengesMu sync.Mutex
+	challenges   = map[string]*challenge{}
+)
+
+// NewChallenge creates a pending second login step for username.
+func NewChallenge(username string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	challengesMu.Lock()
+	defer challengesMu.Unlock()
+	now := Now()
+	for t, c := range challenges {
+		if now.After(c.expires) {
+			delete(challenges, t)
+		}
+	}
+	challenges[token] = &challenge{username: username, expires: now.Add(challengeTTL)}
+	return token, nil
+}
+
+// ChallengeUser returns the user a live challenge belongs to and counts an
+// attempt against it. Expired or exhausted challenges are discarded.
+func ChallengeUser(token string) (string, bool) {
+	challengesMu.Lock()
+	defer challengesMu.Unlock()
+	c, ok := challenges[token]
+	if !ok {
+		return "", false
+	}
+	c.attempts++
+	if Now().After(c.expires) || c.attempts > maxChallengeAttempts {
+		delete(challenges, token)
+		return "", false
+	}
+	return c.username, true
+}
+
+// CompleteChallenge removes a challenge once it has been answered.
+func CompleteChallenge(token string) {
+	challengesMu.Lock()
+	defer challengesMu.Unlock()
+	delete(challenges, token)
+}
@@ -0,0 +1,134 @@
+package twofactor
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+)
+
+// Now is the clock used for code validation. Tests may replace it.
+var Now = time.Now
+
+const (
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+	// recoveryAlphabet leaves out characters that are easy to misread.
+	recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// Enabled reports whether username has two-factor authentication turned on.
+func Enabled(username string) (bool, error) {
+	var n int
+	err := database.DB.QueryRow("SELECT COUNT(*) FROM user_totp WHERE username = ?", username).Scan(&n)
+	return n > 0, err
+}
+
+// Enable stores secret for username, replacing any previous enrollment, and
+// returns a fresh set of recovery codes. Only the hashes of the codes are kept.
+func Enable(username, secret string) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO user_totp (username, secret, last_step) VALUES (?,?,0)
+		ON CONFLICT(username) DO UPDATE SET secret = excluded.secret, last_step = 0`, username, secret); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE username = ?", username); err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		if _, err := tx.Exec("INSERT INTO recovery_codes (username, code_hash) VALUES (?,?)", username, hashRecoveryCode(code)); err != nil {
+			return nil, err
+		}
+	}
+	return codes, tx.Commit()
+}
+
+// Disable removes the enrollment and recovery codes of username.
+func Disable(username string) error {
+	if _, err := database.DB.Exec("DELETE FROM user_totp WHERE username = ?", username); err != nil {
+		return err
+	}
+	_, err := database.DB.Exec("DELETE FROM recovery_codes WHERE username = ?", username)
+	return err
+}
+
+// Verify checks a TOTP code for username. A code is accepted only once: its
+// time step must be newer than the last one used.
+func Verify(username, code string) (bool, error) {
+	var secret string
+	var lastStep int64
+	err := database.DB.QueryRow("SELECT secret, last_step FROM user_totp WHERE username = ?", username).Scan(&secret, &lastStep)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	s, ok := Validate(secret, code, Now())
+	if !ok || s <= lastStep {
+		return false, nil
+	}
+	res, err := database.DB.Exec("UPDATE user_totp SET last_step = ? WHERE username = ? AND last_step < ?", s, username, s)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// UseRecoveryCode consumes a recovery code of username. Each code works once.
+func UseRecoveryCode(username, code string) (bool, error) {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	res, err := database.DB.Exec("DELETE FROM recovery_codes WHERE username = ? AND code_hash = ?", username, hashRecoveryCode(code))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// Recovery codes carry ~49 bits of randomness, so a plain SHA-256 is enough
+// and lets us look them up directly.
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func newRecoveryCode() (string, error) {
+	// rand.Int draws uniformly; reducing a random byte modulo the alphabet
+	// size would favour its first eight characters.
+	buf := make([]byte, recoveryCodeLength)
+	n := big.NewInt(int64(len(recoveryAlphabet)))
+	for i := range buf {
+		c, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = recoveryAlphabet[c.Int64()]
+	}
+	return string(buf), nil
+}
+
+// FormatRecoveryCode splits a code in two halves for display.
+func FormatRecoveryCode(code string) string {
+	return code[:len(code)/2] + "-" + code[len(code)/2:]
+}
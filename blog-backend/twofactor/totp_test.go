@@ -0,0 +1,216 @@
+package twofactor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors,
+// "12345678901234567890", base32 encoded.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// setClock fixes Now at t for the rest of the test.
+func setClock(t *testing.T, at time.Time) {
+	t.Helper()
+	Now = func() time.Time { return at }
+	t.Cleanup(func() { Now = time.Now })
+}
+
+// setupDB points the database at a fresh file for the rest of the test.
+func setupDB(t *testing.T) {
+	t.Helper()
+	database.Path = filepath.Join(t.TempDir(), "auth.db")
+	database.InitDatabase()
+	t.Cleanup(func() { database.DB.Close() })
+}
+
+func TestCodeRFC6238(t *testing.T) {
+	// Appendix B of RFC 6238 lists 8-digit codes; ours are their last six.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("Code(%d): %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("Code(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateWindow(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := step(now)
+	tests := []struct {
+		offset int64
+		ok     bool
+	}{
+		{-2, false},
+		{-1, true},
+		{0, true},
+		{1, true},
+		{2, false},
+	}
+	for _, tt := range tests {
+		code, err := Code(rfcSecret, now.Add(time.Duration(tt.offset)*period))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, ok := Validate(rfcSecret, code, now)
+		if ok != tt.ok {
+			t.Errorf("step %+d: ok = %v, want %v", tt.offset, ok, tt.ok)
+		}
+		if ok && s != current+tt.offset {
+			t.Errorf("step %+d: matched step %d, want %d", tt.offset, s, current+tt.offset)
+		}
+	}
+
+	if _, ok := Validate(rfcSecret, "12345", now); ok {
+		t.Error("short code accepted")
+	}
+	if _, ok := Validate("not base32!", "123456", now); ok {
+		t.Error("code accepted for an invalid secret")
+	}
+}
+
+func TestVerifyRejectsReplay(t *testing.T) {
+	setupDB(t)
+	now := time.Unix(1111111111, 0)
+	setClock(t, now)
+	if _, err := Enable("alice", rfcSecret); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ := Code(rfcSecret, now)
+	if ok, err := Verify("alice", code); err != nil || !ok {
+		t.Fatalf("first use: ok = %v, err = %v", ok, err)
+	}
+	if ok, _ := Verify("alice", code); ok {
+		t.Error("the same code was accepted twice")
+	}
+	previous, _ := Code(rfcSecret, now.Add(-period))
+	if ok, _ := Verify("alice", previous); ok {
+		t.Error("a code older than the last used one was accepted")
+	}
+
+	setClock(t, now.Add(period))
+	next, _ := Code(rfcSecret, now.Add(period))
+	if ok, err := Verify("alice", next); err != nil || !ok {
+		t.Errorf("code of the next step: ok = %v, err = %v", ok, err)
+	}
+	if ok, _ := Verify("bob", next); ok {
+		t.Error("code accepted for a user without two-factor")
+	}
+}
+
+func TestChallengeExpiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	setClock(t, now)
+	token, err := NewChallenge("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, ok := ChallengeUser(token); !ok || user != "alice" {
+		t.Fatalf("ChallengeUser = %q, %v; want alice, true", user, ok)
+	}
+
+	setClock(t, now.Add(challengeTTL+time.Second))
+	if _, ok := ChallengeUser(token); ok {
+		t.Error("expired challenge accepted")
+	}
+	setClock(t, now)
+	if _, ok := ChallengeUser(token); ok {
+		t.Error("expired challenge was not discarded")
+	}
+}
+
+func TestChallengeAttemptCap(t *testing.T) {
+	setClock(t, time.Unix(1700000000, 0))
+	token, err := NewChallenge("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= maxChallengeAttempts; i++ {
+		if _, ok := ChallengeUser(token); !ok {
+			t.Fatalf("attempt %d rejected", i)
+		}
+	}
+	if _, ok := ChallengeUser(token); ok {
+		t.Errorf("attempt %d accepted", maxChallengeAttempts+1)
+	}
+
+	other, _ := NewChallenge("alice")
+	CompleteChallenge(other)
+	if _, ok := ChallengeUser(other); ok {
+		t.Error("completed challenge accepted")
+	}
+}
+
+func TestRecoveryCodeSingleUse(t *testing.T) {
+	setupDB(t)
+	codes, err := Enable("alice", rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	// Codes are accepted as displayed, with the dash and in any case.
+	if ok, err := UseRecoveryCode("alice", FormatRecoveryCode(codes[0])); err != nil || !ok {
+		t.Fatalf("first use: ok = %v, err = %v", ok, err)
+	}
+	if ok, _ := UseRecoveryCode("alice", codes[0]); ok {
+		t.Error("recovery code accepted twice")
+	}
+	if ok, _ := UseRecoveryCode("bob", codes[1]); ok {
+		t.Error("recovery code accepted for another user")
+	}
+	if ok, _ := UseRecoveryCode("alice", strings.ToUpper(codes[1])); !ok {
+		t.Error("upper-cased recovery code rejected")
+	}
+
+	// Enabling again replaces the old codes.
+	if _, err := Enable("alice", rfcSecret); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := UseRecoveryCode("alice", codes[2]); ok {
+		t.Error("recovery code survived re-enrollment")
+	}
+}
+
+func TestNewRecoveryCode(t *testing.T) {
+	counts := map[rune]int{}
+	for i := 0; i < 200; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != recoveryCodeLength {
+			t.Fatalf("code %q has %d characters, want %d", code, len(code), recoveryCodeLength)
+		}
+		for _, c := range code {
+			if !strings.ContainsRune(recoveryAlphabet, c) {
+				t.Fatalf("code %q uses %q, which is not in the alphabet", code, c)
+			}
+			counts[c]++
+		}
+	}
+	if len(counts) != len(recoveryAlphabet) {
+		t.Errorf("only %d of %d characters used", len(counts), len(recoveryAlphabet))
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mdp/qrterminal/v3"
+	"github.com/spf13/cobra"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+	"github.com/gg582/chi-blog/blog-backend/twofactor"
+)
+
+const totpIssuer = "chi-blog"
+
+// newUserCommand builds the "user" command tree for managing admin accounts.
+func newUserCommand() *cobra.Command {
+	var user = &cobra.Command{
+		Use:   "user",
+		Short: "Manage blog admin accounts",
+	}
+
+	var twoFA = &cobra.Command{
+		Use:   "2fa",
+		Short: "Manage two-factor (TOTP) login",
+	}
+
+	var enable = &cobra.Command{
+		Use:   "enable <username>",
+		Short: "Enroll a user in TOTP two-factor login",
+		Long: `Generate a TOTP secret, print it as an otpauth URI and a QR code for your
+authenticator app, and after a code is confirmed print one-time recovery codes.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			username := args[0]
+			database.InitDatabase()
+			mustUserExist(username)
+
+			secret, err := twofactor.NewSecret()
+			if err != nil {
+				log.Fatalf("Failed to generate TOTP secret: %v", err)
+			}
+			uri := twofactor.URI(totpIssuer, username, secret)
+			fmt.Println("Scan this QR code with your authenticator app:")
+			qrterminal.GenerateHalfBlock(uri, qrterminal.L, os.Stdout)
+			fmt.Printf("Or add it manually: %s\n\n", uri)
+
+			log.Println("Please enter the 6-digit code shown by your app to confirm.")
+			var code string
+			fmt.Scanln(&code)
+			if _, ok := twofactor.Validate(secret, code, time.Now()); !ok {
+				log.Println("Code did not match. Two-factor login was not enabled.")
+				os.Exit(1)
+			}
+
+			codes, err := twofactor.Enable(username, secret)
+			if err != nil {
+				log.Fatalf("Failed to store two-factor settings: %v", err)
+			}
+			log.Printf("Two-factor login enabled for %s.", username)
+			fmt.Println("Recovery codes (each works once, store them somewhere safe):")
+			for _, c := range codes {
+				fmt.Println("  " + twofactor.FormatRecoveryCode(c))
+			}
+		},
+	}
+
+	var disable = &cobra.Command{
+		Use:   "disable <username>",
+		Short: "Turn off two-factor login and delete recovery codes",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			username := args[0]
+			log.Printf("WARNING: two-factor login for %s will be removed. type 'yes' to continue", username)
+			var r string
+			fmt.Scanln(&r)
+			if strings.ToLower(r) != "yes" {
+				log.Println("Quitting without changes...")
+				os.Exit(1)
+			}
+			database.InitDatabase()
+			mustUserExist(username)
+			if err := twofactor.Disable(username); err != nil {
+				log.Fatalf("Failed to disable two-factor login: %v", err)
+			}
+			log.Printf("Two-factor login disabled for %s.", username)
+		},
+	}
+
+	twoFA.AddCommand(enable, disable)
+	user.AddCommand(twoFA)
+	return user
+}
+
+// mustUserExist exits when username is not a registered admin.
+func mustUserExist(username string) {
+	var id int
+	err := database.DB.QueryRow("SELECT id FROM blog_users WHERE username = ?", username).Scan(&id)
+	if err == sql.ErrNoRows {
+		log.Fatalf("User %s does not exist.", username)
+	} else if err != nil {
+		log.Fatalf("Failed to query users: %v", err)
+	}
+}
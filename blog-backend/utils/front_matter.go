@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// frontMatterRegex matches the front matter block at the start of a file:
+//
+//	---
+//	author: Lee Yunjin
+//	trusted: true
+//	---
+//
+// The closing "---" must be on a line of its own, so that values may
+// contain dashes.
+var frontMatterRegex = regexp.MustCompile(`(?s)^---[ \t]*\r?\n(?:(.*?)\r?\n)?---[ \t]*(?:\r?\n|$)[\r\n]*`)
+
+// inlineFrontMatterRegex matches the single-line "--- author: name ---"
+// form, which holds a single key.
+var inlineFrontMatterRegex = regexp.MustCompile(`^---[ \t]+(\S.*?)[ \t]+---[ \t]*(?:\r?\n|$)[\r\n]*`)
+
+// FrontMatter holds the "key: value" pairs of a post's front matter.
+// Keys are lower-cased.
+type FrontMatter map[string]string
+
+// ParseFrontMatter splits content into its front matter and the remaining
+// markdown. Content without front matter yields an empty FrontMatter.
+func ParseFrontMatter(content []byte) (FrontMatter, []byte) {
+	meta := FrontMatter{}
+	loc := frontMatterRegex.FindSubmatchIndex(content)
+	if loc == nil {
+		loc = inlineFrontMatterRegex.FindSubmatchIndex(content)
+	}
+	if loc == nil {
+		return meta, content
+	}
+	if loc[2] < 0 {
+		return meta, content[loc[1]:] // An empty block.
+	}
+	for _, line := range strings.Split(string(content[loc[2]:loc[3]]), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" {
+			continue
+		}
+		meta[key] = unquote(strings.TrimSpace(value))
+	}
+	return meta, content[loc[1]:]
+}
+
+// Bool reports whether key is set to a true value ("true", "yes", "1").
+func (fm FrontMatter) Bool(key string) bool {
+	switch strings.ToLower(fm[key]) {
+	case "true", "yes", "1":
+		return true
+	}
+	return false
+}
+
+// Int returns key as an integer, or def when it is missing or malformed.
+func (fm FrontMatter) Int(key string, def int) int {
+	n, err := strconv.Atoi(fm[key])
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// timeLayouts are the date formats accepted by Time. Dates without a time
+// zone are read as UTC.
+var timeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// Time returns key as a time and whether it is set to a valid one.
+func (fm FrontMatter) Time(key string) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, fm[key]); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// List returns key as a list. Both "[a, b]" and "a, b" are accepted.
+func (fm FrontMatter) List(key string) []string {
+	value := strings.TrimSpace(fm[key])
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = unquote(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// SetList stores items as a "[a, b]" list under key, or removes key when
+// items is empty. Commas and brackets are dropped from the items, as List
+// could not read them back.
+func (fm FrontMatter) SetList(key string, items []string) {
+	var clean []string
+	for _, item := range items {
+		item = strings.Join(strings.Fields(strings.NewReplacer(",", " ", "[", "", "]", "").Replace(item)), " ")
+		if item != "" {
+			clean = append(clean, item)
+		}
+	}
+	if len(clean) == 0 {
+		delete(fm, key)
+		return
+	}
+	fm[key] = "[" + strings.Join(clean, ", ") + "]"
+}
+
+// frontMatterOrder lists the keys Encode writes first, in this order.
+var frontMatterOrder = []string{"author", "date", "draft", "trusted", "summary", "tags", "series", "series_order"}
+
+// Encode formats fm as a front matter block, including the "---" lines.
+// Line breaks in values are replaced by spaces, so a value can never add
+// keys of its own.
+func (fm FrontMatter) Encode() string {
+	keys := make([]string, 0, len(fm))
+	for key := range fm {
+		keys = append(keys, key)
+	}
+	rank := func(key string) int {
+		for i, k := range frontMatterOrder {
+			if k == key {
+				return i
+			}
+		}
+		return len(frontMatterOrder)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if ri, rj := rank(keys[i]), rank(keys[j]); ri != rj {
+			return ri < rj
+		}
+		return keys[i] < keys[j]
+	})
+
+	var b strings.Builder
+	b.WriteString("---\n")
+	for _, key := range keys {
+		b.WriteString(key + ": " + strings.Join(strings.Fields(fm[key]), " ") + "\n")
+	}
+	b.WriteString("---\n")
+	return b.String()
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' && s[len(s)-1] == '"' || s[0] == '\'' && s[len(s)-1] == '\'') {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
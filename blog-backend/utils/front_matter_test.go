@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   FrontMatter
+		body   string
+	}{
+		{"block", "---\nauthor: Lee\nTrusted: true\n---\n\n# Title\n", FrontMatter{"author": "Lee", "trusted": "true"}, "# Title\n"},
+		{"crlf", "---\r\nauthor: Lee\r\n---\r\n# Title", FrontMatter{"author": "Lee"}, "# Title"},
+		{"empty block", "---\n---\ntext", FrontMatter{}, "text"},
+		{"single line", "--- author: Lee ---\ntext", FrontMatter{"author": "Lee"}, "text"},
+		{"dashes in a value", "---\nsummary: before --- after\ndraft: true\n---\ntext", FrontMatter{"summary": "before --- after", "draft": "true"}, "text"},
+		{"closing line with spaces", "---\nauthor: Lee\n---  \ntext", FrontMatter{"author": "Lee"}, "text"},
+		{"no front matter", "# Title\n\n---\n", FrontMatter{}, "# Title\n\n---\n"},
+		{"unclosed", "---\nauthor: Lee\n", FrontMatter{}, "---\nauthor: Lee\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, body := ParseFrontMatter([]byte(tt.source))
+			if !reflect.DeepEqual(meta, tt.want) {
+				t.Errorf("front matter = %v, want %v", meta, tt.want)
+			}
+			if string(body) != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestFrontMatterRoundTrip(t *testing.T) {
+	meta := FrontMatter{
+		"author":       "Lee --- Yunjin",
+		"date":         "2024-05-01T10:00:00Z",
+		"draft":        "true",
+		"summary":      "--- starts and ends with dashes ---",
+		"tags":         "[go, flink]",
+		"series":       "Part one --- the basics",
+		"series_order": "2",
+		"custom":       "---",
+	}
+	got, body := ParseFrontMatter([]byte(meta.Encode() + "\n# Title\n"))
+	if !reflect.DeepEqual(got, meta) {
+		t.Errorf("ParseFrontMatter(Encode(m)) = %v, want %v", got, meta)
+	}
+	if string(body) != "# Title\n" {
+		t.Errorf("body = %q, want %q", body, "# Title\n")
+	}
+
+	// Line breaks are folded, so a value cannot close the block or add keys.
+	meta = FrontMatter{"summary": "one\n---\ndraft: false"}
+	got, _ = ParseFrontMatter([]byte(meta.Encode()))
+	if want := (FrontMatter{"summary": "one --- draft: false"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("folded value parsed as %v, want %v", got, want)
+	}
+}
@@ -9,6 +9,9 @@ import (
 // It helps in removing unwanted characters for slug creation.
 var slugRegex = regexp.MustCompile(`[^\p{L}\p{N}\s-]+`) // \p{L} for Unicode letters, \p{N} for Unicode numbers
 
+// UntitledSlug is returned by GenerateSlug when the title has no usable characters.
+const UntitledSlug = "untitled-post"
+
 // GenerateSlug creates a URL-friendly slug from a given title.
 // It handles non-ASCII characters by preserving them and replaces spaces/special chars with hyphens,
 // similar to Jekyll's behavior for international characters.
@@ -30,7 +33,7 @@ func GenerateSlug(title string) string {
 	if slug == "" {
 		// Fallback for titles that result in empty slugs (e.g., "!!!")
 		// You might want to generate a random string or a default slug here.
-		return "untitled-post"
+		return UntitledSlug
 	}
 
 	return slug
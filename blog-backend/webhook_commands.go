@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+	"github.com/gg582/chi-blog/blog-backend/events"
+	"github.com/gg582/chi-blog/blog-backend/webhooks"
+)
+
+// newWebhookCommand builds the "webhook" command tree.
+func newWebhookCommand() *cobra.Command {
+	var webhookCmd = &cobra.Command{
+		Use:   "webhook",
+		Short: "Manage outgoing webhooks",
+	}
+
+	var eventList string
+	var register = &cobra.Command{
+		Use:   "register <url>",
+		Short: "Register a webhook endpoint and print its signing secret",
+		Long: `Register a URL to receive a signed JSON payload when posts change.
+Each payload carries an ` + webhooks.HeaderSignature + ` header: "sha256=" followed by the
+hex HMAC-SHA256 of the body, keyed with the secret printed here. The secret is
+not shown again.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			database.InitDatabase()
+			var types []string
+			if eventList != "" {
+				types = strings.Split(eventList, ",")
+			}
+			h, err := webhooks.Register(args[0], types)
+			if err != nil {
+				log.Fatalf("Failed to register webhook: %v", err)
+			}
+			fmt.Printf("Registered webhook %d for %s.\n", h.ID, h.URL)
+			fmt.Printf("Secret: %s\n", h.Secret)
+		},
+	}
+	register.Flags().StringVar(&eventList, "events", "", "comma-separated events to send ("+strings.Join(events.Types, ", ")+"), all by default")
+
+	var list = &cobra.Command{
+		Use:   "list",
+		Short: "List registered webhooks",
+		Run: func(cmd *cobra.Command, args []string) {
+			database.InitDatabase()
+			hooks, err := webhooks.List()
+			if err != nil {
+				log.Fatalf("Failed to list webhooks: %v", err)
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tURL\tEVENTS\tCREATED")
+			for _, h := range hooks {
+				eventList := "all"
+				if len(h.Events) > 0 {
+					eventList = strings.Join(h.Events, ",")
+				}
+				fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", h.ID, h.URL, eventList, h.CreatedAt.Format("2006-01-02 15:04"))
+			}
+			tw.Flush()
+		},
+	}
+
+	var test = &cobra.Command{
+		Use:   "test <id>",
+		Short: "Send a ping to a webhook and show the response status",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			database.InitDatabase()
+			h, err := webhooks.Get(parseWebhookID(args[0]))
+			if err != nil {
+				log.Fatalf("Failed to load webhook: %v", err)
+			}
+			status, err := webhooks.Test(*h)
+			if err != nil {
+				log.Fatalf("Ping to %s failed: %v", h.URL, err)
+			}
+			fmt.Printf("Ping to %s answered %d.\n", h.URL, status)
+		},
+	}
+
+	var remove = &cobra.Command{
+		Use:   "remove <id>",
+		Short: "Remove a webhook and its delivery log",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			database.InitDatabase()
+			id := parseWebhookID(args[0])
+			if err := webhooks.Remove(id); err != nil {
+				log.Fatalf("Failed to remove webhook: %v", err)
+			}
+			fmt.Printf("Removed webhook %d.\n", id)
+		},
+	}
+
+	var webhookID int64
+	var limit int
+	var deliveries = &cobra.Command{
+		Use:   "deliveries",
+		Short: "Show the delivery log, newest first",
+		Run: func(cmd *cobra.Command, args []string) {
+			database.InitDatabase()
+			attempts, err := webhooks.Deliveries(webhookID, limit)
+			if err != nil {
+				log.Fatalf("Failed to read delivery log: %v", err)
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "TIME\tWEBHOOK\tEVENT\tDELIVERY\tATTEMPT\tSTATUS\tDURATION\tERROR")
+			for _, a := range attempts {
+				status := "-"
+				if a.StatusCode != 0 {
+					status = strconv.Itoa(a.StatusCode)
+				}
+				fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%d\t%s\t%s\t%s\n",
+					a.CreatedAt.Format("2006-01-02 15:04:05"), a.WebhookID, a.Event, a.DeliveryID, a.Attempt, status, a.Duration, a.Error)
+			}
+			tw.Flush()
+		},
+	}
+	deliveries.Flags().Int64Var(&webhookID, "webhook", 0, "only deliveries to this webhook")
+	deliveries.Flags().IntVar(&limit, "limit", 50, "maximum number of entries")
+
+	webhookCmd.AddCommand(register, list, test, remove, deliveries)
+	return webhookCmd
+}
+
+func parseWebhookID(s string) int64 {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		log.Fatalf("Invalid webhook id %q", s)
+	}
+	return id
+}
@@ -0,0 +1,202 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/events"
+	"github.com/gg582/chi-blog/blog-backend/site"
+)
+
+// EventPing is sent by Test to check that an endpoint is reachable.
+const EventPing = "ping"
+
+// Headers sent with every payload. The signature is "sha256=" followed by
+// the hex HMAC-SHA256 of the body, keyed with the webhook's secret.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// MaxAttempts is how often a delivery is tried before it is given up.
+// Retries wait RetryDelay, doubling after every attempt.
+const MaxAttempts = 5
+
+// RetryDelay is the wait before the first retry. Tests may shorten it.
+var RetryDelay = 30 * time.Second
+
+// Client sends the payloads. Webhook URLs are set by the admin, so unlike
+// webmentions they may point into the local network.
+var Client = &http.Client{Timeout: 10 * time.Second}
+
+const userAgent = "chi-blog-webhooks (+https://github.com/gg582/chi-blog)"
+
+// Job is a payload waiting to be delivered to a webhook.
+type Job struct {
+	Webhook    Webhook
+	DeliveryID string
+	Event      string
+	Payload    []byte
+	Attempt    int // 1 for the first try
+}
+
+// JobQueue feeds the delivery workers, which run Process. It is nil until
+// the server starts them.
+var JobQueue chan Job
+
+// enqueue submits a job without waiting. It reports false if the queue is
+// full or not running.
+func enqueue(job Job) bool {
+	select {
+	case JobQueue <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// Dispatch queues e for every webhook that wants it. It is subscribed to
+// the event bus when the server starts.
+func Dispatch(e events.Event) {
+	hooks, err := List()
+	if err != nil {
+		log.Printf("Webhooks: listing webhooks for %s %s: %v", e.Type, e.PostID, err)
+		return
+	}
+	for _, h := range hooks {
+		if !h.Wants(e.Type) {
+			continue
+		}
+		job, err := newJob(h, e.Type, map[string]any{
+			"postId": e.PostID,
+			"url":    site.PostURL(e.PostID),
+			"post":   e.Post,
+		}, e.Time)
+		if err != nil {
+			log.Printf("Webhooks: encoding %s for webhook %d: %v", e.Type, h.ID, err)
+			continue
+		}
+		if !enqueue(job) {
+			log.Printf("Webhook queue is full. Not delivering %s %s to webhook %d.", e.Type, e.PostID, h.ID)
+		}
+	}
+}
+
+// Test sends a ping to h right away, without retries, and returns the
+// status code of the response.
+func Test(h Webhook) (int, error) {
+	job, err := newJob(h, EventPing, map[string]any{"url": site.URL}, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return deliver(job)
+}
+
+// newJob builds the payload for an event of type typ carrying data.
+func newJob(h Webhook, typ string, data map[string]any, at time.Time) (Job, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return Job{}, err
+	}
+	id := hex.EncodeToString(b)
+	payload := map[string]any{
+		"id":        id,
+		"event":     typ,
+		"createdAt": at.UTC(),
+	}
+	for k, v := range data {
+		payload[k] = v
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, err
+	}
+	return Job{Webhook: h, DeliveryID: id, Event: typ, Payload: body, Attempt: 1}, nil
+}
+
+// Process delivers job and schedules a retry if it failed in a way that
+// may pass.
+func Process(id int, job Job) {
+	status, err := deliver(job)
+	if err == nil {
+		log.Printf("Webhook worker %d: delivered %s to webhook %d", id, job.Event, job.Webhook.ID)
+		return
+	}
+	retry := status == 0 || status == http.StatusTooManyRequests || status >= 500
+	if !retry || job.Attempt >= MaxAttempts {
+		log.Printf("Webhook worker %d: giving up on %s to webhook %d after %d attempts: %v", id, job.Event, job.Webhook.ID, job.Attempt, err)
+		return
+	}
+	delay := RetryDelay << (job.Attempt - 1)
+	log.Printf("Webhook worker %d: %s to webhook %d failed, retrying in %s: %v", id, job.Event, job.Webhook.ID, delay, err)
+	job.Attempt++
+	time.AfterFunc(delay, func() {
+		if !enqueue(job) {
+			log.Printf("Webhook queue is full. Dropping retry of %s to webhook %d.", job.Event, job.Webhook.ID)
+		}
+	})
+}
+
+// deliver posts the signed payload of job once and records the attempt in
+// the delivery log. It returns the status code of the response, or 0 when
+// there was none.
+func deliver(job Job) (int, error) {
+	start := time.Now()
+	status, err := post(job)
+	a := Attempt{
+		WebhookID:  job.Webhook.ID,
+		DeliveryID: job.DeliveryID,
+		Event:      job.Event,
+		Attempt:    job.Attempt,
+		StatusCode: status,
+		Duration:   time.Since(start),
+		CreatedAt:  start,
+	}
+	if err != nil {
+		a.Error = err.Error()
+	}
+	if err := logAttempt(a); err != nil {
+		log.Printf("Failed to log webhook delivery %s: %v", job.DeliveryID, err)
+	}
+	return status, err
+}
+
+func post(job Job) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, job.Webhook.URL, bytes.NewReader(job.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(HeaderEvent, job.Event)
+	req.Header.Set(HeaderDelivery, job.DeliveryID)
+	req.Header.Set(HeaderSignature, Sign(job.Webhook.Secret, job.Payload))
+	resp, err := Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header value for body under secret.
+// Receivers compute the same and compare in constant time.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,190 @@
+// Package webhooks posts HMAC-signed JSON payloads to registered endpoints
+// when posts are published, updated or deleted, for site rebuilds, chat
+// notifications and cross-posting.
+package webhooks
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+	"github.com/gg582/chi-blog/blog-backend/events"
+)
+
+var (
+	// ErrNotFound is returned for an unknown webhook id.
+	ErrNotFound = errors.New("webhook not found")
+	// ErrInvalidURL is returned when registering a URL that is not http(s).
+	ErrInvalidURL = errors.New("webhook URL must be an absolute http or https URL")
+)
+
+// Webhook is a registered endpoint.
+type Webhook struct {
+	ID  int64
+	URL string
+	// Secret is the HMAC key payloads are signed with. It is shown once,
+	// when the webhook is registered.
+	Secret string
+	// Events the webhook receives; empty means all of them.
+	Events    []string
+	CreatedAt time.Time
+}
+
+// Wants reports whether the webhook receives events of type typ.
+func (h Webhook) Wants(typ string) bool {
+	return len(h.Events) == 0 || slices.Contains(h.Events, typ)
+}
+
+// Register adds a webhook for rawURL receiving eventTypes, or every event
+// when none are given, with a new random secret.
+func Register(rawURL string, eventTypes []string) (*Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+	for _, typ := range eventTypes {
+		if !slices.Contains(events.Types, typ) {
+			return nil, fmt.Errorf("unknown event %q, expected one of %s", typ, strings.Join(events.Types, ", "))
+		}
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	h := &Webhook{URL: u.String(), Secret: hex.EncodeToString(secret), Events: eventTypes, CreatedAt: time.Now()}
+	res, err := database.DB.Exec(
+		"INSERT INTO webhooks (url, secret, events, created_at) VALUES (?,?,?,?)",
+		h.URL, h.Secret, strings.Join(h.Events, ","), h.CreatedAt.Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if h.ID, err = res.LastInsertId(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// List returns every registered webhook.
+func List() ([]Webhook, error) {
+	rows, err := database.DB.Query("SELECT id, url, secret, events, created_at FROM webhooks ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []Webhook{}
+	for rows.Next() {
+		h, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, *h)
+	}
+	return hooks, rows.Err()
+}
+
+// Get returns the webhook with id.
+func Get(id int64) (*Webhook, error) {
+	h, err := scanWebhook(database.DB.QueryRow("SELECT id, url, secret, events, created_at FROM webhooks WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return h, err
+}
+
+// Remove deletes a webhook and its delivery log.
+func Remove(id int64) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec("DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func scanWebhook(row interface{ Scan(...any) error }) (*Webhook, error) {
+	var h Webhook
+	var eventList string
+	var created int64
+	if err := row.Scan(&h.ID, &h.URL, &h.Secret, &eventList, &created); err != nil {
+		return nil, err
+	}
+	if eventList != "" {
+		h.Events = strings.Split(eventList, ",")
+	}
+	h.CreatedAt = time.Unix(created, 0)
+	return &h, nil
+}
+
+// Attempt is an entry of the delivery log: one try at delivering an event.
+type Attempt struct {
+	ID         int64
+	WebhookID  int64
+	DeliveryID string // the same for every retry of a delivery
+	Event      string
+	Attempt    int
+	StatusCode int // 0 when no response was received
+	Error      string
+	Duration   time.Duration
+	CreatedAt  time.Time
+}
+
+// logAttempt adds a to the delivery log.
+func logAttempt(a Attempt) error {
+	_, err := database.DB.Exec(
+		`INSERT INTO webhook_deliveries (webhook_id, delivery_id, event, attempt, status_code, error, duration_ms, created_at)
+		VALUES (?,?,?,?,?,?,?,?)`,
+		a.WebhookID, a.DeliveryID, a.Event, a.Attempt, a.StatusCode, a.Error, a.Duration.Milliseconds(), a.CreatedAt.Unix(),
+	)
+	return err
+}
+
+// Deliveries returns the latest limit entries of the delivery log, newest
+// first. A non-zero webhookID limits it to one webhook.
+func Deliveries(webhookID int64, limit int) ([]Attempt, error) {
+	query := "SELECT id, webhook_id, delivery_id, event, attempt, status_code, error, duration_ms, created_at FROM webhook_deliveries"
+	var args []any
+	if webhookID != 0 {
+		query += " WHERE webhook_id = ?"
+		args = append(args, webhookID)
+	}
+	rows, err := database.DB.Query(query+" ORDER BY id DESC LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []Attempt{}
+	for rows.Next() {
+		var a Attempt
+		var ms, created int64
+		if err := rows.Scan(&a.ID, &a.WebhookID, &a.DeliveryID, &a.Event, &a.Attempt, &a.StatusCode, &a.Error, &ms, &created); err != nil {
+			return nil, err
+		}
+		a.Duration = time.Duration(ms) * time.Millisecond
+		a.CreatedAt = time.Unix(created, 0)
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
@@ -0,0 +1,141 @@
+package webhooks
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/database"
+	"github.com/gg582/chi-blog/blog-backend/events"
+	"github.com/gg582/chi-blog/blog-backend/workerpool"
+)
+
+func setupWebhooks(t *testing.T) {
+	t.Helper()
+	database.Path = filepath.Join(t.TempDir(), "auth.db")
+	database.InitDatabase()
+	t.Cleanup(func() { database.DB.Close() })
+	oldQueue := JobQueue
+	JobQueue = make(chan Job, 16)
+	t.Cleanup(func() { JobQueue = oldQueue })
+}
+
+func TestSign(t *testing.T) {
+	// The well-known HMAC-SHA256 example with the key "key".
+	got := Sign("key", []byte("The quick brown fox jumps over the lazy dog"))
+	want := "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"
+	if got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+	if Sign("other", []byte("body")) == Sign("key", []byte("body")) {
+		t.Error("signatures under different secrets are equal")
+	}
+}
+
+func TestDispatchWants(t *testing.T) {
+	setupWebhooks(t)
+	all, err := Register("https://hooks.example/all", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	published, err := Register("https://hooks.example/published", []string{events.PostPublished})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := Register("https://hooks.example/deleted", []string{events.PostDeleted, events.PostUpdated})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		event string
+		want  []int64
+	}{
+		{events.PostPublished, []int64{all.ID, published.ID}},
+		{events.PostUpdated, []int64{all.ID, deleted.ID}},
+		{events.PostDeleted, []int64{all.ID, deleted.ID}},
+	}
+	for _, tt := range tests {
+		Dispatch(events.Event{Type: tt.event, PostID: "hello", Time: time.Now()})
+		var got []int64
+		for len(JobQueue) > 0 {
+			job := <-JobQueue
+			if job.Event != tt.event || job.Attempt != 1 {
+				t.Errorf("%s: queued %s attempt %d", tt.event, job.Event, job.Attempt)
+			}
+			got = append(got, job.Webhook.ID)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s went to webhooks %v, want %v", tt.event, got, tt.want)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	setupWebhooks(t)
+	oldDelay := RetryDelay
+	RetryDelay = 2 * time.Millisecond
+	t.Cleanup(func() { RetryDelay = oldDelay })
+	workerpool.Start(1, JobQueue, Process)
+
+	tests := []struct {
+		name     string
+		statuses []int // answers in order; the last one repeats
+		want     []int // status codes logged
+	}{
+		{"success", []int{200}, []int{200}},
+		{"server error then success", []int{500, 204}, []int{500, 204}},
+		{"too many requests then success", []int{429, 200}, []int{429, 200}},
+		{"client error is not retried", []int{404}, []int{404}},
+		{"gives up after MaxAttempts", []int{503}, []int{503, 503, 503, 503, 503}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			hits := 0
+			var secret string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				status := tt.statuses[min(hits, len(tt.statuses)-1)]
+				hits++
+				mu.Unlock()
+				if r.Header.Get(HeaderSignature) != Sign(secret, body) || r.Header.Get(HeaderEvent) != events.PostPublished {
+					t.Errorf("request headers %v do not match the signed %s payload", r.Header, events.PostPublished)
+				}
+				w.WriteHeader(status)
+			}))
+			defer srv.Close()
+			h, err := Register(srv.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			secret = h.Secret
+
+			Dispatch(events.Event{Type: events.PostPublished, PostID: "hello", Time: time.Now()})
+			// Long enough for every retry, which wait 2+4+8+16ms.
+			time.Sleep(200 * time.Millisecond)
+
+			log, err := Deliveries(h.ID, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for i := len(log) - 1; i >= 0; i-- {
+				got = append(got, log[i].StatusCode)
+				if log[i].Attempt != len(log)-i || log[i].DeliveryID != log[0].DeliveryID {
+					t.Errorf("log entry %d: attempt %d of delivery %s", len(log)-i, log[i].Attempt, log[i].DeliveryID)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("attempts answered %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package webmention
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/utils"
+)
+
+// maxBody is how much of a fetched page is read.
+const maxBody = 1 << 20
+
+const userAgent = "chi-blog webmention (+https://github.com/gg582/chi-blog)"
+
+// Client fetches sources and targets and posts outgoing mentions. The URLs
+// come from strangers, so it only connects to public addresses. Tests may
+// replace it.
+var Client = utils.NewPublicClient(10 * time.Second)
+
+// get fetches u and returns the response with its body read up to maxBody.
+func get(u string) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html, */*;q=0.5")
+	resp, err := Client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBody))
+	return resp, body, err
+}
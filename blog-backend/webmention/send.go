@@ -0,0 +1,159 @@
+package webmention
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/gg582/chi-blog/blog-backend/site"
+)
+
+// ErrNoEndpoint means the target does not accept webmentions.
+var ErrNoEndpoint = errors.New("target has no webmention endpoint")
+
+// Discover returns the webmention endpoint of target, from its Link header
+// or the first <link> or <a> element with rel="webmention".
+func Discover(target string) (string, error) {
+	resp, body, err := get(target)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("fetching target: %s", resp.Status)
+	}
+	base := resp.Request.URL
+
+	for _, header := range resp.Header.Values("Link") {
+		for _, link := range strings.Split(header, ",") {
+			ref, params, ok := strings.Cut(link, ";")
+			ref = strings.TrimSpace(ref)
+			if ok && strings.HasPrefix(ref, "<") && strings.HasSuffix(ref, ">") && hasRel(linkRel(params)) {
+				return resolve(base, ref[1:len(ref)-1])
+			}
+		}
+	}
+
+	if !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		return "", ErrNoEndpoint
+	}
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return "", ErrNoEndpoint
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			if t.Data != "link" && t.Data != "a" {
+				continue
+			}
+			var href, rel string
+			hasHref := false
+			for _, attr := range t.Attr {
+				switch attr.Key {
+				case "href":
+					href, hasHref = attr.Val, true
+				case "rel":
+					rel = attr.Val
+				}
+			}
+			if hasHref && hasRel(rel) {
+				// An empty href means the target page is its own endpoint.
+				return resolve(base, href)
+			}
+		}
+	}
+}
+
+// linkRel returns the rel parameter of a Link header entry.
+func linkRel(params string) string {
+	for _, p := range strings.Split(params, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(p), "=")
+		if strings.EqualFold(strings.TrimSpace(name), "rel") {
+			return strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return ""
+}
+
+// hasRel reports whether a space separated rel value contains webmention.
+func hasRel(rel string) bool {
+	for _, r := range strings.Fields(rel) {
+		if strings.EqualFold(r, "webmention") {
+			return true
+		}
+	}
+	return false
+}
+
+func resolve(base *url.URL, ref string) (string, error) {
+	u, err := base.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", ErrNoEndpoint
+	}
+	return u.String(), nil
+}
+
+// Send notifies target that source links to it.
+func Send(source, target string) error {
+	endpoint, err := Discover(target)
+	if err != nil {
+		return err
+	}
+	form := url.Values{"source": {source}, "target": {target}}
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint %s answered %s", endpoint, resp.Status)
+	}
+	return nil
+}
+
+// Links returns the distinct http and https links in a rendered post that
+// point away from this blog.
+func Links(doc []byte) []string {
+	var links []string
+	seen := map[string]bool{}
+	z := html.NewTokenizer(bytes.NewReader(doc))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return links
+		case html.StartTagToken:
+			t := z.Token()
+			if t.Data != "a" {
+				continue
+			}
+			for _, attr := range t.Attr {
+				if attr.Key != "href" {
+					continue
+				}
+				u, err := url.Parse(attr.Val)
+				if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" || site.IsHost(u.Hostname()) {
+					continue
+				}
+				u.Fragment = ""
+				if link := u.String(); !seen[link] {
+					seen[link] = true
+					links = append(links, link)
+				}
+			}
+		}
+	}
+}
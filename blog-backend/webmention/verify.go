@@ -0,0 +1,87 @@
+package webmention
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	// ErrNoLink means the source does not link to the target.
+	ErrNoLink = errors.New("source does not link to target")
+	// ErrGone means the source was deleted.
+	ErrGone = errors.New("source is gone")
+)
+
+// Verify fetches source and checks that it links to target. It returns the
+// title of the source page.
+func Verify(source, target string) (string, error) {
+	resp, body, err := get(source)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case resp.StatusCode == http.StatusGone:
+		return "", ErrGone
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("fetching source: %s", resp.Status)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		// Other documents, such as plain text or JSON, only need to
+		// mention the target.
+		if !bytes.Contains(body, []byte(target)) {
+			return "", ErrNoLink
+		}
+		return "", nil
+	}
+	title, found := scanSource(body, resp.Request.URL, target)
+	if !found {
+		return "", ErrNoLink
+	}
+	return title, nil
+}
+
+// scanSource looks for a link or embed of target in an HTML page at base
+// and returns the page title.
+func scanSource(doc []byte, base *url.URL, target string) (title string, found bool) {
+	z := html.NewTokenizer(bytes.NewReader(doc))
+	inTitle := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.TrimSpace(title), found
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			if t.Data == "title" && title == "" {
+				inTitle = true
+			}
+			for _, attr := range t.Attr {
+				if attr.Key != "href" && attr.Key != "src" {
+					continue
+				}
+				if ref, err := base.Parse(strings.TrimSpace(attr.Val)); err == nil && sameURL(ref.String(), target) {
+					found = true
+				}
+			}
+		case html.TextToken:
+			if inTitle {
+				title += string(z.Text())
+			}
+		case html.EndTagToken:
+			inTitle = false
+		}
+	}
+}
+
+// sameURL compares two URLs, ignoring a trailing slash.
+func sameURL(a, b string) bool {
+	return strings.TrimSuffix(a, "/") == strings.TrimSuffix(b, "/")
+}
@@ -0,0 +1,99 @@
+// Package webmention receives and sends webmentions
+// (https://www.w3.org/TR/webmention/). A received mention is stored with its
+// post once a worker has fetched the source and found the link to the
+// target; links in published posts are announced to the sites they point to.
+package webmention
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/gg582/chi-blog/blog-backend/content"
+	"github.com/gg582/chi-blog/blog-backend/database"
+	"github.com/gg582/chi-blog/blog-backend/site"
+)
+
+// Errors returned by Validate.
+var (
+	ErrInvalidURL    = errors.New("source and target must be http or https URLs")
+	ErrSameURL       = errors.New("source and target must differ")
+	ErrUnknownTarget = errors.New("target is not a post on this blog")
+)
+
+// Mention is a verified link from another page to a post.
+type Mention struct {
+	ID        int64     `json:"id"`
+	PostID    string    `json:"postId"`
+	Source    string    `json:"source"`
+	Target    string    `json:"target"`
+	Title     string    `json:"title,omitempty"` // Title of the source page
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"` // Last successful verification
+}
+
+// Validate checks a received mention before it is queued for verification
+// and returns the id of the post it targets.
+func Validate(source, target string) (string, error) {
+	s, err := url.Parse(source)
+	if err != nil || s.Scheme != "http" && s.Scheme != "https" || s.Host == "" {
+		return "", ErrInvalidURL
+	}
+	t, err := url.Parse(target)
+	if err != nil || t.Scheme != "http" && t.Scheme != "https" || t.Host == "" {
+		return "", ErrInvalidURL
+	}
+	if source == target {
+		return "", ErrSameURL
+	}
+	postID, ok := site.PostSlug(t)
+	if !ok {
+		return "", ErrUnknownTarget
+	}
+	if _, ok := content.PostTitle(postID); !ok {
+		return "", ErrUnknownTarget
+	}
+	return postID, nil
+}
+
+// save stores a verified mention, or refreshes it when it was seen before.
+func save(postID, source, target, title string) error {
+	now := time.Now().Unix()
+	_, err := database.DB.Exec(
+		`INSERT INTO webmentions (post_id, source, target, title, created_at, updated_at) VALUES (?,?,?,?,?,?)
+		ON CONFLICT (source, target) DO UPDATE SET title = excluded.title, updated_at = excluded.updated_at`,
+		postID, source, target, title, now, now,
+	)
+	return err
+}
+
+// remove deletes a mention whose source no longer links to its target.
+func remove(source, target string) error {
+	_, err := database.DB.Exec("DELETE FROM webmentions WHERE source = ? AND target = ?", source, target)
+	return err
+}
+
+// List returns the verified mentions of a post, oldest first.
+func List(postID string) ([]Mention, error) {
+	rows, err := database.DB.Query(
+		"SELECT id, post_id, source, target, title, created_at, updated_at FROM webmentions WHERE post_id = ? ORDER BY id",
+		postID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := []Mention{}
+	for rows.Next() {
+		var m Mention
+		var created, updated int64
+		if err := rows.Scan(&m.ID, &m.PostID, &m.Source, &m.Target, &m.Title, &created, &updated); err != nil {
+			return nil, err
+		}
+		m.CreatedAt = time.Unix(created, 0)
+		m.UpdatedAt = time.Unix(updated, 0)
+		mentions = append(mentions, m)
+	}
+	return mentions, rows.Err()
+}
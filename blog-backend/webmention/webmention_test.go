@@ -0,0 +1,168 @@
+package webmention
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// newServer starts a test server and points Client at it. The real client
+// refuses loopback addresses.
+func newServer(t *testing.T, h http.Handler) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	old := Client
+	Client = srv.Client()
+	t.Cleanup(func() { Client = old })
+	return srv
+}
+
+func TestDiscover(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		body   string
+		want   string // path of the endpoint on the test server, "" for none
+	}{
+		{"link header", `<https://other.example/x>; rel="other", </wm>; rel="webmention"`, `<a rel="webmention" href="/wrong">`, "/wm"},
+		{"link header with several rels", `</wm>; rel="webmention other"`, "", "/wm"},
+		{"link element", "", `<html><head><link rel="webmention" href="/wm-link"></head></html>`, "/wm-link"},
+		{"anchor", "", `<p><a href="/about">about</a> <a rel="webmention" href="wm-a">endpoint</a></p>`, "/page/wm-a"},
+		{"first element wins", "", `<link rel="webmention" href="/first"><a rel="webmention" href="/second">`, "/first"},
+		{"empty href is the page itself", "", `<link rel="webmention" href="">`, "/page/target"},
+		{"rel without href", "", `<link rel="webmention">`, ""},
+		{"no endpoint", "", `<a href="/wm">not marked</a>`, ""},
+		{"non-http endpoint", "", `<link rel="webmention" href="mailto:a@example.com">`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.header != "" {
+					w.Header().Set("Link", tt.header)
+				}
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				io.WriteString(w, tt.body)
+			}))
+
+			got, err := Discover(srv.URL + "/page/target")
+			if tt.want == "" {
+				if !errors.Is(err, ErrNoEndpoint) {
+					t.Errorf("Discover = %q, %v; want ErrNoEndpoint", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Discover: %v", err)
+			}
+			if got != srv.URL+tt.want {
+				t.Errorf("Discover = %q, want %q", got, srv.URL+tt.want)
+			}
+		})
+	}
+}
+
+func TestSend(t *testing.T) {
+	var form url.Values
+	srv := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/post":
+			w.Header().Set("Link", `</wm>; rel=webmention`)
+			w.Header().Set("Content-Type", "text/html")
+		case "/wm":
+			r.ParseForm()
+			form = r.PostForm
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	target := srv.URL + "/post"
+	if err := Send("https://blog.example/posts/a", target); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if form.Get("source") != "https://blog.example/posts/a" || form.Get("target") != target {
+		t.Errorf("endpoint received %v", form)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	const target = "https://blog.example/posts/a"
+	pages := map[string]struct {
+		contentType string
+		status      int
+		body        string
+	}{
+		"/links":   {"text/html; charset=utf-8", http.StatusOK, `<html><head><title> Reply </title></head><body><a href="` + target + `/">a</a></body></html>`},
+		"/embeds":  {"text/html", http.StatusOK, `<img src="` + target + `">`},
+		"/other":   {"text/html", http.StatusOK, `<html><title>Other</title><a href="https://blog.example/posts/b">b</a><p>` + target + `</p></html>`},
+		"/text":    {"text/plain", http.StatusOK, "see " + target},
+		"/no-text": {"text/plain", http.StatusOK, "nothing here"},
+		"/gone":    {"text/html", http.StatusGone, ""},
+		"/error":   {"text/html", http.StatusInternalServerError, ""},
+	}
+	srv := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", page.contentType)
+		w.WriteHeader(page.status)
+		io.WriteString(w, page.body)
+	}))
+
+	tests := []struct {
+		path      string
+		wantTitle string
+		wantErr   error
+	}{
+		{"/links", "Reply", nil},
+		{"/embeds", "", nil},
+		{"/text", "", nil},
+		// A page that only mentions the URL in its text does not link it.
+		{"/other", "", ErrNoLink},
+		{"/no-text", "", ErrNoLink},
+		{"/gone", "", ErrGone},
+	}
+	for _, tt := range tests {
+		title, err := Verify(srv.URL+tt.path, target)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("Verify(%s) error = %v, want %v", tt.path, err, tt.wantErr)
+			continue
+		}
+		if title != tt.wantTitle {
+			t.Errorf("Verify(%s) title = %q, want %q", tt.path, title, tt.wantTitle)
+		}
+	}
+	if _, err := Verify(srv.URL+"/error", target); err == nil {
+		t.Error("Verify accepted a source that failed to load")
+	}
+}
+
+func TestRelativeLinkInSource(t *testing.T) {
+	srv := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<a href="/posts/a">relative</a>`)
+	}))
+	if _, err := Verify(srv.URL+"/reply", srv.URL+"/posts/a"); err != nil {
+		t.Errorf("relative link to the target not recognised: %v", err)
+	}
+}
+
+// TestClientRefusesLoopback checks that the real client, unlike the one the
+// tests swap in, does not fetch private addresses.
+func TestClientRefusesLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `</wm>; rel=webmention`)
+	}))
+	defer srv.Close()
+	if _, err := Discover(srv.URL); err == nil || errors.Is(err, ErrNoEndpoint) {
+		t.Errorf("Discover of a loopback address: err = %v, want a refused connection", err)
+	}
+}
@@ -0,0 +1,88 @@
+package webmention
+
+import (
+	"errors"
+	"log"
+)
+
+// Job is a mention waiting to be processed. Received mentions are verified
+// and stored; outgoing ones are sent to the target's endpoint.
+type Job struct {
+	Source   string
+	Target   string
+	PostID   string // post targeted by a received mention
+	Outgoing bool
+}
+
+// JobQueue feeds the worker pool, which runs Process. It is nil until the
+// server starts it.
+var JobQueue chan Job
+
+// Enqueue submits a job without waiting. It reports false if the queue is
+// full or not running.
+func Enqueue(job Job) bool {
+	select {
+	case JobQueue <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// Announce queues a mention from source to every external link in the
+// rendered post and returns how many were queued.
+func Announce(source string, doc []byte) int {
+	queued := 0
+	for _, target := range Links(doc) {
+		if !Enqueue(Job{Source: source, Target: target, Outgoing: true}) {
+			log.Printf("Webmention queue is full. Not sending %s -> %s.", source, target)
+			continue
+		}
+		queued++
+	}
+	return queued
+}
+
+// Process verifies and stores a received mention, or sends an outgoing
+// one.
+func Process(id int, job Job) {
+	if job.Outgoing {
+		processOutgoing(id, job)
+	} else {
+		processIncoming(id, job)
+	}
+}
+
+func processIncoming(id int, job Job) {
+	title, err := Verify(job.Source, job.Target)
+	switch {
+	case errors.Is(err, ErrNoLink) || errors.Is(err, ErrGone):
+		// The source may have linked before; an update that removes the
+		// link also removes the mention.
+		log.Printf("Webmention worker %d: rejected %s -> %s: %v", id, job.Source, job.Target, err)
+		if err := remove(job.Source, job.Target); err != nil {
+			log.Printf("Error in webmention worker %d: %v", id, err)
+		}
+		return
+	case err != nil:
+		log.Printf("Webmention worker %d: could not verify %s -> %s: %v", id, job.Source, job.Target, err)
+		return
+	}
+	if err := save(job.PostID, job.Source, job.Target, title); err != nil {
+		log.Printf("Error in webmention worker %d: saving %s -> %s: %v", id, job.Source, job.Target, err)
+		return
+	}
+	log.Printf("Webmention worker %d: verified %s -> %s", id, job.Source, job.Target)
+}
+
+func processOutgoing(id int, job Job) {
+	err := Send(job.Source, job.Target)
+	switch {
+	case errors.Is(err, ErrNoEndpoint):
+		// Most sites do not take webmentions; nothing to do.
+	case err != nil:
+		log.Printf("Webmention worker %d: sending %s -> %s failed: %v", id, job.Source, job.Target, err)
+	default:
+		log.Printf("Webmention worker %d: sent %s -> %s", id, job.Source, job.Target)
+	}
+}
@@ -0,0 +1,13 @@
+package workerpool
+
+// Start starts n workers calling fn for every job received on queue, until
+// queue is closed. fn is passed the number of the worker, from 1, for logs.
+func Start[T any](n int, queue <-chan T, fn func(worker int, job T)) {
+	for i := 1; i <= n; i++ {
+		go func() {
+			for job := range queue {
+				fn(i, job)
+			}
+		}()
+	}
+}
@@ -22,21 +22,9 @@ type UploadResult struct {
     Error error
 }
 
-func NewWorkerPool(numWorkers int, jobQueue chan UploadJob) {
-    for i := 0; i < numWorkers; i++ {
-        go startWorker(i+1, jobQueue)
-    }
-}
-
-func startWorker(id int, jobQueue chan UploadJob) {
-    log.Printf("Worker %d started.", id)
-    for job := range jobQueue {
-        processUploadJob(id, job)
-    }
-    log.Printf("Worker %d stopped.", id)
-}
-
-func processUploadJob(id int, job UploadJob) {
+// ProcessUpload saves the file of job and sends the result back on
+// job.ResultChan. It is run by the upload workers, see Start.
+func ProcessUpload(id int, job UploadJob) {
     var result UploadResult
     result.OriginalFileName = job.FileHeader.Filename
 
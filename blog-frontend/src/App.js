@@ -10,6 +10,8 @@ import AboutPage from './pages/AboutPage';
 import ContactPage from './pages/ContactPage';
 import NewPostPage from './pages/NewPostPage';
 import LoginPage from './pages/LoginPage';
+import NewsletterPage from './pages/NewsletterPage';
+import ArchivePage from './pages/ArchivePage';
 
 // Import Authentication Context and Protected Route
 import { AuthProvider } from './context/AuthContext';
@@ -38,7 +40,11 @@ function AppContent() {
           <Route path="/posts/:id" element={<PostDetailPage />} />
           <Route path="/about" element={<AboutPage />} />
           <Route path="/contact" element={<ContactPage />} />
+          <Route path="/archive" element={<ArchivePage />} />
+          <Route path="/archive/:year/:month" element={<ArchivePage />} />
           <Route path="/login" element={<LoginPage />} />
+          <Route path="/newsletter/confirm" element={<NewsletterPage action="confirm" />} />
+          <Route path="/newsletter/unsubscribe" element={<NewsletterPage action="unsubscribe" />} />
 
           {/* Protected route for NewPostPage: only accessible if authenticated */}
           <Route
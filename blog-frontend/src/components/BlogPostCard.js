@@ -7,6 +7,7 @@ function BlogPostCard({ post }) {
       <p className="post-meta">
         {/* Display author and formatted creation date */}
         Author: {post.author} | Date: {new Date(post.createdAt).toLocaleDateString()}
+        {post.commentCount > 0 && ` | Comments: ${post.commentCount}`}
       </p>
       {/* Render the HTML content directly from the backend.
           WARNING: Using dangerouslySetInnerHTML can expose to XSS attacks if content is not trusted/sanitized.
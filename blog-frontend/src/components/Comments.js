@@ -0,0 +1,109 @@
+import React, { useCallback, useEffect, useState } from 'react';
+import API_BASE_URL from '../config/api';
+import './Comments.css';
+
+const emptyForm = { author: '', email: '', body: '', website: '' };
+
+// CommentForm posts a new comment, or a reply when parentId is set. The
+// "website" field is a honeypot hidden from people.
+function CommentForm({ postId, parentId, onDone }) {
+  const [form, setForm] = useState(emptyForm);
+  const [status, setStatus] = useState(null);
+
+  const handleChange = (e) => setForm({ ...form, [e.target.name]: e.target.value });
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    setStatus({ sending: true });
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/posts/${postId}/comments`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ ...form, parentId: parentId || 0 }),
+      });
+      const data = await response.json().catch(() => ({}));
+      if (!response.ok) {
+        throw new Error(data.message || `HTTP error! status: ${response.status}`);
+      }
+      setForm(emptyForm);
+      setStatus({ message: 'Thanks! Your comment will appear once it has been approved.' });
+      if (onDone) onDone();
+    } catch (err) {
+      setStatus({ error: err.message });
+    }
+  };
+
+  return (
+    <form className="comment-form" onSubmit={handleSubmit}>
+      <input name="author" placeholder="Name" value={form.author} onChange={handleChange} maxLength={80} required />
+      <input name="email" type="email" placeholder="Email (optional, never shown)" value={form.email} onChange={handleChange} />
+      <input name="website" className="comment-honeypot" value={form.website} onChange={handleChange} tabIndex={-1} autoComplete="off" aria-hidden="true" />
+      <textarea name="body" placeholder="Comment (markdown)" value={form.body} onChange={handleChange} maxLength={5000} rows={4} required />
+      <button type="submit" disabled={status && status.sending}>{parentId ? 'Reply' : 'Post comment'}</button>
+      {status && status.message && <p className="comment-status">{status.message}</p>}
+      {status && status.error && <p className="comment-status comment-error">{status.error}</p>}
+    </form>
+  );
+}
+
+// maxDepth matches comments.MaxDepth in the backend: deeper replies are
+// refused.
+const maxDepth = 3;
+
+function Comment({ comment, postId, depth = 1 }) {
+  const [replying, setReplying] = useState(false);
+  return (
+    <li className="comment">
+      <p className="comment-meta">
+        {comment.author} | {new Date(comment.createdAt).toLocaleString()}
+      </p>
+      {/* Comment HTML is rendered by the backend with a restricted policy. */}
+      <div className="comment-body" dangerouslySetInnerHTML={{ __html: comment.bodyHtml }}></div>
+      {depth < maxDepth && (
+        <button className="comment-reply" onClick={() => setReplying(!replying)}>
+          {replying ? 'Cancel' : 'Reply'}
+        </button>
+      )}
+      {replying && <CommentForm postId={postId} parentId={comment.id} />}
+      {comment.replies && (
+        <ul className="comment-replies">
+          {comment.replies.map((reply) => <Comment key={reply.id} comment={reply} postId={postId} depth={depth + 1} />)}
+        </ul>
+      )}
+    </li>
+  );
+}
+
+// Comments shows the approved comments on a post as a thread, with a form
+// for new ones.
+function Comments({ postId }) {
+  const [comments, setComments] = useState([]);
+  const [total, setTotal] = useState(0);
+
+  const fetchComments = useCallback(async () => {
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/posts/${postId}/comments`);
+      if (!response.ok) return;
+      setComments(await response.json());
+      setTotal(Number(response.headers.get('X-Total-Count')) || 0);
+    } catch (err) {
+      console.error('Error fetching comments:', err);
+    }
+  }, [postId]);
+
+  useEffect(() => {
+    fetchComments();
+  }, [fetchComments]);
+
+  return (
+    <section className="comments">
+      <h2>{total === 1 ? '1 comment' : `${total} comments`}</h2>
+      <ul className="comment-list">
+        {comments.map((comment) => <Comment key={comment.id} comment={comment} postId={postId} />)}
+      </ul>
+      <CommentForm postId={postId} />
+    </section>
+  );
+}
+
+export default Comments;
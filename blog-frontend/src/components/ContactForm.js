@@ -0,0 +1,50 @@
+import React, { useState } from 'react';
+import API_BASE_URL from '../config/api';
+import './ContactForm.css';
+
+const emptyForm = { name: '', email: '', subject: '', message: '', website: '' };
+
+// ContactForm sends a message to the site owner. The "website" field is a
+// honeypot hidden from people, and formToken, issued by GET /api/contact,
+// tells the server how long the form was open, as bots send it right away.
+function ContactForm({ formToken }) {
+  const [form, setForm] = useState(emptyForm);
+  const [status, setStatus] = useState(null);
+
+  const handleChange = (e) => setForm({ ...form, [e.target.name]: e.target.value });
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    setStatus({ sending: true });
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/contact`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ ...form, formToken }),
+      });
+      const data = await response.json().catch(() => ({}));
+      if (!response.ok) {
+        throw new Error(data.message || `HTTP error! status: ${response.status}`);
+      }
+      setForm(emptyForm);
+      setStatus({ message: data.message });
+    } catch (err) {
+      setStatus({ error: err.message });
+    }
+  };
+
+  return (
+    <form className="contact-form" onSubmit={handleSubmit}>
+      <input name="name" placeholder="Name" value={form.name} onChange={handleChange} maxLength={80} required />
+      <input name="email" type="email" placeholder="Email (optional, for a reply)" value={form.email} onChange={handleChange} />
+      <input name="subject" placeholder="Subject" value={form.subject} onChange={handleChange} maxLength={200} />
+      <textarea name="message" placeholder="Message" rows={8} value={form.message} onChange={handleChange} maxLength={10000} required />
+      <input name="website" className="contact-honeypot" value={form.website} onChange={handleChange} tabIndex={-1} autoComplete="off" aria-hidden="true" />
+      <button type="submit" disabled={status && status.sending}>Send</button>
+      {status && status.message && <p className="contact-status">{status.message}</p>}
+      {status && status.error && <p className="contact-status contact-error">{status.error}</p>}
+    </form>
+  );
+}
+
+export default ContactForm;
@@ -33,6 +33,7 @@ function Header() {
       <nav>
         <ul style={{ listStyle: 'none', margin: 0, padding: 0, display: 'flex' }}>
           <li style={{ marginRight: '15px' }}><Link to="/" style={{ color: 'white', textDecoration: 'none' }}>Home</Link></li>
+          <li style={{ marginRight: '15px' }}><Link to="/archive" style={{ color: 'white', textDecoration: 'none' }}>Archive</Link></li>
           <li style={{ marginRight: '15px' }}><Link to="/about" style={{ color: 'white', textDecoration: 'none' }}>About</Link></li>
           <li style={{ marginRight: '15px' }}><Link to="/contact" style={{ color: 'white', textDecoration: 'none' }}>Contact</Link></li>
 
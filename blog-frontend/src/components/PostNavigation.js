@@ -0,0 +1,32 @@
+import React from 'react';
+import { Link } from 'react-router-dom';
+
+// PostNavigation links to the previous and next parts of a post's series,
+// and to the posts published before and after it.
+function PostNavigation({ post }) {
+  const series = post.series;
+  const hasSeriesLinks = series && (series.previous || series.next);
+  if (!hasSeriesLinks && !post.previous && !post.next) return null;
+
+  return (
+    <nav className="post-navigation">
+      {hasSeriesLinks && (
+        <div className="post-navigation-series">
+          <p>
+            Part {series.part} of {series.total} in <strong>{series.title}</strong>
+          </p>
+          <div className="post-navigation-links">
+            {series.previous ? <Link to={`/posts/${series.previous.id}`}>← {series.previous.title}</Link> : <span />}
+            {series.next && <Link to={`/posts/${series.next.id}`}>{series.next.title} →</Link>}
+          </div>
+        </div>
+      )}
+      <div className="post-navigation-links">
+        {post.previous ? <Link to={`/posts/${post.previous.id}`}>← Older: {post.previous.title}</Link> : <span />}
+        {post.next && <Link to={`/posts/${post.next.id}`}>Newer: {post.next.title} →</Link>}
+      </div>
+    </nav>
+  );
+}
+
+export default PostNavigation;
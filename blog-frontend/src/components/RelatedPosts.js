@@ -0,0 +1,22 @@
+import React from 'react';
+import { Link } from 'react-router-dom';
+
+// RelatedPosts points readers to similar posts at the end of a post.
+function RelatedPosts({ posts }) {
+  if (!posts || posts.length === 0) return null;
+  return (
+    <section className="related-posts">
+      <h2>Related posts</h2>
+      <ul>
+        {posts.map((p) => (
+          <li key={p.id}>
+            <Link to={`/posts/${p.id}`}>{p.title}</Link>
+            {p.summary && <p>{p.summary}</p>}
+          </li>
+        ))}
+      </ul>
+    </section>
+  );
+}
+
+export default RelatedPosts;
@@ -0,0 +1,46 @@
+import React, { useState } from 'react';
+import API_BASE_URL from '../config/api';
+import './SubscribeForm.css';
+
+// SubscribeForm asks for an email address for the newsletter. The
+// "website" field is a honeypot hidden from people.
+function SubscribeForm() {
+  const [email, setEmail] = useState('');
+  const [website, setWebsite] = useState('');
+  const [status, setStatus] = useState(null);
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    setStatus({ sending: true });
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/subscribe`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ email, website }),
+      });
+      const data = await response.json().catch(() => ({}));
+      if (!response.ok) {
+        throw new Error(data.message || `HTTP error! status: ${response.status}`);
+      }
+      setEmail('');
+      setStatus({ message: data.message });
+    } catch (err) {
+      setStatus({ error: err.message });
+    }
+  };
+
+  return (
+    <form className="subscribe-form" onSubmit={handleSubmit}>
+      <label htmlFor="subscribe-email">Get new posts by email</label>
+      <div className="subscribe-row">
+        <input id="subscribe-email" type="email" placeholder="you@example.com" value={email} onChange={(e) => setEmail(e.target.value)} required />
+        <input name="website" className="subscribe-honeypot" value={website} onChange={(e) => setWebsite(e.target.value)} tabIndex={-1} autoComplete="off" aria-hidden="true" />
+        <button type="submit" disabled={status && status.sending}>Subscribe</button>
+      </div>
+      {status && status.message && <p className="subscribe-status">{status.message}</p>}
+      {status && status.error && <p className="subscribe-status subscribe-error">{status.error}</p>}
+    </form>
+  );
+}
+
+export default SubscribeForm;
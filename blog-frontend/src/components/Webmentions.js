@@ -0,0 +1,35 @@
+import React, { useEffect, useState } from 'react';
+import API_BASE_URL from '../config/api';
+
+// Webmentions lists the pages on other sites that link to a post.
+function Webmentions({ postId }) {
+  const [mentions, setMentions] = useState([]);
+
+  useEffect(() => {
+    const fetchMentions = async () => {
+      try {
+        const response = await fetch(`${API_BASE_URL}/api/posts/${postId}/webmentions`);
+        if (response.ok) setMentions(await response.json());
+      } catch (err) {
+        console.error('Error fetching webmentions:', err);
+      }
+    };
+    fetchMentions();
+  }, [postId]);
+
+  if (mentions.length === 0) return null;
+  return (
+    <section className="webmentions">
+      <h2>Mentioned by</h2>
+      <ul>
+        {mentions.map((m) => (
+          <li key={m.id}>
+            <a href={m.source} rel="nofollow ugc noopener" target="_blank">{m.title || m.source}</a>
+          </li>
+        ))}
+      </ul>
+    </section>
+  );
+}
+
+export default Webmentions;
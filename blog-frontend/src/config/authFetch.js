@@ -0,0 +1,43 @@
+import API_BASE_URL from "./api";
+
+// Cached CSRF token of the current session. The backend hands it out on login
+// and from /api/csrf-token; write requests must echo it in X-CSRF-Token.
+let csrfToken = null;
+
+export const setCsrfToken = (token) => {
+  csrfToken = token || null;
+};
+
+const fetchCsrfToken = async () => {
+  const response = await fetch(`${API_BASE_URL}/api/csrf-token`, { credentials: 'include' });
+  if (!response.ok) {
+    throw new Error(`Could not get CSRF token (status ${response.status}). Please log in again.`);
+  }
+  const data = await response.json();
+  csrfToken = data.csrfToken;
+  return csrfToken;
+};
+
+/**
+ * fetch() wrapper for authenticated write requests. It sends the session cookie
+ * and the CSRF token, and retries once with a fresh token if the backend
+ * rejects the one we had cached.
+ */
+const authFetch = async (url, options = {}, retried = false) => {
+  const token = csrfToken || (await fetchCsrfToken());
+  const response = await fetch(url, {
+    ...options,
+    credentials: 'include',
+    headers: { ...(options.headers || {}), 'X-CSRF-Token': token },
+  });
+  if (response.status === 403 && !retried) {
+    const body = await response.clone().json().catch(() => ({}));
+    if (body.code === 'CSRF_TOKEN_MISMATCH' || body.code === 'CSRF_TOKEN_MISSING') {
+      csrfToken = null;
+      return authFetch(url, options, true);
+    }
+  }
+  return response;
+};
+
+export default authFetch;
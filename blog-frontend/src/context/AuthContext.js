@@ -1,6 +1,8 @@
 // src/context/AuthContext.js
 
 import React, { createContext, useState, useContext } from 'react';
+import API_BASE_URL from '../config/api';
+import authFetch, { setCsrfToken } from '../config/authFetch';
 
 // Create a new React Context. This context will provide the authentication state
 // and functions (login, logout) to any component that consumes it.
@@ -37,6 +39,9 @@ export const AuthProvider = ({ children }) => {
    * and updating the 'isAuthenticated' state to false.
    */
   const logout = () => {
+    // End the server-side session too; failures only mean it was already gone.
+    authFetch(`${API_BASE_URL}/api/logout`, { method: 'POST' }).catch(() => {});
+    setCsrfToken(null);
     localStorage.removeItem('authToken'); // Remove the stored token
     setIsAuthenticated(false); // Set authentication status to false
   };
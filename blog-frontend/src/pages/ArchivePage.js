@@ -0,0 +1,100 @@
+import React, { useEffect, useState } from "react";
+import { Link, useParams } from "react-router-dom";
+import API_BASE_URL from "../config/api";
+import "./ArchivePage.css";
+
+const PAGE_SIZE = 20;
+
+const monthName = (year, month) =>
+  new Date(year, month - 1, 1).toLocaleString(undefined, { month: "long" });
+
+// ArchivePage lists the months with posts at /archive, and the posts of a
+// month, a page at a time, at /archive/:year/:month.
+function ArchivePage() {
+  const { year, month } = useParams();
+  const [archive, setArchive] = useState(null);
+  const [posts, setPosts] = useState(null);
+  const [total, setTotal] = useState(0);
+  const [offset, setOffset] = useState(0);
+  const [error, setError] = useState(null);
+
+  useEffect(() => {
+    setOffset(0);
+  }, [year, month]);
+
+  useEffect(() => {
+    const fetchArchive = async () => {
+      setError(null);
+      try {
+        const url = year
+          ? `${API_BASE_URL}/api/archive/${year}/${month}?limit=${PAGE_SIZE}&offset=${offset}`
+          : `${API_BASE_URL}/api/archive`;
+        const response = await fetch(url);
+        if (!response.ok) {
+          throw new Error(`HTTP error! status: ${response.status}`);
+        }
+        const data = await response.json();
+        if (year) {
+          setPosts(data);
+          setTotal(Number(response.headers.get("X-Total-Count")) || data.length);
+        } else {
+          setArchive(data);
+        }
+      } catch (e) {
+        setError(e);
+      }
+    };
+    fetchArchive();
+  }, [year, month, offset]);
+
+  if (error) {
+    return (
+      <main className="container archive-page">
+        <p>Error: {error.message}</p>
+      </main>
+    );
+  }
+
+  if (year) {
+    return (
+      <main className="container archive-page">
+        <p><Link to="/archive">← Archive</Link></p>
+        <h1>{monthName(year, month)} {year}</h1>
+        {posts && posts.length === 0 && <p>No posts this month.</p>}
+        <ul className="archive-posts">
+          {(posts || []).map((p) => (
+            <li key={p.id}>
+              <Link to={`/posts/${p.id}`}>{p.title}</Link>
+              <span className="archive-date">{new Date(p.createdAt).toLocaleDateString()}</span>
+            </li>
+          ))}
+        </ul>
+        <div className="archive-pager">
+          {offset > 0 && <button onClick={() => setOffset(Math.max(0, offset - PAGE_SIZE))}>← Newer</button>}
+          {offset + PAGE_SIZE < total && <button onClick={() => setOffset(offset + PAGE_SIZE)}>Older →</button>}
+        </div>
+      </main>
+    );
+  }
+
+  return (
+    <main className="container archive-page">
+      <h1>Archive</h1>
+      {(archive || []).map((y) => (
+        <section key={y.year}>
+          <h2>{y.year} <span className="archive-count">({y.count})</span></h2>
+          <ul className="archive-months">
+            {y.months.map((m) => (
+              <li key={m.month}>
+                <Link to={`/archive/${y.year}/${m.month}`}>{monthName(y.year, m.month)}</Link>{" "}
+                <span className="archive-count">({m.count})</span>
+              </li>
+            ))}
+          </ul>
+        </section>
+      ))}
+    </main>
+  );
+}
+
+export default ArchivePage;
@@ -1,10 +1,12 @@
 import React, { useEffect, useState } from 'react';
 import Header from '../components/Header'; // Re-use the Header component
+import ContactForm from '../components/ContactForm';
 import './ContactPage.css'; // Styling for the Contact page
 import API_BASE_URL from "../config/api";
 
 function ContactPage() {
   const [content, setContent] = useState(null);
+  const [formToken, setFormToken] = useState('');
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState(null);
 
@@ -16,6 +18,9 @@ function ContactPage() {
         if (!response.ok) {
           throw new Error(`HTTP error! status: ${response.status}`);
         }
+        // The token records when the form was shown; the contact form sends
+        // it back with the message.
+        setFormToken(response.headers.get('X-Form-Token') || '');
         const data = await response.json();
         // Assuming the backend sends a JSON object with 'title' and 'contentHtml'
         setContent(data);
@@ -63,6 +68,7 @@ function ContactPage() {
           <div className="contact-content" dangerouslySetInnerHTML={{ __html: content.contentHtml }}></div>
         )}
         {!content && <p>Contact page content not found.</p>}
+        <ContactForm formToken={formToken} />
       </main>
     </div>
   );
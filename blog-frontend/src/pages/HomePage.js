@@ -1,6 +1,7 @@
 import React, { useEffect, useState } from "react";
 import Header from "../components/Header"; // Header component for navigation
 import BlogPostCard from "../components/BlogPostCard"; // Card component for each blog post
+import SubscribeForm from "../components/SubscribeForm";
 import "./HomePage.css"; // Styling for the home page
 import API_BASE_URL from "../config/api";
 
@@ -68,6 +69,7 @@ function HomePage() {
             <p>No posts found. Be the first to write one!</p>
           )}
         </div>
+        <SubscribeForm />
       </main>
     </div>
   );
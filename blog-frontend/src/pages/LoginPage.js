@@ -5,6 +5,7 @@ import { useNavigate } from 'react-router-dom'; // Import useNavigate for progra
 import { useAuth } from '../context/AuthContext'; // Import useAuth hook from your AuthContext
 import Header from '../components/Header'; // ★★★ Import the reusable Header component ★★★
 import API_BASE_URL from "../config/api";
+import { setCsrfToken } from "../config/authFetch";
 
 /**
  * LoginPage Component
@@ -17,6 +18,8 @@ function LoginPage() {
   const [username, setUsername] = useState(''); // State for the username input field
   const [password, setPassword] = useState(''); // State for the password input field
   const [message, setMessage] = useState(''); // State for displaying login messages (e.g., success, error)
+  const [challenge, setChallenge] = useState(''); // Two-factor challenge issued after the password step
+  const [code, setCode] = useState(''); // TOTP or recovery code for the second step
 
   const navigate = useNavigate(); // Hook to programmatically change routes after login
   const { login } = useAuth(); // Access the login function from AuthContext
@@ -38,11 +41,18 @@ function LoginPage() {
       password: password,
     };
 
+    // Second step: the password was accepted and the backend asked for a two-factor code.
+    if (challenge) {
+      await submitTwoFactor();
+      return;
+    }
+
     try {
       // Send a POST request to the backend's login API endpoint
       // NOTE: In a production environment, this URL MUST be HTTPS for security.
       const response = await fetch(`${API_BASE_URL}/api/login`, {
         method: 'POST', // Use the POST HTTP method for login
+        credentials: 'include', // Accept the HttpOnly session cookie set by the backend
         headers: {
           'Content-Type': 'application/json', // Inform the server that the request body is JSON
         },
@@ -54,7 +64,14 @@ function LoginPage() {
       // If your backend specifically returns 202 Accepted for login, adjust 'response.ok' to 'response.status === 202'.
       if (response.ok) {
         const data = await response.json(); // Parse the JSON response from the backend
+        if (data.twoFactorRequired) {
+          // Keep the challenge and ask for the code from the authenticator app.
+          setChallenge(data.challenge);
+          setMessage('Enter the code from your authenticator app, or a recovery code.');
+          return;
+        }
         setMessage(data.message || 'Login successful!'); // Display the success message
+        setCsrfToken(data.csrfToken); // Write requests must echo this token
 
         // Call the login function from AuthContext to update the global authentication state.
         // In a real app, 'data.token' (if backend provides a JWT) would be passed here.
@@ -75,6 +92,40 @@ function LoginPage() {
     }
   };
 
+  /**
+   * Sends the two-factor code together with the challenge from the password step.
+   * Six digits are treated as a TOTP code, anything else as a recovery code.
+   */
+  const submitTwoFactor = async () => {
+    const isTotp = /^\d{6}$/.test(code.trim());
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/login/2fa`, {
+        method: 'POST',
+        credentials: 'include',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(isTotp ? { challenge, code: code.trim() } : { challenge, recoveryCode: code.trim() }),
+      });
+      if (response.ok) {
+        const data = await response.json();
+        setMessage(data.message || 'Login successful!');
+        setCsrfToken(data.csrfToken);
+        login('some_auth_token_from_backend');
+        localStorage.setItem('authToken', 'true');
+        navigate('/new-post');
+      } else {
+        const errorText = await response.text();
+        if (response.status === 401 && errorText.includes('challenge')) {
+          setChallenge(''); // The challenge expired; start over from the password step.
+        }
+        setMessage(`Login failed: ${errorText || response.statusText}`);
+        setCode('');
+      }
+    } catch (error) {
+      setMessage(`Network error: ${error.message}. Please check if the backend server is running.`);
+      console.error('Two-factor fetch error:', error);
+    }
+  };
+
   return (
     // Fragment to hold both the Header and the main content div
     <>
@@ -108,6 +159,21 @@ function LoginPage() {
               style={{ width: '100%', padding: '8px', boxSizing: 'border-box' }}
             />
           </div>
+          {/* Two-factor code input, shown only after the password step asks for it */}
+          {challenge && (
+            <div style={{ marginBottom: '15px' }}>
+              <label htmlFor="code" style={{ display: 'block', marginBottom: '5px' }}>Two-factor code:</label>
+              <input
+                type="text"
+                id="code"
+                value={code}
+                onChange={(e) => setCode(e.target.value)}
+                autoComplete="one-time-code"
+                required
+                style={{ width: '100%', padding: '8px', boxSizing: 'border-box' }}
+              />
+            </div>
+          )}
           {/* Submit button */}
           <button type="submit" style={{
             backgroundColor: '#007bff', // Blue background
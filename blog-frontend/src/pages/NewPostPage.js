@@ -2,6 +2,7 @@ import React, { useState, useEffect } from 'react';
 import { useNavigate, Link } from 'react-router-dom';
 import './NewPostPage.css';
 import API_BASE_URL from "../config/api";
+import authFetch from "../config/authFetch";
 
 import { marked } from 'marked'; 
 
@@ -80,6 +81,11 @@ function NewPostPage() {
   const [title, setTitle] = useState('');
   const [content, setContent] = useState('');
   const [author, setAuthor] = useState('');
+  const [tags, setTags] = useState('');
+  const [series, setSeries] = useState('');
+  const [seriesOrder, setSeriesOrder] = useState('');
+  const [summary, setSummary] = useState('');
+  const [draft, setDraft] = useState(false);
   const [error, setError] = useState(null);
   const [success, setSuccess] = useState(false);
   const navigate = useNavigate();
@@ -133,10 +139,18 @@ function NewPostPage() {
     }
 
     const backendUrl = `${API_BASE_URL}/api/new-post/${encodeURIComponent(postSlug)}`;
-    const postData = { title, content, author };
+    const postData = {
+      title, content, author, draft,
+      tags: tags.split(',').map((t) => t.trim()).filter(Boolean),
+      series: series.trim(),
+      summary: summary.trim(),
+    };
+    if (series.trim() && seriesOrder) {
+      postData.seriesOrder = parseInt(seriesOrder, 10);
+    }
 
     try {
-      const response = await fetch(backendUrl, {
+      const response = await authFetch(backendUrl, {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify(postData),
@@ -149,7 +163,9 @@ function NewPostPage() {
 
       const newPost = await response.json();
       setSuccess(true);
-      setTitle(''); setContent(''); setAuthor(''); setSelectedFiles([]); setUploadedResults([]); setPreviewHtml('');
+      setTitle(''); setContent(''); setAuthor('');
+      setTags(''); setSeries(''); setSeriesOrder(''); setSummary(''); setDraft(false);
+      setSelectedFiles([]); setUploadedResults([]); setPreviewHtml('');
       setTimeout(() => { navigate(`/posts/${newPost.id}`); }, 1500);
     } catch (e) {
       setError(e.message);
@@ -180,7 +196,7 @@ function NewPostPage() {
     });
 
     try {
-      const response = await fetch(UPLOAD_ENDPOINT, {
+      const response = await authFetch(UPLOAD_ENDPOINT, {
         method: 'POST',
         body: formData,
       });
@@ -260,6 +276,28 @@ function NewPostPage() {
             <input type="text" id="author" value={author} onChange={(e) => setAuthor(e.target.value)} required style={inputStyle} />
           </div>
 
+          <div style={formGroupStyle}>
+            <label htmlFor="tags" style={labelStyle}>Tags (comma separated):</label>
+            <input type="text" id="tags" value={tags} onChange={(e) => setTags(e.target.value)} style={inputStyle} />
+          </div>
+
+          <div style={formGroupStyle}>
+            <label htmlFor="series" style={labelStyle}>Series / Part:</label>
+            <input type="text" id="series" value={series} onChange={(e) => setSeries(e.target.value)} style={inputStyle} />
+            <input type="number" id="series-order" min="1" value={seriesOrder} onChange={(e) => setSeriesOrder(e.target.value)} style={inputStyle} />
+          </div>
+
+          <div style={formGroupStyle}>
+            <label htmlFor="summary" style={labelStyle}>Summary:</label>
+            <input type="text" id="summary" value={summary} onChange={(e) => setSummary(e.target.value)} style={inputStyle} />
+          </div>
+
+          <div style={formGroupStyle}>
+            <label style={labelStyle}>
+              <input type="checkbox" checked={draft} onChange={(e) => setDraft(e.target.checked)} /> Save as draft
+            </label>
+          </div>
+
           {/* Unified Batch File Upload Section */}
           <div style={{ ...formGroupStyle, ...uploadSectionStyle }}>
             <label style={labelStyle}>File Upload (Select Multiple Files):</label>
@@ -0,0 +1,38 @@
+import React, { useEffect, useState } from "react";
+import { useSearchParams } from "react-router-dom";
+import API_BASE_URL from "../config/api";
+
+// NewsletterPage handles the links in newsletter mails. action is "confirm"
+// or "unsubscribe". The token is posted from here rather than followed as a
+// GET link, so mail scanners that open links cannot act on it.
+function NewsletterPage({ action }) {
+  const [searchParams] = useSearchParams();
+  const [message, setMessage] = useState("Working...");
+
+  useEffect(() => {
+    const endpoint = action === "confirm" ? "/api/subscribe/confirm" : "/api/unsubscribe";
+    const submit = async () => {
+      try {
+        const response = await fetch(`${API_BASE_URL}${endpoint}`, {
+          method: "POST",
+          headers: { "Content-Type": "application/json" },
+          body: JSON.stringify({ token: searchParams.get("token") || "" }),
+        });
+        const data = await response.json().catch(() => ({}));
+        setMessage(data.message || `HTTP error! status: ${response.status}`);
+      } catch (e) {
+        setMessage(`Error: ${e.message}`);
+      }
+    };
+    submit();
+  }, [action, searchParams]);
+
+  return (
+    <main className="container">
+      <h2>Newsletter</h2>
+      <p>{message}</p>
+    </main>
+  );
+}
+
+export default NewsletterPage;
@@ -3,6 +3,10 @@
 import React, { useEffect, useState } from "react";
 import { useParams } from "react-router-dom";
 import Header from "../components/Header";
+import Comments from "../components/Comments";
+import Webmentions from "../components/Webmentions";
+import RelatedPosts from "../components/RelatedPosts";
+import PostNavigation from "../components/PostNavigation";
 import "./PostDetailPage.css";
 import API_BASE_URL from "../config/api";
 
@@ -22,7 +26,10 @@ function PostDetailPage() {
         const response = await fetch(`${API_BASE_URL}/api/posts/${id}`, {
           method: "POST",
           headers: { "Content-Type": "application/json" },
-          body: JSON.stringify({}),
+          // The session cookie lets a logged-in admin preview drafts.
+          credentials: "include",
+          // The referrer only feeds the blog's own view counter.
+          body: JSON.stringify({ referrer: document.referrer }),
         });
         if (!response.ok) {
           if (response.status === 404) { throw new Error("Post not found."); }
@@ -39,7 +46,21 @@ function PostDetailPage() {
     fetchPost();
   }, [id]);
 
+  // Effect hook: Loads the stylesheet for code blocks highlighted by the backend (pre.chroma).
+  useEffect(() => {
+    const id = "server-highlight-css";
+    if (!document.getElementById(id)) {
+      const link = document.createElement("link");
+      link.id = id;
+      link.rel = "stylesheet";
+      link.href = `${API_BASE_URL}/api/highlight.css`;
+      document.head.appendChild(link);
+    }
+  }, []);
+
   // Effect hook: Applies Highlight.js after post HTML content is rendered.
+  // Blocks the backend already highlighted are skipped; hljs only handles
+  // languages the server does not know.
   useEffect(() => {
     if (window.hljs) { // Check if window.hljs is available.
       // The 'desktop' language is already bundled in the CDN's highlight.min.js,
@@ -49,7 +70,7 @@ function PostDetailPage() {
         // Highlight code blocks within the specific post content div.
         const postContentElement = document.querySelector('.post-detail-content');
         if (postContentElement) {
-            postContentElement.querySelectorAll('pre code').forEach((block) => {
+            postContentElement.querySelectorAll('pre:not(.chroma) code').forEach((block) => {
                 if (!block.classList.contains('hljs')) { // Prevent re-highlighting already processed blocks.
                     window.hljs.highlightElement(block);
                 }
@@ -59,6 +80,32 @@ function PostDetailPage() {
     }
   }, [post]);
 
+  // Effect hook: Draws Mermaid diagrams. The backend emits their source as
+  // <pre class="mermaid">; math is already MathML and needs no script.
+  useEffect(() => {
+    if (window.mermaid && post && post.contentHtml) {
+      window.mermaid.initialize({ startOnLoad: false });
+      window.mermaid.run({ querySelector: '.post-detail-content pre.mermaid' });
+    }
+  }, [post]);
+
+  // Effect hook: Replaces {{< gist >}} links with the gist embed. The embed
+  // script only runs inside its own iframe, never in the page.
+  useEffect(() => {
+    if (!post || !post.contentHtml) return;
+    document.querySelectorAll('.post-detail-content div.gist[data-gist]').forEach((el) => {
+      const file = el.dataset.gistFile ? `?file=${encodeURIComponent(el.dataset.gistFile)}` : '';
+      const iframe = document.createElement('iframe');
+      iframe.className = 'gist-frame';
+      iframe.title = `Gist ${el.dataset.gist}`;
+      iframe.srcdoc = `<base target="_blank"><script src="https://gist.github.com/${el.dataset.gist}.js${file}"></script>`;
+      iframe.onload = () => {
+        iframe.style.height = `${iframe.contentDocument.body.scrollHeight + 16}px`;
+      };
+      el.replaceWith(iframe);
+    });
+  }, [post]);
+
   if (loading) {
     return (
       <div className="post-detail-page">
@@ -99,6 +146,10 @@ function PostDetailPage() {
           className="post-detail-content"
           dangerouslySetInnerHTML={{ __html: post.contentHtml }}
         ></div>
+        <PostNavigation post={post} />
+        <RelatedPosts posts={post.related} />
+        <Webmentions postId={id} />
+        <Comments postId={id} />
       </main>
     </div>
   );